@@ -1,6 +1,9 @@
 package triggerhook
 
 import (
+	"database/sql"
+	"fmt"
+
 	"github.com/pvelx/triggerhook/connection"
 	"github.com/pvelx/triggerhook/contracts"
 	"github.com/pvelx/triggerhook/error_service"
@@ -29,12 +32,7 @@ func Build(config Config) contracts.TriggerHookInterface {
 	errorService := error_service.New(&config.ErrorServiceOptions)
 	monitoringService := monitoring_service.New(&config.MonitoringServiceOptions)
 
-	repositoryService := repository.New(
-		connection.New(&config.Connection),
-		util.NewId(),
-		errorService,
-		&config.RepositoryOptions,
-	)
+	repositoryService := newRepository(config, errorService)
 
 	taskManager := task_manager.New(
 		repositoryService,
@@ -60,7 +58,8 @@ func Build(config Config) contracts.TriggerHookInterface {
 
 	senderService := sender_service.New(
 		taskManager,
-		waitingService.GetReadyToSendChan(),
+		waitingService.GetReadyToSendChan,
+		waitingService.GetDelayedChan(),
 		errorService,
 		monitoringService,
 		&config.SenderServiceOptions,
@@ -72,5 +71,42 @@ func Build(config Config) contracts.TriggerHookInterface {
 		preloaderService,
 		senderService,
 		monitoringService,
+		taskManager,
+	)
+}
+
+/*
+	Repositories of the drivers which are built in. The repositories of PostgreSQL and SQLite
+	are added by their files, so they are excluded with the drivers by the build tags
+*/
+var sqlRepositories = map[string]func(
+	client *sql.DB,
+	appInstanceId string,
+	eh contracts.EventHandlerInterface,
+	options *repository.Options,
+) contracts.RepositoryInterface{
+	connection.MySQL: repository.New,
+}
+
+func newRepository(config Config, errorService contracts.EventHandlerInterface) contracts.RepositoryInterface {
+	if config.Connection.Driver == connection.Memory {
+		return repository.NewMemory(util.NewId(), errorService, &config.RepositoryOptions)
+	}
+
+	driver := config.Connection.Driver
+	if driver == "" {
+		driver = connection.MySQL
+	}
+
+	newSqlRepository, ok := sqlRepositories[driver]
+	if !ok {
+		panic(fmt.Sprintf("unknown driver %s, it may be excluded by the build tags", driver))
+	}
+
+	return newSqlRepository(
+		connection.New(&config.Connection),
+		util.NewId(),
+		errorService,
+		&config.RepositoryOptions,
 	)
 }
@@ -0,0 +1,13 @@
+//go:build !nopostgres
+// +build !nopostgres
+
+package triggerhook
+
+import (
+	"github.com/pvelx/triggerhook/connection"
+	"github.com/pvelx/triggerhook/repository"
+)
+
+func init() {
+	sqlRepositories[connection.Postgres] = repository.NewPostgres
+}
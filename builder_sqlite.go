@@ -0,0 +1,13 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package triggerhook
+
+import (
+	"github.com/pvelx/triggerhook/connection"
+	"github.com/pvelx/triggerhook/repository"
+)
+
+func init() {
+	sqlRepositories[connection.SQLite] = repository.NewSQLite
+}
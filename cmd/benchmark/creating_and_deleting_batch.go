@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/pvelx/triggerhook"
+	"github.com/pvelx/triggerhook/connection"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+func creatingAndDeletingBatch(taskCount int, batchSize int) [][]string {
+	triggerHookService := triggerhook.Build(triggerhook.Config{
+		Connection: connection.Options{
+			User:     mysqlUser,
+			Password: mysqlPassword,
+			Host:     mysqlHost,
+			DbName:   mysqlDbName,
+		},
+	})
+
+	go func() {
+		if err := triggerHookService.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	point := time.Now()
+	batches := createBatches(triggerHookService, taskCount, batchSize, 300)
+	duration := time.Since(point)
+
+	point2 := time.Now()
+	deleteBatches(batches, triggerHookService)
+	durationDeleting := time.Since(point2)
+
+	return [][]string{
+		{
+			fmt.Sprintf("Creating task by batch of %d", batchSize),
+			fmt.Sprintf("%v", duration),
+			fmt.Sprintf("%f", float64(taskCount)/duration.Seconds()),
+		},
+		{
+			fmt.Sprintf("Deleting task by batch of %d", batchSize),
+			fmt.Sprintf("%v", durationDeleting),
+			fmt.Sprintf("%f", float64(taskCount)/durationDeleting.Seconds()),
+		},
+	}
+}
+
+func deleteBatches(batches <-chan []string, triggerHookService contracts.TriggerHookInterface) {
+	fmt.Println("\nDeleting task")
+	preparingBar := pb.StartNew(len(batches))
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for taskIds := range batches {
+				preparingBar.Add(1)
+				if _, err := triggerHookService.DeleteBatch(context.Background(), taskIds); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	preparingBar.Finish()
+}
+
+func createBatches(
+	triggerHookService contracts.TriggerHookInterface,
+	numberOfTask int,
+	batchSize int,
+	dispersion int,
+) <-chan []string {
+	numberOfBatch := (numberOfTask + batchSize - 1) / batchSize
+	createdBatches := make(chan []string, numberOfBatch)
+	batches := make(chan int, numberOfBatch)
+	for left := numberOfTask; left > 0; left -= batchSize {
+		if left < batchSize {
+			batches <- left
+		} else {
+			batches <- batchSize
+		}
+	}
+	close(batches)
+
+	rand.Seed(time.Now().UnixNano())
+	wg := sync.WaitGroup{}
+
+	fmt.Println("\nCreating task")
+	preparingBar := pb.StartNew(numberOfBatch)
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for size := range batches {
+				preparingBar.Add(1)
+				tasks := make([]domain.Task, size)
+				for i := range tasks {
+					tasks[i].ExecTime = time.Now().Add(time.Hour + time.Duration(rand.Intn(dispersion))*time.Second).Unix()
+				}
+				if _, err := triggerHookService.CreateBatch(context.Background(), tasks); err != nil {
+					fmt.Println(err)
+				}
+
+				taskIds := make([]string, 0, size)
+				for _, task := range tasks {
+					taskIds = append(taskIds, task.Id)
+				}
+				createdBatches <- taskIds
+			}
+		}()
+	}
+
+	wg.Wait()
+	preparingBar.Finish()
+	close(createdBatches)
+
+	return createdBatches
+}
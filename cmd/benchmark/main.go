@@ -39,6 +39,7 @@ func clear() {
 func main() {
 	testName := flag.String("test_name", "creating_and_deleting", "max rate creating/deleting tasks")
 	taskCount := flag.Int("task_count", 1000000, "count of task for the test")
+	batchSize := flag.Int("batch_size", 1000, "count of task in the batch for the test of batches")
 	flag.Parse()
 	fmt.Printf("\ncount of task: %d\n", *taskCount)
 
@@ -48,6 +49,9 @@ func main() {
 	case "creating_and_deleting":
 		fmt.Println("Benchmark: max rate creating/deleting tasks")
 		data = creatingAndDeleting(*taskCount)
+	case "creating_and_deleting_batch":
+		fmt.Println("Benchmark: max rate creating/deleting tasks by batches")
+		data = creatingAndDeletingBatch(*taskCount, *batchSize)
 	case "sending_and_confirmation":
 		fmt.Println("Benchmark: max rate sending/confirmation tasks")
 		data = sendingAndConfirmation(*taskCount)
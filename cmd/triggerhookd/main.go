@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pvelx/triggerhook"
+	"github.com/pvelx/triggerhook/contracts"
+)
+
+/*
+	The file of the configuration is in JSON. Durations of the server are strings in the format
+	of time.ParseDuration, for example "30s". Fields of Trigger hook are the same as in triggerhook.Config,
+	their durations are numbers of nanoseconds as time.Duration is decoded from JSON, for example:
+
+	{
+		"addr": ":8080",
+		"max_wait": "30s",
+		"delivery_timeout": "5m",
+		"shutdown_timeout": "30s",
+		"trigger_hook": {
+			"Connection": {"Driver": "sqlite3", "DbName": "triggerhook.db"}
+		}
+	}
+*/
+type config struct {
+	Addr            string             `json:"addr"`
+	MaxWait         duration           `json:"max_wait"`
+	DeliveryTimeout duration           `json:"delivery_timeout"`
+	ShutdownTimeout duration           `json:"shutdown_timeout"`
+	TriggerHook     triggerhook.Config `json:"trigger_hook"`
+}
+
+/*
+	Duration in the format of time.ParseDuration, for example "1m30s"
+*/
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+
+	return nil
+}
+
+func loadConfig(path string) (config, error) {
+	c := config{
+		Addr:            ":8080",
+		ShutdownTimeout: duration(30 * time.Second),
+	}
+	if path == "" {
+		return c, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&c)
+
+	return c, err
+}
+
+func main() {
+	configPath := flag.String("config", "", "path to the file of the configuration")
+	flag.Parse()
+
+	c, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	/*
+		Handlers of the events can not be set in the file, so the events are written to the log
+	*/
+	c.TriggerHook.ErrorServiceOptions.EventHandlers = map[contracts.Level]func(event contracts.EventError){
+		contracts.LevelFatal: func(event contracts.EventError) {
+			log.Fatalf("%s:%d %s %v", event.File, event.Line, event.EventMessage, event.Extra)
+		},
+		contracts.LevelError: func(event contracts.EventError) {
+			log.Printf("%s:%d %s %v", event.File, event.Line, event.EventMessage, event.Extra)
+		},
+	}
+
+	triggerHook := triggerhook.Build(c.TriggerHook)
+	s := newServer(triggerHook, &serverOptions{
+		MaxWait:         time.Duration(c.MaxWait),
+		DeliveryTimeout: time.Duration(c.DeliveryTimeout),
+	})
+	httpServer := &http.Server{Addr: c.Addr, Handler: s}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- triggerHook.Run()
+	}()
+
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+	log.Printf("trigger hook is listening on %s", c.Addr)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case sig := <-signals:
+		log.Printf("%s received, stopping", sig)
+	case err := <-runErr:
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.ShutdownTimeout))
+	defer cancel()
+
+	/*
+		Long polling requests are finished by stopping of the trigger hook, so it is stopped first
+	*/
+	if err := s.stop(ctx); err != nil {
+		log.Printf("cannot stop trigger hook: %s", err)
+	}
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("cannot stop http server: %s", err)
+	}
+}
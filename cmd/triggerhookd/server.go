@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imdario/mergo"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
+)
+
+var (
+	errDeliveryNotFound = errors.New("delivery not found, it is already confirmed, rolled back or expired")
+	errIncorrectWait    = errors.New("time of waiting is not correct")
+	errIncorrectDelay   = errors.New("delay of the rollback is not correct")
+	errMethodNotAllowed = errors.New("method is not allowed")
+)
+
+type serverOptions struct {
+	/*
+		Max time of waiting for a task by the consumer. It may be decreased by the parameter "wait" of the request
+	*/
+	MaxWait time.Duration
+
+	/*
+		Time after which the delivery which is neither confirmed nor rolled back is rolled back by the server
+	*/
+	DeliveryTimeout time.Duration
+}
+
+func newServer(triggerHook contracts.TriggerHookInterface, options *serverOptions) *server {
+	if options == nil {
+		options = &serverOptions{}
+	}
+
+	defaultOptions := serverOptions{
+		MaxWait:         30 * time.Second,
+		DeliveryTimeout: 5 * time.Minute,
+	}
+
+	if err := mergo.Merge(options, defaultOptions); err != nil {
+		panic(err)
+	}
+
+	s := &server{
+		triggerHook:     triggerHook,
+		maxWait:         options.MaxWait,
+		deliveryTimeout: options.DeliveryTimeout,
+		stopping:        make(chan struct{}),
+		deliveries:      make(map[string]*delivery),
+		mux:             http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/tasks", s.handleTasks)
+	s.mux.HandleFunc("/tasks/", s.handleTask)
+	s.mux.HandleFunc("/deliveries", s.handleDeliveries)
+	s.mux.HandleFunc("/deliveries/", s.handleDelivery)
+
+	return s
+}
+
+type server struct {
+	sync.Mutex
+	triggerHook     contracts.TriggerHookInterface
+	maxWait         time.Duration
+	deliveryTimeout time.Duration
+	stopping        chan struct{}
+
+	/*
+		Consumed tasks which are not confirmed or rolled back yet by the token of the delivery
+	*/
+	deliveries map[string]*delivery
+	mux        *http.ServeMux
+}
+
+type delivery struct {
+	token    string
+	task     contracts.TaskToSendInterface
+	deadline time.Time
+	timer    *time.Timer
+}
+
+type deliveryResponse struct {
+	Token        string      `json:"token"`
+	Task         domain.Task `json:"task"`
+	Redeliveries int         `json:"redeliveries"`
+	Deadline     int64       `json:"deadline"` //Time after which the delivery is rolled back by the server
+}
+
+func (d *delivery) response() deliveryResponse {
+	return deliveryResponse{
+		Token:        d.token,
+		Task:         d.task.Task(),
+		Redeliveries: d.task.Redeliveries(),
+		Deadline:     d.deadline.Unix(),
+	}
+}
+
+type rollbackRequest struct {
+	Delay   string `json:"delay,omitempty"`   //Duration before sending the task again, for example "10s"
+	Backoff bool   `json:"backoff,omitempty"` //The delay is calculated by the backoff policy of the sender service
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+/*
+	POST /tasks creates the task. Responds with 200 and the id of the existing task
+	if the task with the same idempotency key exists
+*/
+func (s *server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	var task domain.Task
+	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := s.triggerHook.CreateCtx(r.Context(), &task)
+	switch {
+	case err == contracts.TmErrorIdempotencyKeyExist:
+		writeJson(w, http.StatusOK, task)
+	case err != nil:
+		writeError(w, statusOf(err), err)
+	default:
+		writeJson(w, http.StatusCreated, task)
+	}
+}
+
+/*
+	GET /tasks/{id} returns the task,
+	DELETE /tasks/{id} deletes the task
+*/
+func (s *server) handleTask(w http.ResponseWriter, r *http.Request) {
+	taskId := strings.TrimPrefix(r.URL.Path, "/tasks/")
+	if taskId == "" || strings.Contains(taskId, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		task, err := s.triggerHook.Get(r.Context(), taskId)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJson(w, http.StatusOK, task)
+	case http.MethodDelete:
+		if err := s.triggerHook.DeleteCtx(r.Context(), taskId); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet+", "+http.MethodDelete)
+	}
+}
+
+/*
+	POST /deliveries?wait=10s&queue=emails waits for the task of the queue to execute, the default queue
+	if the queue is not specified. Responds with 204 if there is no task during the time of waiting
+*/
+func (s *server) handleDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	wait := s.maxWait
+	if value := r.URL.Query().Get("wait"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, errIncorrectWait)
+			return
+		}
+		if d < wait {
+			wait = d
+		}
+	}
+
+	//	The task is consumed only while the consumer waits for it,
+	//	so the task is not taken by the server when nobody is able to receive it
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	task, err := s.triggerHook.ConsumeQueueCtx(ctx, r.URL.Query().Get("queue"))
+	switch {
+	case r.Context().Err() != nil:
+		//	The consumer is gone, nobody receives the response
+		if task != nil {
+			task.Rollback()
+		}
+	case err == context.DeadlineExceeded:
+		w.WriteHeader(http.StatusNoContent)
+	case err != nil:
+		writeError(w, statusOf(err), err)
+	default:
+		d := s.register(task)
+		if d == nil {
+			writeError(w, http.StatusServiceUnavailable, contracts.ErrStopped)
+			return
+		}
+		writeJson(w, http.StatusOK, d.response())
+	}
+}
+
+/*
+	GET /deliveries/{token} returns the delivery,
+	POST /deliveries/{token}/confirm confirms the execution of the task,
+	POST /deliveries/{token}/rollback sends the task again
+*/
+func (s *server) handleDelivery(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/deliveries/"), "/")
+	token := path[0]
+
+	switch {
+	case len(path) == 1 && token != "":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+
+		s.Lock()
+		d, ok := s.deliveries[token]
+		var response deliveryResponse
+		if ok {
+			response = d.response()
+		}
+		s.Unlock()
+
+		if !ok {
+			writeError(w, http.StatusNotFound, errDeliveryNotFound)
+			return
+		}
+		writeJson(w, http.StatusOK, response)
+	case len(path) == 2 && token != "" && path[1] == "confirm":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+
+		d := s.take(token)
+		if d == nil {
+			writeError(w, http.StatusNotFound, errDeliveryNotFound)
+			return
+		}
+		d.task.Confirm()
+		w.WriteHeader(http.StatusNoContent)
+	case len(path) == 2 && token != "" && path[1] == "rollback":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+
+		var request rollbackRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		var delay time.Duration
+		if request.Delay != "" {
+			var err error
+			if delay, err = time.ParseDuration(request.Delay); err != nil || delay < 0 {
+				writeError(w, http.StatusBadRequest, errIncorrectDelay)
+				return
+			}
+		}
+
+		d := s.take(token)
+		if d == nil {
+			writeError(w, http.StatusNotFound, errDeliveryNotFound)
+			return
+		}
+		switch {
+		case request.Backoff:
+			d.task.RollbackWithBackoff()
+		case delay > 0:
+			d.task.RollbackWithDelay(delay)
+		default:
+			d.task.Rollback()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+/*
+	The delivery is rolled back if the consumer does not confirm or roll it back in time.
+	Returns nil if the server is stopping, the task is rolled back in this case
+*/
+func (s *server) register(task contracts.TaskToSendInterface) *delivery {
+	d := &delivery{
+		token:    util.NewId(),
+		task:     task,
+		deadline: time.Now().Add(s.deliveryTimeout),
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	select {
+	case <-s.stopping:
+		task.Rollback()
+		return nil
+	default:
+	}
+
+	s.deliveries[d.token] = d
+	d.timer = time.AfterFunc(s.deliveryTimeout, func() {
+		if expired := s.take(d.token); expired != nil {
+			expired.task.Rollback()
+		}
+	})
+
+	return d
+}
+
+/*
+	Removes the delivery, so it is confirmed or rolled back only once
+*/
+func (s *server) take(token string) *delivery {
+	s.Lock()
+	defer s.Unlock()
+
+	d, ok := s.deliveries[token]
+	if !ok {
+		return nil
+	}
+	delete(s.deliveries, token)
+	d.timer.Stop()
+
+	return d
+}
+
+/*
+	Rolls back the deliveries which are not finished, so the trigger hook is able to stop
+*/
+func (s *server) rollbackAll() {
+	s.Lock()
+	tokens := make([]string, 0, len(s.deliveries))
+	for token := range s.deliveries {
+		tokens = append(tokens, token)
+	}
+	s.Unlock()
+
+	for _, token := range tokens {
+		if d := s.take(token); d != nil {
+			d.task.Rollback()
+		}
+	}
+}
+
+func statusOf(err error) int {
+	switch err {
+	case contracts.TmErrorTaskExist:
+		return http.StatusConflict
+	case contracts.TmErrorTaskNotFound:
+		return http.StatusNotFound
+	case contracts.TmErrorUuidIsNotCorrect,
+		contracts.TmErrorRecurrenceIsNotCorrect,
+		contracts.TmErrorQueueIsNotCorrect,
+		contracts.TmErrorPriorityIsNotCorrect,
+		contracts.TmErrorIdempotencyKeyIsNotCorrect:
+		return http.StatusBadRequest
+	case contracts.TmErrorPayloadTooLarge,
+		contracts.TmErrorHeadersTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case contracts.ErrStopped:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, method string) {
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJson(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+/*
+	Stops the trigger hook. The deliveries which are not finished are rolled back, so they are sent again
+	after the start of the server
+*/
+func (s *server) stop(ctx context.Context) error {
+	s.Lock()
+	select {
+	case <-s.stopping:
+	default:
+		close(s.stopping)
+	}
+	s.Unlock()
+
+	s.rollbackAll()
+
+	return s.triggerHook.Stop(ctx)
+}
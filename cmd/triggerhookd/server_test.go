@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pvelx/triggerhook"
+	"github.com/pvelx/triggerhook/connection"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type triggerHookMock struct {
+	contracts.TriggerHookInterface
+	CreateCtxMock       func(ctx context.Context, task *domain.Task) error
+	DeleteCtxMock       func(ctx context.Context, taskId string) error
+	GetMock             func(ctx context.Context, taskId string) (domain.Task, error)
+	ConsumeQueueCtxMock func(ctx context.Context, queue string) (contracts.TaskToSendInterface, error)
+	StopMock            func(ctx context.Context) error
+}
+
+func (t *triggerHookMock) CreateCtx(ctx context.Context, task *domain.Task) error {
+	return t.CreateCtxMock(ctx, task)
+}
+
+func (t *triggerHookMock) DeleteCtx(ctx context.Context, taskId string) error {
+	return t.DeleteCtxMock(ctx, taskId)
+}
+
+func (t *triggerHookMock) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	return t.GetMock(ctx, taskId)
+}
+
+func (t *triggerHookMock) ConsumeQueueCtx(ctx context.Context, queue string) (contracts.TaskToSendInterface, error) {
+	return t.ConsumeQueueCtxMock(ctx, queue)
+}
+
+func (t *triggerHookMock) Stop(ctx context.Context) error {
+	if t.StopMock == nil {
+		return nil
+	}
+	return t.StopMock(ctx)
+}
+
+type taskToSendMock struct {
+	contracts.TaskToSendInterface
+	task   domain.Task
+	result chan string
+}
+
+func (t *taskToSendMock) Confirm() {
+	t.result <- "confirm"
+}
+
+func (t *taskToSendMock) Rollback() {
+	t.result <- "rollback"
+}
+
+func (t *taskToSendMock) RollbackWithDelay(delay time.Duration) {
+	t.result <- "rollback " + delay.String()
+}
+
+func (t *taskToSendMock) RollbackWithBackoff() {
+	t.result <- "rollback with backoff"
+}
+
+func (t *taskToSendMock) Task() domain.Task {
+	return t.task
+}
+
+func (t *taskToSendMock) Redeliveries() int {
+	return 0
+}
+
+/*
+	Consume returns the tasks of the default queue from the channel and blocks until ctx is done
+	when there are no tasks
+*/
+func consumeFrom(tasks chan contracts.TaskToSendInterface) func(ctx context.Context, queue string) (contracts.TaskToSendInterface, error) {
+	return func(ctx context.Context, queue string) (contracts.TaskToSendInterface, error) {
+		if queue != "" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		select {
+		case task, ok := <-tasks:
+			if !ok {
+				return nil, contracts.ErrStopped
+			}
+			return task, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func request(t *testing.T, handler http.Handler, method string, url string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, url, bytes.NewReader(data)))
+
+	return recorder
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"created", nil, http.StatusCreated},
+		{"exist", contracts.TmErrorTaskExist, http.StatusConflict},
+		{"incorrect uuid", contracts.TmErrorUuidIsNotCorrect, http.StatusBadRequest},
+		{"incorrect recurrence", contracts.TmErrorRecurrenceIsNotCorrect, http.StatusBadRequest},
+		{"incorrect queue", contracts.TmErrorQueueIsNotCorrect, http.StatusBadRequest},
+		{"incorrect priority", contracts.TmErrorPriorityIsNotCorrect, http.StatusBadRequest},
+		{"incorrect idempotency key", contracts.TmErrorIdempotencyKeyIsNotCorrect, http.StatusBadRequest},
+		{"large payload", contracts.TmErrorPayloadTooLarge, http.StatusRequestEntityTooLarge},
+		{"internal error", contracts.TmErrorCreatingTasks, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var createdTask domain.Task
+			s := newServer(&triggerHookMock{CreateCtxMock: func(ctx context.Context, task *domain.Task) error {
+				createdTask = *task
+				if tt.err == nil {
+					task.Id = "the id"
+				}
+				return tt.err
+			}}, nil)
+
+			task := domain.Task{ExecTime: 100, Payload: []byte("payload"), Headers: map[string]string{"key": "value"}}
+			response := request(t, s, http.MethodPost, "/tasks", task)
+
+			assert.Equal(t, tt.expectedStatus, response.Code)
+			assert.Equal(t, task, createdTask)
+			if tt.err != nil {
+				assert.JSONEq(t, `{"error":"`+tt.err.Error()+`"}`, response.Body.String())
+				return
+			}
+
+			var actual domain.Task
+			assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &actual))
+			task.Id = "the id"
+			assert.Equal(t, task, actual)
+		})
+	}
+
+	s := newServer(&triggerHookMock{}, nil)
+	assert.Equal(t, http.StatusBadRequest, request(t, s, http.MethodPost, "/tasks", "not a task").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(t, s, http.MethodGet, "/tasks", nil).Code)
+}
+
+func TestCreateWithIdempotencyKey(t *testing.T) {
+	s := newServer(&triggerHookMock{CreateCtxMock: func(ctx context.Context, task *domain.Task) error {
+		task.Id = "the existing id"
+		return contracts.TmErrorIdempotencyKeyExist
+	}}, nil)
+
+	task := domain.Task{ExecTime: 100, IdempotencyKey: "order-1"}
+	response := request(t, s, http.MethodPost, "/tasks", task)
+	assert.Equal(t, http.StatusOK, response.Code, "the existing task must be returned")
+
+	var actual domain.Task
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &actual))
+	task.Id = "the existing id"
+	assert.Equal(t, task, actual)
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"found", nil, http.StatusOK},
+		{"not found", contracts.TmErrorTaskNotFound, http.StatusNotFound},
+		{"internal error", contracts.TmErrorFindingTasks, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := domain.Task{Id: "the-id", ExecTime: 100, Payload: []byte("payload")}
+			s := newServer(&triggerHookMock{GetMock: func(ctx context.Context, taskId string) (domain.Task, error) {
+				assert.Equal(t, "the-id", taskId)
+				if tt.err != nil {
+					return domain.Task{}, tt.err
+				}
+				return task, nil
+			}}, nil)
+
+			response := request(t, s, http.MethodGet, "/tasks/the-id", nil)
+
+			assert.Equal(t, tt.expectedStatus, response.Code)
+			if tt.err != nil {
+				return
+			}
+			var actual domain.Task
+			assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &actual))
+			assert.Equal(t, task, actual)
+		})
+	}
+
+	s := newServer(&triggerHookMock{}, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, request(t, s, http.MethodPost, "/tasks/the-id", nil).Code)
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"deleted", nil, http.StatusNoContent},
+		{"not found", contracts.TmErrorTaskNotFound, http.StatusNotFound},
+		{"incorrect uuid", contracts.TmErrorUuidIsNotCorrect, http.StatusBadRequest},
+		{"internal error", contracts.TmErrorDeletingTask, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deletedTaskId string
+			s := newServer(&triggerHookMock{DeleteCtxMock: func(ctx context.Context, taskId string) error {
+				deletedTaskId = taskId
+				return tt.err
+			}}, nil)
+
+			response := request(t, s, http.MethodDelete, "/tasks/the-id", nil)
+
+			assert.Equal(t, tt.expectedStatus, response.Code)
+			assert.Equal(t, "the-id", deletedTaskId)
+		})
+	}
+}
+
+func TestConsumeAndConfirm(t *testing.T) {
+	tasks := make(chan contracts.TaskToSendInterface, 1)
+	s := newServer(&triggerHookMock{ConsumeQueueCtxMock: consumeFrom(tasks)}, nil)
+
+	response := request(t, s, http.MethodPost, "/deliveries?wait=10ms", nil)
+	assert.Equal(t, http.StatusNoContent, response.Code, "there are no tasks during the time of waiting")
+
+	task := &taskToSendMock{task: domain.Task{Id: util.NewId(), ExecTime: 100}, result: make(chan string, 1)}
+	tasks <- task
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, tasks, 1, "the task must not be consumed while nobody waits for it")
+
+	response = request(t, s, http.MethodPost, "/deliveries?wait=1s", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	var delivery deliveryResponse
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &delivery))
+	assert.Equal(t, task.task, delivery.Task)
+	assert.NotEmpty(t, delivery.Token)
+
+	response = request(t, s, http.MethodGet, "/deliveries/"+delivery.Token, nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	var fetched deliveryResponse
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &fetched))
+	assert.Equal(t, delivery, fetched)
+
+	response = request(t, s, http.MethodPost, "/deliveries/"+delivery.Token+"/confirm", nil)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	assert.Equal(t, "confirm", <-task.result)
+
+	response = request(t, s, http.MethodPost, "/deliveries/"+delivery.Token+"/confirm", nil)
+	assert.Equal(t, http.StatusNotFound, response.Code, "the delivery must be confirmed only once")
+	response = request(t, s, http.MethodGet, "/deliveries/"+delivery.Token, nil)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	response = request(t, s, http.MethodPost, "/deliveries?wait=incorrect", nil)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestConsumeQueue(t *testing.T) {
+	task := &taskToSendMock{task: domain.Task{Id: util.NewId(), Queue: "emails"}, result: make(chan string, 1)}
+	s := newServer(&triggerHookMock{
+		ConsumeQueueCtxMock: func(ctx context.Context, queue string) (contracts.TaskToSendInterface, error) {
+			assert.Equal(t, "emails", queue)
+			return task, nil
+		},
+	}, nil)
+
+	response := request(t, s, http.MethodPost, "/deliveries?queue=emails", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	var delivery deliveryResponse
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &delivery))
+	assert.Equal(t, task.task, delivery.Task)
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     interface{}
+		expected string
+	}{
+		{"immediately", nil, "rollback"},
+		{"with delay", rollbackRequest{Delay: "10s"}, "rollback 10s"},
+		{"with backoff", rollbackRequest{Backoff: true}, "rollback with backoff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := make(chan contracts.TaskToSendInterface, 1)
+			s := newServer(&triggerHookMock{ConsumeQueueCtxMock: consumeFrom(tasks)}, nil)
+
+			task := &taskToSendMock{task: domain.Task{Id: util.NewId()}, result: make(chan string, 1)}
+			tasks <- task
+
+			var delivery deliveryResponse
+			response := request(t, s, http.MethodPost, "/deliveries", nil)
+			assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &delivery))
+
+			response = request(t, s, http.MethodPost, "/deliveries/"+delivery.Token+"/rollback", tt.body)
+			assert.Equal(t, http.StatusNoContent, response.Code)
+			assert.Equal(t, tt.expected, <-task.result)
+		})
+	}
+
+	s := newServer(&triggerHookMock{}, nil)
+	response := request(t, s, http.MethodPost, "/deliveries/unknown/rollback", rollbackRequest{Delay: "incorrect"})
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	response = request(t, s, http.MethodPost, "/deliveries/unknown/rollback", nil)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestDeliveryTimeout(t *testing.T) {
+	tasks := make(chan contracts.TaskToSendInterface, 1)
+	s := newServer(&triggerHookMock{ConsumeQueueCtxMock: consumeFrom(tasks)}, &serverOptions{DeliveryTimeout: 50 * time.Millisecond})
+
+	task := &taskToSendMock{task: domain.Task{Id: util.NewId()}, result: make(chan string, 1)}
+	tasks <- task
+
+	var delivery deliveryResponse
+	response := request(t, s, http.MethodPost, "/deliveries", nil)
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &delivery))
+
+	assert.Equal(t, "rollback", <-task.result, "the delivery must be rolled back after the timeout")
+	response = request(t, s, http.MethodPost, "/deliveries/"+delivery.Token+"/confirm", nil)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestStop(t *testing.T) {
+	tasks := make(chan contracts.TaskToSendInterface, 1)
+	s := newServer(&triggerHookMock{
+		ConsumeQueueCtxMock: consumeFrom(tasks),
+		StopMock: func(ctx context.Context) error {
+			close(tasks)
+			return nil
+		},
+	}, nil)
+
+	task := &taskToSendMock{task: domain.Task{Id: util.NewId()}, result: make(chan string, 1)}
+	tasks <- task
+	response := request(t, s, http.MethodPost, "/deliveries", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	assert.NoError(t, s.stop(context.Background()))
+	assert.Equal(t, "rollback", <-task.result, "the unfinished delivery must be rolled back")
+
+	response = request(t, s, http.MethodPost, "/deliveries", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+}
+
+func TestInMemory(t *testing.T) {
+	triggerHook := triggerhook.Build(triggerhook.Config{
+		Connection: connection.Options{Driver: connection.Memory},
+	})
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	testServer := httptest.NewServer(newServer(triggerHook, nil))
+	defer testServer.Close()
+
+	body := `{"exec_time":` + strconv.FormatInt(time.Now().Unix(), 10) + `,"payload":"cGF5bG9hZA=="}`
+	response, err := http.Post(testServer.URL+"/tasks", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created domain.Task
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&created))
+	response.Body.Close()
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+
+	response, err = http.Post(testServer.URL+"/tasks", "application/json", strings.NewReader(`{"id":"`+created.Id+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	response, err = http.Post(testServer.URL+"/deliveries?wait=10s", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delivery deliveryResponse
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&delivery))
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, created.Id, delivery.Task.Id)
+	assert.Equal(t, []byte("payload"), delivery.Task.Payload)
+
+	response, err = http.Post(testServer.URL+"/deliveries/"+delivery.Token+"/confirm", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+}
@@ -0,0 +1,131 @@
+package connection
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/imdario/mergo"
+)
+
+const (
+	MySQL    = "mysql"
+	Postgres = "postgres"
+	SQLite   = "sqlite3"
+
+	/*
+		Tasks are kept in the memory of the process, the connection to a database is not needed
+	*/
+	Memory = "memory"
+)
+
+type Options struct {
+	/*
+		Database driver: MySQL, Postgres, SQLite or Memory. MySQL by default.
+		The drivers of PostgreSQL and SQLite are excluded from the build by the tags nopostgres and nosqlite
+	*/
+	Driver string
+
+	/*
+		Data source name in the format of the driver.
+		If it is specified User, Password, Host and DbName are ignored
+	*/
+	Dsn string
+
+	User     string
+	Password string
+	Host     string
+
+	/*
+		Name of the database. For SQLite it is the path of the database file
+	*/
+	DbName       string
+	MaxIdleConns int
+	MaxOpenConns int
+}
+
+func New(options *Options) *sql.DB {
+
+	if options == nil {
+		options = &Options{}
+	}
+
+	if options.Driver == "" {
+		options.Driver = MySQL
+	}
+
+	defaultOptions := Options{
+		Host:         "127.0.0.1:3306",
+		User:         "root",
+		Password:     "",
+		DbName:       "task",
+		MaxOpenConns: 25,
+		MaxIdleConns: 25,
+	}
+
+	if options.Driver == Postgres {
+		defaultOptions.Host = "127.0.0.1:5432"
+		defaultOptions.User = "postgres"
+	}
+
+	if options.Driver == SQLite {
+		defaultOptions.DbName = "task.db"
+	}
+
+	if err := mergo.Merge(options, defaultOptions); err != nil {
+		panic(err)
+	}
+
+	dataSourceName := options.Dsn
+	if dataSourceName == "" {
+		switch options.Driver {
+		case MySQL:
+			dataSourceName = fmt.Sprintf(
+				"%s:%s@tcp(%s)/%s?charset=utf8",
+				options.User,
+				options.Password,
+				options.Host,
+				options.DbName,
+			)
+		case Postgres:
+			dataSourceName = (&url.URL{
+				Scheme:   "postgres",
+				User:     url.UserPassword(options.User, options.Password),
+				Host:     options.Host,
+				Path:     options.DbName,
+				RawQuery: "sslmode=disable",
+			}).String()
+		case SQLite:
+			/*
+				Writing transactions lock the database at the beginning, so they wait for each other
+				instead of failing when a reading transaction tries to write
+			*/
+			dataSourceName = fmt.Sprintf(
+				"file:%s?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=1&_txlock=immediate",
+				options.DbName,
+			)
+		default:
+			panic(fmt.Sprintf("unknown driver %s", options.Driver))
+		}
+	}
+
+	Client, err := sql.Open(options.Driver, dataSourceName)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := Client.Ping(); err != nil {
+		panic(err)
+	}
+
+	if options.MaxIdleConns > 0 {
+		Client.SetMaxIdleConns(options.MaxIdleConns)
+	}
+
+	if options.MaxOpenConns > 0 {
+		Client.SetMaxOpenConns(options.MaxOpenConns)
+	}
+
+	return Client
+}
@@ -0,0 +1,9 @@
+//go:build !nopostgres
+// +build !nopostgres
+
+package connection
+
+/*
+	The driver of PostgreSQL is excluded by the build tag nopostgres
+*/
+import _ "github.com/lib/pq"
@@ -0,0 +1,9 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package connection
+
+/*
+	The driver of SQLite requires cgo, it is excluded by the build tag nosqlite
+*/
+import _ "github.com/mattn/go-sqlite3"
@@ -14,43 +14,328 @@ import (
 
 type SenderServiceInterface interface {
 	Run()
+
+	/*
+		Consumes the task of the default queue. Returns nil after stopping
+	*/
 	Consume() TaskToSendInterface
+
+	/*
+		Consumes the task of the queue. Returns nil after stopping
+	*/
+	ConsumeQueue(queue string) TaskToSendInterface
+
+	/*
+		Consumes the task of the queue. Returns the error of ctx if ctx is done before the task is ready,
+		ErrStopped after stopping
+	*/
+	ConsumeCtx(ctx context.Context, queue string) (TaskToSendInterface, error)
+
+	/*
+		Consumes from 1 to max tasks of the queue. Blocks until the first task is ready,
+		the other tasks are returned if they are ready. The errors are the same as of ConsumeCtx
+	*/
+	ConsumeBatch(ctx context.Context, queue string, max int) ([]TaskToSendInterface, error)
+
+	/*
+		Stops sending, waits for the sent tasks to be confirmed or rolled back and flushes the confirmations
+	*/
+	Stop(ctx context.Context) error
 }
 
 type TaskToSendInterface interface {
 	Confirm()
+
+	/*
+		Sends the task again immediately
+	*/
 	Rollback()
+
+	/*
+		Sends the task again after the delay
+	*/
+	RollbackWithDelay(delay time.Duration)
+
+	/*
+		Sends the task again after the delay calculated by the backoff policy of the sender service
+	*/
+	RollbackWithBackoff()
+
+	/*
+		Sends the task again after the delay without counting the attempt,
+		for example when the task is not executed because the consumer is busy
+	*/
+	Postpone(delay time.Duration)
+
+	/*
+		Moves the task to the dead letter store at once, the task is not sent again
+	*/
+	Reject(reason string)
+
 	Task() domain.Task
+
+	/*
+		Number of times the task was sent again because it was not confirmed or rolled back in time
+	*/
+	Redeliveries() int
 }
 
 /*	--------------------------------------------------
 	Task manager
 */
 type TaskManagerInterface interface {
+	/*
+		Returns TmErrorIdempotencyKeyExist if the idempotency key of the task is reserved by another task,
+		the id of that task is filled in the task
+	*/
 	Create(ctx context.Context, task *domain.Task, isTaken bool) error
 	Delete(ctx context.Context, taskId string) error
-	GetTasksToComplete(ctx context.Context, preloadingTimeRange time.Duration) (CollectionsInterface, error)
+
+	/*
+		Creates the tasks in one transaction. The ids and the times of execution are filled in the tasks.
+		Returns the result of each task in the order of the tasks: nil if the task is created,
+		TmErrorTaskExist, TmErrorIdempotencyKeyExist or the error of the validation, for example TmErrorUuidIsNotCorrect
+	*/
+	CreateBatch(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error)
+
+	/*
+		Returns the result of each task in the order of the ids: nil if the task is deleted or TmErrorTaskNotFound
+	*/
+	DeleteBatch(ctx context.Context, taskIds []string) ([]error, error)
+
+	/*
+		Returns TmErrorTaskNotFound if the task does not exist
+	*/
+	Get(ctx context.Context, taskId string) (domain.Task, error)
+
+	/*
+		Tasks in order of time of execution
+	*/
+	List(ctx context.Context, filter TaskFilter) ([]domain.Task, error)
+
+	/*
+		Moves the task to the time of execution of the given task. The other fields of the given task
+		are filled from the stored task. Returns TmErrorTaskNotFound if the task does not exist
+	*/
+	Reschedule(ctx context.Context, task *domain.Task, isTaken bool) error
+
+	/*
+		Takes the collections of the tasks of the queues. The tasks of all queues are taken if queues are not specified
+	*/
+	GetTasksToComplete(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (CollectionsInterface, error)
 	ConfirmExecution(ctx context.Context, task []domain.Task) error
+
+	/*
+		Saves the count of attempts of the execution of the task
+	*/
+	RollbackExecution(ctx context.Context, task domain.Task) error
+
+	/*
+		Deletes the task and saves it to the dead letter store with the reason
+	*/
+	MoveToDeadLetter(ctx context.Context, task domain.Task, reason string) error
+
+	/*
+		Dead lettered tasks in order of time of dead lettering
+	*/
+	GetDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error)
+	GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error)
+
+	/*
+		Deletes the task from the dead letter store and creates it again.
+		Returns TmErrorIdempotencyKeyExist if the idempotency key of the task is reserved by another task,
+		the id of the existing task is filled in the task
+	*/
+	RequeueDeadLetter(ctx context.Context, task *domain.Task, isTaken bool) error
+
+	/*
+		Deletes the tasks dead lettered before the time. Returns count of deleted tasks
+	*/
+	PurgeDeadLetters(ctx context.Context, before time.Time) (int64, error)
+
+	/*
+		Returns the collections of the tasks taken by the instance to the pool, so they can be taken again
+	*/
+	Release(ctx context.Context, tasks []domain.Task) error
+
+	/*
+		Notifies other instances that the instance is alive, so they do not take its collections
+	*/
+	Heartbeat(ctx context.Context) error
+
+	/*
+		Releases all collections taken by the instance on shutdown
+	*/
+	Unregister(ctx context.Context) error
 }
 
 var (
-	TmErrorCreatingTasks       = errors.New("cannot create task")
-	TmErrorUuidIsNotCorrect    = errors.New("uuid of the task is not correct")
-	TmErrorTaskExist           = errors.New("task with this uuid already exist")
-	TmErrorConfirmationTasks   = errors.New("cannot confirm execution of tasks")
-	TmErrorGetTasks            = errors.New("cannot get any tasks")
-	TmErrorCollectionsNotFound = errors.New("collections not found")
-	TmErrorDeletingTask        = errors.New("cannot delete task")
-	TmErrorTaskNotFound        = errors.New("task not found")
+	TmErrorCreatingTasks          = errors.New("cannot create task")
+	TmErrorUuidIsNotCorrect       = errors.New("uuid of the task is not correct")
+	TmErrorTaskExist              = errors.New("task with this uuid already exist")
+	TmErrorConfirmationTasks      = errors.New("cannot confirm execution of tasks")
+	TmErrorGetTasks               = errors.New("cannot get any tasks")
+	TmErrorCollectionsNotFound    = errors.New("collections not found")
+	TmErrorDeletingTask           = errors.New("cannot delete task")
+	TmErrorTaskNotFound           = errors.New("task not found")
+	TmErrorPayloadTooLarge        = errors.New("payload of the task is too large")
+	TmErrorHeadersTooLarge        = errors.New("headers of the task are too large")
+	TmErrorRecurrenceIsNotCorrect = errors.New("recurrence of the task is not correct")
+	TmErrorRollbackTask           = errors.New("cannot roll back execution of the task")
+	TmErrorMovingToDeadLetter     = errors.New("cannot move task to dead letter")
+	TmErrorGettingDeadLetters     = errors.New("cannot get dead lettered tasks")
+	TmErrorRequeueTask            = errors.New("cannot requeue dead lettered task")
+	TmErrorPurgingDeadLetters     = errors.New("cannot purge dead lettered tasks")
+	TmErrorReleasingTasks         = errors.New("cannot release tasks")
+	TmErrorHeartbeat              = errors.New("cannot save heartbeat of the instance")
+	TmErrorUnregistering          = errors.New("cannot unregister the instance")
+	TmErrorFindingTasks           = errors.New("cannot find tasks")
+	TmErrorReschedulingTask       = errors.New("cannot reschedule task")
+	TmErrorQueueIsNotCorrect      = errors.New("queue of the task is not correct")
+	TmErrorPriorityIsNotCorrect   = errors.New("priority of the task is not correct")
+
+	TmErrorIdempotencyKeyIsNotCorrect = errors.New("idempotency key of the task is not correct")
+	TmErrorIdempotencyKeyExist        = errors.New("task with this idempotency key already exist")
 )
 
 /*	--------------------------------------------------
 	Repository
 */
+
+/*
+	Filter of the listed tasks. The fields which are not specified do not filter the tasks
+*/
+type TaskFilter struct {
+	/*
+		Tasks executed at the time or later. Not bounded if it is zero
+	*/
+	ExecTimeFrom time.Time
+
+	/*
+		Tasks executed before the time. Not bounded if it is zero
+	*/
+	ExecTimeTo time.Time
+
+	/*
+		true - tasks taken by any instance, false - tasks not taken by instances
+	*/
+	Taken *bool
+
+	/*
+		Tasks taken by the instance
+	*/
+	TakenBy string
+
+	/*
+		Max count of the tasks, 100 if not specified
+	*/
+	Limit  int
+	Offset int
+}
+
 type RepositoryInterface interface {
+	/*
+		Reserves the idempotency key of the task in the same transaction.
+		Returns RepoErrorIdempotencyKeyExist if the key is reserved by another task
+	*/
 	Create(ctx context.Context, task domain.Task, isTaken bool) error
+
+	/*
+		Deletes the tasks. The idempotency keys of the tasks are kept for the retention
+	*/
 	Delete(ctx context.Context, tasks []domain.Task) (int64, error)
-	FindBySecToExecTime(ctx context.Context, preloadingTimeRange time.Duration) (CollectionsInterface, error)
+
+	/*
+		Creates the tasks in one transaction inserting many tasks by one statement. The collections are filled
+		up to MaxCountTasksInCollection. Returns the result of each task: nil, RepoErrorTaskExist
+		or RepoErrorIdempotencyKeyExist
+	*/
+	CreateBatch(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error)
+
+	/*
+		Deletes the tasks in one transaction. Returns the result of each task: nil or RepoErrorTaskNotFound
+	*/
+	DeleteBatch(ctx context.Context, taskIds []string) ([]error, error)
+
+	/*
+		Returns RepoErrorTaskNotFound if the task does not exist
+	*/
+	Get(ctx context.Context, taskId string) (domain.Task, error)
+
+	/*
+		Id of the task which has reserved the idempotency key.
+		Returns RepoErrorTaskNotFound if the key is not reserved
+	*/
+	GetIdByIdempotencyKey(ctx context.Context, idempotencyKey string) (string, error)
+
+	/*
+		Tasks in order of time of execution
+	*/
+	List(ctx context.Context, filter TaskFilter) ([]domain.Task, error)
+
+	/*
+		Moves the task to the collection of the time of execution of the given task in one transaction.
+		The other fields of the stored task are kept. Returns the stored task
+		or RepoErrorTaskNotFound if the task does not exist
+	*/
+	Reschedule(ctx context.Context, task domain.Task, isTaken bool) (domain.Task, error)
+
+	/*
+		Deletes the tasks and creates the next occurrences of them in one transaction.
+		The next occurrence is created only if the task with the same id was deleted.
+		Returns count of deleted and count of created tasks
+	*/
+	DeleteAndCreate(ctx context.Context, tasks []domain.Task, nextTasks []domain.Task) (int64, int64, error)
+
+	/*
+		Takes the collections of the queues. The collections of all queues are taken if queues are not specified
+	*/
+	FindBySecToExecTime(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (CollectionsInterface, error)
+
+	/*
+		Saves the count of attempts of the execution of the task
+	*/
+	UpdateAttempts(ctx context.Context, task domain.Task) error
+
+	/*
+		Deletes the task and saves it to the dead letter store in one transaction.
+		Returns RepoErrorTaskNotFound if the task does not exist
+	*/
+	MoveToDeadLetter(ctx context.Context, task domain.Task, reason string) error
+
+	FindDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error)
+
+	/*
+		Returns RepoErrorTaskNotFound if the task is not dead lettered
+	*/
+	GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error)
+
+	/*
+		Deletes the task from the dead letter store and creates it in one transaction.
+		Returns RepoErrorTaskNotFound if the task is not dead lettered
+	*/
+	Requeue(ctx context.Context, task domain.Task, isTaken bool) error
+
+	/*
+		Deletes the tasks dead lettered before the time (unix). Returns count of deleted tasks
+	*/
+	PurgeDeadLetters(ctx context.Context, deadLetteredBefore int64) (int64, error)
+
+	/*
+		Clears the instance which has taken the collections of the tasks
+	*/
+	Release(ctx context.Context, tasks []domain.Task) error
+
+	/*
+		Saves the time when the instance was alive. Collections of the instance which has not sent
+		the heartbeat in time are taken by other instances
+	*/
+	Heartbeat(ctx context.Context) error
+
+	/*
+		Releases all collections taken by the instance and deletes the instance from the registry
+	*/
+	Unregister(ctx context.Context) error
 	Up() error
 	Count() (int, error)
 }
@@ -71,6 +356,18 @@ var (
 	RepoErrorDeadlock        = errors.New("deadlock, please retry")
 	RepoErrorLockWaitTimeout = errors.New("lock wait timeout exceeded")
 	RepoErrorSchemaSetup     = errors.New("schema setup failed")
+	RepoErrorTaskNotFound    = errors.New("task not found")
+	RepoErrorUpdatingTask    = errors.New("updating the task was fail")
+	RepoErrorMovingTask      = errors.New("moving the task to dead letter was fail")
+	RepoErrorGettingDead     = errors.New("getting the dead lettered tasks were fail")
+	RepoErrorRequeueTask     = errors.New("requeue of the task was fail")
+	RepoErrorPurgingDead     = errors.New("purging the dead lettered tasks was fail")
+	RepoErrorReleasingTasks  = errors.New("releasing the tasks was fail")
+	RepoErrorHeartbeat       = errors.New("saving the heartbeat of the instance was fail")
+	RepoErrorUnregistering   = errors.New("unregistering the instance was fail")
+	RepoErrorRescheduling    = errors.New("rescheduling the task was fail")
+
+	RepoErrorIdempotencyKeyExist = errors.New("task with the idempotency key already exist")
 )
 
 /*	--------------------------------------------------
@@ -78,8 +375,29 @@ var (
 */
 type PreloadingServiceInterface interface {
 	AddNewTask(ctx context.Context, task *domain.Task) error
+
+	/*
+		Creates the tasks by batches of the taken and not taken tasks. Returns the result of each task
+	*/
+	AddNewTasks(ctx context.Context, tasks []domain.Task) ([]error, error)
+
+	/*
+		Creates the dead lettered task again
+	*/
+	RequeueDeadLetter(ctx context.Context, task *domain.Task) error
+
+	/*
+		Moves the task to the time of execution of the given task. The other fields of the given task
+		are filled from the stored task. Returns true if the task is taken by the instance
+	*/
+	Reschedule(ctx context.Context, task *domain.Task) (bool, error)
 	GetPreloadedChan() <-chan domain.Task
 	Run()
+
+	/*
+		Stops preloading. New tasks are not taken by the instance after stopping
+	*/
+	Stop(ctx context.Context) error
 }
 
 /*	--------------------------------------------------
@@ -87,8 +405,34 @@ type PreloadingServiceInterface interface {
 */
 type WaitingServiceInterface interface {
 	CancelIfExist(ctx context.Context, taskId string) error
-	GetReadyToSendChan() chan domain.Task
+
+	/*
+		Deletes the tasks and cancels the waiting ones. Returns the result of each task
+	*/
+	CancelBatch(ctx context.Context, taskIds []string) ([]error, error)
+
+	/*
+		Replaces the waiting task with the same id by the rescheduled task.
+		The waiting task is removed if the rescheduled task is not taken by the instance
+	*/
+	Reschedule(task domain.Task, isTaken bool)
+
+	/*
+		The tasks of the queue are sent to the channel at their time of execution.
+		The tasks of each queue wait separately, so the queue which is not consumed does not delay other queues
+	*/
+	GetReadyToSendChan(queue string) <-chan domain.Task
+
+	/*
+		The task sent to the channel is sent again at its time of execution
+	*/
+	GetDelayedChan() chan<- domain.Task
 	Run()
+
+	/*
+		Stops waiting and releases the waiting tasks, so they can be taken by other instances
+	*/
+	Stop(ctx context.Context) error
 }
 
 /*	--------------------------------------------------
@@ -157,6 +501,12 @@ const (
 		Summarizes the measured values. Does not depend on the measurement period
 	*/
 	IntegralMetricType
+
+	/*
+		Distribution of the values measured in a time period. Measurement of the event is the count of the values,
+		the distribution is in Histogram of the event
+	*/
+	HistogramMetricType
 )
 
 var (
@@ -168,6 +518,35 @@ type MeasurementEvent struct {
 	Measurement   int64
 	Time          time.Time
 	PeriodMeasure time.Duration
+
+	/*
+		Only for the histogram metric, nil for other types
+	*/
+	Histogram *HistogramSnapshot
+}
+
+/*
+	Distribution of the values measured in the time period
+*/
+type HistogramSnapshot struct {
+	/*
+		Count of the values less than or equal to the upper bound of the bucket. The values greater than
+		the upper bound of the last bucket are counted only in Count
+	*/
+	Buckets []HistogramBucket
+	Count   int64
+	Sum     int64
+	Max     int64
+
+	/*
+		Estimated values by the percentiles, for example 0.99 - the value which 99% of values do not exceed
+	*/
+	Percentiles map[float64]int64
+}
+
+type HistogramBucket struct {
+	UpperBound int64
+	Count      int64
 }
 
 type Topic string
@@ -188,10 +567,25 @@ type MonitoringInterface interface {
 	*/
 	Listen(topic Topic, callback func() int64) error
 
+	/*
+		Removing the topic. The listening to the topic is stopped
+	*/
+	Remove(topic Topic)
+
+	/*
+		Subscribing to the measurement events of the topic at runtime. The subscription is cancelled by the returned func
+	*/
+	Subscribe(topic Topic, callback func(event MeasurementEvent)) (cancel func())
+
 	/*
 		Launch monitoring
 	*/
 	Run()
+
+	/*
+		Stopping of the measurements, the listening and the subscriptions
+	*/
+	Stop()
 }
 
 /*	--------------------------------------------------
@@ -231,12 +625,74 @@ var (
 
 	SendingRate Topic = "sending_rate"
 
+	/*
+		Number of tasks that were not confirmed or rolled back in time per unit of time
+	*/
+	LeaseExpirationRate Topic = "lease_expiration_rate"
+
 	/*
 		Number of all tasks
 	*/
 	All Topic = "all"
+
+	/*
+		Number of tasks moved to the dead letter store per unit of time
+	*/
+	DeadLettered Topic = "dead_lettered"
+
+	/*
+		Histogram of the time in milliseconds between the time of execution of the task and the sending to the consumer
+	*/
+	Lateness Topic = "lateness"
+
+	/*
+		Histogram of the time in milliseconds between the sending of the task to the consumer and the confirmation
+	*/
+	ProcessingTime Topic = "processing_time"
+
+	/*
+		Number of measurement events dropped because the subscriber did not keep up per unit of time
+	*/
+	DroppedEvents Topic = "dropped_events"
 )
 
+/*
+	Topic of the measurement of the named queue, for example "queue_payments_sending_rate".
+	Preloaded and SendingRate are measured for each named queue in addition to the measurement of all queues.
+	The topics are initialized when the queue is used for the first time
+*/
+func QueueTopic(topic Topic, queue string) Topic {
+	if queue == "" {
+		return topic
+	}
+
+	return Topic("queue_" + queue + "_" + string(topic))
+}
+
+var ErrStopped = errors.New("trigger hook is stopped")
+
+/*
+	Executes the task. The task is confirmed if nil is returned and rolled back with the backoff otherwise
+*/
+type Handler func(ctx context.Context, task domain.Task) error
+
+type HandlerOptions struct {
+	/*
+		Count of the tasks executed at once, 10 by default
+	*/
+	WorkersCount int
+
+	/*
+		The context of the handler is cancelled after the timeout, 1 minute by default
+	*/
+	Timeout time.Duration
+
+	/*
+		Queue of the executed tasks, the default queue if not specified
+	*/
+	Queue string
+}
+
 type TriggerHookInterface interface {
 
 	// Deprecated
@@ -245,14 +701,132 @@ type TriggerHookInterface interface {
 	// Deprecated
 	Delete(taskId string) error
 
+	/*
+		Returns TmErrorIdempotencyKeyExist if the task with the same idempotency key exists or was executed
+		less than the retention ago, the id of that task is filled in the task
+	*/
 	CreateCtx(ctx context.Context, task *domain.Task) error
 
 	DeleteCtx(ctx context.Context, taskId string) error
 
+	/*
+		Creates many tasks at once, it is much faster than creating them one by one.
+		The ids and the times of execution are filled in the tasks. Returns the result of each task
+		in the order of the tasks: nil if the task is created, TmErrorTaskExist, TmErrorIdempotencyKeyExist
+		or the error of the validation. The error is returned if no task is created because of the failure
+	*/
+	CreateBatch(ctx context.Context, tasks []domain.Task) ([]error, error)
+
+	/*
+		Deletes many tasks at once. Returns the result of each task in the order of the ids:
+		nil if the task is deleted or TmErrorTaskNotFound
+	*/
+	DeleteBatch(ctx context.Context, taskIds []string) ([]error, error)
+
+	/*
+		Consumes the task of the default queue
+	*/
 	Consume() TaskToSendInterface
 
+	/*
+		Consumes the task of the named queue. The tasks of the queue are consumed only by ConsumeQueue
+		with the name of the queue. Blocks until the task is ready, returns nil after stopping
+	*/
+	ConsumeQueue(queue string) TaskToSendInterface
+
+	/*
+		Consumes the task of the default queue. Returns the error of ctx if ctx is done before the task is ready,
+		ErrStopped after stopping
+	*/
+	ConsumeCtx(ctx context.Context) (TaskToSendInterface, error)
+
+	/*
+		Consumes the task of the named queue, the errors are the same as of ConsumeCtx
+	*/
+	ConsumeQueueCtx(ctx context.Context, queue string) (TaskToSendInterface, error)
+
+	/*
+		Consumes from 1 to max ready tasks of the default queue at once. Blocks until the first task is ready,
+		the errors are the same as of ConsumeCtx
+	*/
+	ConsumeBatch(ctx context.Context, max int) ([]TaskToSendInterface, error)
+
+	/*
+		Consumes from 1 to max ready tasks of the named queue at once, the same as ConsumeBatch
+	*/
+	ConsumeQueueBatch(ctx context.Context, queue string, max int) ([]TaskToSendInterface, error)
+
+	/*
+		Executes the tasks of the queue by the handler in the workers until stopping. The panic of the handler
+		is reported to the event handler and the task is rolled back. Stop waits for the tasks being executed.
+		Returns ErrStopped after stopping
+	*/
+	Handle(handler Handler, options *HandlerOptions) error
+
+	/*
+		Returns TmErrorTaskNotFound if the task does not exist. The task which is sent and waits
+		for the confirmation exists too
+	*/
+	Get(ctx context.Context, taskId string) (domain.Task, error)
+
+	/*
+		Tasks in order of time of execution
+	*/
+	List(ctx context.Context, filter TaskFilter) ([]domain.Task, error)
+
+	/*
+		Moves the task to the new time of execution in one transaction. The task waiting in the memory
+		of the instance is replaced or removed. The task which is sent and waits for the confirmation is not changed.
+		Returns TmErrorTaskNotFound if the task does not exist
+	*/
+	Reschedule(ctx context.Context, taskId string, execTime time.Time) error
+
+	/*
+		Dead lettered tasks in order of time of dead lettering
+	*/
+	GetDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error)
+
+	GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error)
+
+	/*
+		Creates the dead lettered task again with the new time of execution. The count of attempts is reset
+	*/
+	RequeueDeadLetter(ctx context.Context, taskId string, execTime time.Time) error
+
+	/*
+		Deletes the tasks dead lettered before the time. Returns count of deleted tasks
+	*/
+	PurgeDeadLetters(ctx context.Context, before time.Time) (int64, error)
+
+	/*
+		Subscribing to the measurement events of the topic of the monitoring at runtime.
+		The subscription is cancelled by the returned func
+	*/
+	Subscribe(topic Topic, callback func(event MeasurementEvent)) (cancel func())
+
 	/*
 		LAUNCHER TRIGGER HOOK :) !!!
+		Returns nil after stopping
 	*/
 	Run() error
+
+	/*
+		Stops preloading, waits for the tasks being executed by the handlers, then waits for the other
+		sent tasks to be confirmed or rolled back, flushes the confirmations and releases the tasks
+		waiting in the memory of the instance.
+		Consume returns nil after stopping. Returns the error of ctx if the deadline is exceeded
+	*/
+	Stop(ctx context.Context) error
+}
+
+/*	--------------------------------------------------
+	Webhook service
+*/
+
+type WebhookServiceInterface interface {
+	/*
+		Sends the requests of the tasks consumed from the trigger hook.
+		Returns when the trigger hook is stopped and the requests in progress are finished
+	*/
+	Run()
 }
@@ -1,6 +1,75 @@
 package domain
 
 type Task struct {
-	Id       string `json:"id"`        //Uuid of the task. If not specified it will be created automatically
-	ExecTime int64  `json:"exec_time"` //Time of execution of the task. Required parameter
+	Id             string            `json:"id"`                        //Uuid of the task. If not specified it will be created automatically
+	ExecTime       int64             `json:"exec_time"`                 //Time of execution of the task. Required parameter if ExecTimeMs is not specified
+	ExecTimeMs     int64             `json:"exec_time_ms,omitempty"`    //Time of execution of the task in milliseconds. If specified ExecTime is filled automatically. Ignored if it is not in the second of ExecTime
+	Payload        []byte            `json:"payload,omitempty"`         //Arbitrary data of the task. It is returned to the consumer as is
+	Headers        map[string]string `json:"headers,omitempty"`         //Arbitrary metadata of the task. It is returned to the consumer as is
+	Recurrence     *Recurrence       `json:"recurrence,omitempty"`      //Schedule of the repetition of the task. If not specified the task is executed once
+	Attempts       int               `json:"attempts,omitempty"`        //Count of the failed attempts of the execution. Filled automatically
+	Queue          string            `json:"queue,omitempty"`           //Name of the queue of the task. The tasks of the queue are consumed separately. The default queue if not specified
+	Priority       int               `json:"priority,omitempty"`        //Among the tasks with the same time of execution the tasks with the higher priority are sent first. 0 by default
+	IdempotencyKey string            `json:"idempotency_key,omitempty"` //The task is not created if the task with the same key exists or was executed less than the retention ago
+}
+
+/*
+	Time of execution of the task in milliseconds. The time in milliseconds specifies the time within the second
+	of ExecTime, so it is used if ExecTime is not specified or ExecTime is not changed after it.
+	Otherwise the time in seconds is used
+*/
+func (t *Task) ExecTimeInMs() int64 {
+	if t.ExecTimeMs != 0 && (t.ExecTime == 0 || t.ExecTime == t.ExecTimeMs/1000) {
+		return t.ExecTimeMs
+	}
+
+	return t.ExecTime * 1000
+}
+
+/*
+	Sets the time of execution in milliseconds and the second which the time belongs to
+*/
+func (t *Task) SetExecTimeMs(execTimeMs int64) {
+	t.ExecTimeMs = execTimeMs
+	t.ExecTime = execTimeMs / 1000
+}
+
+/*
+	Moves the time of execution of the task forward. The recurrent task keeps the time of its occurrence
+*/
+func (t *Task) Delay(execTimeMs int64) {
+	if t.Recurrence != nil && t.Recurrence.ScheduledTimeMs == 0 {
+		recurrence := *t.Recurrence
+		recurrence.ScheduledTimeMs = t.ExecTimeInMs()
+		t.Recurrence = &recurrence
+	}
+	t.SetExecTimeMs(execTimeMs)
+}
+
+/*
+	After the confirmation of the execution the task is created again with the same id
+	and the time of the next occurrence. Either Cron or Interval must be specified.
+*/
+type Recurrence struct {
+	Cron           string `json:"cron,omitempty"`            //Cron expression with 5 fields or a descriptor, for example "0 3 * * *" or "@daily"
+	Interval       int64  `json:"interval,omitempty"`        //Fixed interval between occurrences in seconds
+	Location       string `json:"location,omitempty"`        //IANA time zone of the cron expression, for example "Europe/Berlin". UTC by default
+	EndTime        int64  `json:"end_time,omitempty"`        //Time after which the task is not repeated. 0 - without limit
+	MaxOccurrences int    `json:"max_occurrences,omitempty"` //Max count of executions of the task. 0 - without limit
+	Occurrence     int    `json:"occurrence,omitempty"`      //Count of the executions before the current occurrence. Filled automatically
+
+	/*
+		Time of the current occurrence in milliseconds while the task is delayed by the rollback,
+		so the next occurrence is calculated by the schedule. It is kept in the memory only
+	*/
+	ScheduledTimeMs int64 `json:"-"`
+}
+
+/*
+	The task which is not executed anymore, for example because it has used all attempts of the execution
+*/
+type DeadLetter struct {
+	Task           Task   `json:"task"`
+	Reason         string `json:"reason"`           //Why the task was dead lettered
+	DeadLetteredAt int64  `json:"dead_lettered_at"` //Time when the task was dead lettered
 }
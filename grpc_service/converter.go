@@ -0,0 +1,89 @@
+package grpc_service
+
+import (
+	"time"
+
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/grpc_service/pb"
+)
+
+func toDomainTask(task *pb.Task) domain.Task {
+	result := domain.Task{
+		Id:             task.Id,
+		ExecTime:       task.ExecTime,
+		ExecTimeMs:     task.ExecTimeMs,
+		Payload:        task.Payload,
+		Headers:        task.Headers,
+		Attempts:       int(task.Attempts),
+		Queue:          task.Queue,
+		Priority:       int(task.Priority),
+		IdempotencyKey: task.IdempotencyKey,
+	}
+
+	if r := task.Recurrence; r != nil {
+		result.Recurrence = &domain.Recurrence{
+			Cron:           r.Cron,
+			Interval:       r.Interval,
+			Location:       r.Location,
+			EndTime:        r.EndTime,
+			MaxOccurrences: int(r.MaxOccurrences),
+			Occurrence:     int(r.Occurrence),
+		}
+	}
+
+	return result
+}
+
+func fromDomainTask(task domain.Task) *pb.Task {
+	result := &pb.Task{
+		Id:             task.Id,
+		ExecTime:       task.ExecTime,
+		ExecTimeMs:     task.ExecTimeMs,
+		Payload:        task.Payload,
+		Headers:        task.Headers,
+		Attempts:       int32(task.Attempts),
+		Queue:          task.Queue,
+		Priority:       int32(task.Priority),
+		IdempotencyKey: task.IdempotencyKey,
+	}
+
+	if r := task.Recurrence; r != nil {
+		result.Recurrence = &pb.Recurrence{
+			Cron:           r.Cron,
+			Interval:       r.Interval,
+			Location:       r.Location,
+			EndTime:        r.EndTime,
+			MaxOccurrences: int32(r.MaxOccurrences),
+			Occurrence:     int32(r.Occurrence),
+		}
+	}
+
+	return result
+}
+
+func toTaskFilter(request *pb.ListRequest) contracts.TaskFilter {
+	filter := contracts.TaskFilter{
+		TakenBy: request.TakenBy,
+		Limit:   int(request.Limit),
+		Offset:  int(request.Offset),
+	}
+
+	if request.ExecTimeFrom > 0 {
+		filter.ExecTimeFrom = time.Unix(request.ExecTimeFrom, 0)
+	}
+	if request.ExecTimeTo > 0 {
+		filter.ExecTimeTo = time.Unix(request.ExecTimeTo, 0)
+	}
+
+	switch request.Ownership {
+	case pb.ListRequest_TAKEN:
+		taken := true
+		filter.Taken = &taken
+	case pb.ListRequest_NOT_TAKEN:
+		taken := false
+		filter.Taken = &taken
+	}
+
+	return filter
+}
@@ -0,0 +1,331 @@
+package grpc_service
+
+//go:generate goprotoc --go_out=paths=source_relative:pb --go-grpc_out=paths=source_relative:pb triggerhook.proto
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/grpc_service/pb"
+	"github.com/pvelx/triggerhook/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+	gRPC server of the trigger hook. It is registered by pb.RegisterTriggerHookServer
+*/
+func New(triggerHook contracts.TriggerHookInterface) pb.TriggerHookServer {
+	return &grpcService{
+		triggerHook: triggerHook,
+		queues:      make(map[string]chan chan contracts.TaskToSendInterface),
+	}
+}
+
+type grpcService struct {
+	pb.UnimplementedTriggerHookServer
+	triggerHook contracts.TriggerHookInterface
+
+	/*
+		Requests of the tasks by the queue. Consume blocks until a task is ready, so it is called
+		by the only goroutine for all streams of the queue and only when a stream has credits.
+		Otherwise the consumed task would wait for a stream and would prevent the trigger hook from stopping
+	*/
+	queuesMu sync.Mutex
+	queues   map[string]chan chan contracts.TaskToSendInterface
+}
+
+func (s *grpcService) Create(ctx context.Context, request *pb.CreateRequest) (*pb.CreateResponse, error) {
+	if request.Task == nil {
+		return nil, status.Error(codes.InvalidArgument, "task is required")
+	}
+
+	task := toDomainTask(request.Task)
+	err := s.triggerHook.CreateCtx(ctx, &task)
+	if err != nil && err != contracts.TmErrorIdempotencyKeyExist {
+		return nil, toStatus(err)
+	}
+
+	return &pb.CreateResponse{Task: fromDomainTask(task), Existing: err != nil}, nil
+}
+
+func (s *grpcService) Delete(ctx context.Context, request *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	if err := s.triggerHook.DeleteCtx(ctx, request.Id); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.DeleteResponse{}, nil
+}
+
+func (s *grpcService) Get(ctx context.Context, request *pb.GetRequest) (*pb.GetResponse, error) {
+	task, err := s.triggerHook.Get(ctx, request.Id)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &pb.GetResponse{Task: fromDomainTask(task)}, nil
+}
+
+func (s *grpcService) List(ctx context.Context, request *pb.ListRequest) (*pb.ListResponse, error) {
+	tasks, err := s.triggerHook.List(ctx, toTaskFilter(request))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &pb.ListResponse{Tasks: make([]*pb.Task, 0, len(tasks))}
+	for _, task := range tasks {
+		response.Tasks = append(response.Tasks, fromDomainTask(task))
+	}
+
+	return response, nil
+}
+
+func (s *grpcService) Consume(stream pb.TriggerHook_ConsumeServer) error {
+	c := &consumer{
+		deliveries:  make(map[string]contracts.TaskToSendInterface),
+		creditAdded: make(chan struct{}, 1),
+	}
+	defer c.rollbackAll()
+
+	/*
+		The first request specifies the queue of the stream
+	*/
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.handle(first); err != nil {
+		return err
+	}
+	requests := s.requests(first.Queue)
+
+	received := make(chan error, 1)
+	go func() {
+		received <- c.receive(stream)
+	}()
+
+	for {
+		if !c.takeCredit() {
+			select {
+			case <-c.creditAdded:
+				continue
+			case err := <-received:
+				return err
+			}
+		}
+
+		request := make(chan contracts.TaskToSendInterface, 1)
+		select {
+		case requests <- request:
+		case err := <-received:
+			return err
+		}
+
+		var task contracts.TaskToSendInterface
+		select {
+		case task = <-request:
+		case err := <-received:
+			/*
+				The task consumed for the stream which is ended is sent again
+			*/
+			go func() {
+				if task := <-request; task != nil {
+					task.Rollback()
+				}
+			}()
+			return err
+		}
+
+		if task == nil {
+			return status.Error(codes.Unavailable, contracts.ErrStopped.Error())
+		}
+
+		deliveryId := util.NewId()
+		c.add(deliveryId, task)
+
+		if err := stream.Send(&pb.Delivery{
+			DeliveryId:   deliveryId,
+			Task:         fromDomainTask(task.Task()),
+			Redeliveries: int32(task.Redeliveries()),
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+/*
+	Returns the requests of the tasks of the queue. The goroutine consuming the queue is started on the first call
+*/
+func (s *grpcService) requests(queue string) chan chan contracts.TaskToSendInterface {
+	s.queuesMu.Lock()
+	defer s.queuesMu.Unlock()
+
+	requests, ok := s.queues[queue]
+	if !ok {
+		requests = make(chan chan contracts.TaskToSendInterface)
+		s.queues[queue] = requests
+		go s.consume(queue, requests)
+	}
+
+	return requests
+}
+
+func (s *grpcService) consume(queue string, requests chan chan contracts.TaskToSendInterface) {
+	for request := range requests {
+		request <- s.triggerHook.ConsumeQueue(queue)
+	}
+}
+
+/*
+	State of the stream of the consumer
+*/
+type consumer struct {
+	sync.Mutex
+	credits     uint64
+	creditAdded chan struct{}
+
+	/*
+		Sent tasks which are not confirmed or rolled back yet by the id of the delivery
+	*/
+	deliveries map[string]contracts.TaskToSendInterface
+}
+
+/*
+	Handles the messages of the client until the client closes the stream
+*/
+func (c *consumer) receive(stream pb.TriggerHook_ConsumeServer) error {
+	for {
+		request, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := c.handle(request); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *consumer) handle(request *pb.ConsumeRequest) error {
+	switch r := request.Request.(type) {
+	case *pb.ConsumeRequest_Credits:
+		c.addCredits(r.Credits.Count)
+	case *pb.ConsumeRequest_Confirm:
+		/*
+			Unknown deliveries are ignored, they may be already confirmed or rolled back
+		*/
+		if task := c.take(r.Confirm.DeliveryId); task != nil {
+			task.Confirm()
+		}
+	case *pb.ConsumeRequest_Rollback:
+		task := c.take(r.Rollback.DeliveryId)
+		switch {
+		case task == nil:
+		case r.Rollback.Backoff:
+			task.RollbackWithBackoff()
+		case r.Rollback.DelayMs > 0:
+			task.RollbackWithDelay(time.Duration(r.Rollback.DelayMs) * time.Millisecond)
+		default:
+			task.Rollback()
+		}
+	case nil:
+		/*
+			The first request may only specify the queue
+		*/
+		if request.Queue == "" {
+			return status.Error(codes.InvalidArgument, "request is empty")
+		}
+	}
+
+	return nil
+}
+
+func (c *consumer) addCredits(count uint32) {
+	c.Lock()
+	c.credits += uint64(count)
+	c.Unlock()
+
+	select {
+	case c.creditAdded <- struct{}{}:
+	default:
+	}
+}
+
+func (c *consumer) takeCredit() bool {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.credits == 0 {
+		return false
+	}
+	c.credits--
+
+	return true
+}
+
+func (c *consumer) add(deliveryId string, task contracts.TaskToSendInterface) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.deliveries[deliveryId] = task
+}
+
+/*
+	Removes the delivery, so it is confirmed or rolled back only once
+*/
+func (c *consumer) take(deliveryId string) contracts.TaskToSendInterface {
+	c.Lock()
+	defer c.Unlock()
+
+	task, ok := c.deliveries[deliveryId]
+	if !ok {
+		return nil
+	}
+	delete(c.deliveries, deliveryId)
+
+	return task
+}
+
+/*
+	The deliveries which are not finished when the stream ends are sent again
+*/
+func (c *consumer) rollbackAll() {
+	c.Lock()
+	deliveries := c.deliveries
+	c.deliveries = make(map[string]contracts.TaskToSendInterface)
+	c.Unlock()
+
+	for _, task := range deliveries {
+		task.Rollback()
+	}
+}
+
+func toStatus(err error) error {
+	switch err {
+	case contracts.TmErrorTaskExist:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case contracts.TmErrorTaskNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case contracts.TmErrorUuidIsNotCorrect,
+		contracts.TmErrorRecurrenceIsNotCorrect,
+		contracts.TmErrorPayloadTooLarge,
+		contracts.TmErrorHeadersTooLarge,
+		contracts.TmErrorQueueIsNotCorrect,
+		contracts.TmErrorPriorityIsNotCorrect,
+		contracts.TmErrorIdempotencyKeyIsNotCorrect:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case contracts.ErrStopped:
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,404 @@
+package grpc_service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/grpc_service/pb"
+	"github.com/pvelx/triggerhook/util"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type triggerHookMock struct {
+	contracts.TriggerHookInterface
+	CreateCtxMock    func(ctx context.Context, task *domain.Task) error
+	DeleteCtxMock    func(ctx context.Context, taskId string) error
+	GetMock          func(ctx context.Context, taskId string) (domain.Task, error)
+	ListMock         func(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error)
+	ConsumeQueueMock func(queue string) contracts.TaskToSendInterface
+}
+
+func (t *triggerHookMock) CreateCtx(ctx context.Context, task *domain.Task) error {
+	return t.CreateCtxMock(ctx, task)
+}
+
+func (t *triggerHookMock) DeleteCtx(ctx context.Context, taskId string) error {
+	return t.DeleteCtxMock(ctx, taskId)
+}
+
+func (t *triggerHookMock) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	return t.GetMock(ctx, taskId)
+}
+
+func (t *triggerHookMock) List(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+	return t.ListMock(ctx, filter)
+}
+
+func (t *triggerHookMock) ConsumeQueue(queue string) contracts.TaskToSendInterface {
+	return t.ConsumeQueueMock(queue)
+}
+
+type taskToSendMock struct {
+	contracts.TaskToSendInterface
+	task   domain.Task
+	result chan string
+}
+
+func newTaskToSendMock() *taskToSendMock {
+	return &taskToSendMock{
+		task:   domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix(), Payload: []byte("payload")},
+		result: make(chan string, 1),
+	}
+}
+
+func (t *taskToSendMock) Confirm() {
+	t.result <- "confirm"
+}
+
+func (t *taskToSendMock) Rollback() {
+	t.result <- "rollback"
+}
+
+func (t *taskToSendMock) RollbackWithDelay(delay time.Duration) {
+	t.result <- "rollback " + delay.String()
+}
+
+func (t *taskToSendMock) RollbackWithBackoff() {
+	t.result <- "rollback with backoff"
+}
+
+func (t *taskToSendMock) Task() domain.Task {
+	return t.task
+}
+
+func (t *taskToSendMock) Redeliveries() int {
+	return 1
+}
+
+/*
+	Consume returns the tasks of the default queue from the channel and blocks when there are no tasks
+*/
+func consumeFrom(tasks chan contracts.TaskToSendInterface) func(queue string) contracts.TaskToSendInterface {
+	return consumeQueues(map[string]chan contracts.TaskToSendInterface{"": tasks})
+}
+
+/*
+	Consume returns the tasks of the queue from its channel and blocks when there are no tasks
+*/
+func consumeQueues(queues map[string]chan contracts.TaskToSendInterface) func(queue string) contracts.TaskToSendInterface {
+	return func(queue string) contracts.TaskToSendInterface {
+		task, ok := <-queues[queue]
+		if !ok {
+			return nil
+		}
+		return task
+	}
+}
+
+/*
+	Starts the server on the in-process listener and returns the client connected to it
+*/
+func newClient(t *testing.T, triggerHook contracts.TriggerHookInterface) pb.TriggerHookClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	pb.RegisterTriggerHookServer(server, New(triggerHook))
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+
+	return pb.NewTriggerHookClient(conn)
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode codes.Code
+	}{
+		{"created", nil, codes.OK},
+		{"exist", contracts.TmErrorTaskExist, codes.AlreadyExists},
+		{"incorrect uuid", contracts.TmErrorUuidIsNotCorrect, codes.InvalidArgument},
+		{"large payload", contracts.TmErrorPayloadTooLarge, codes.InvalidArgument},
+		{"incorrect priority", contracts.TmErrorPriorityIsNotCorrect, codes.InvalidArgument},
+		{"incorrect idempotency key", contracts.TmErrorIdempotencyKeyIsNotCorrect, codes.InvalidArgument},
+		{"internal error", contracts.TmErrorCreatingTasks, codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var createdTask domain.Task
+			client := newClient(t, &triggerHookMock{CreateCtxMock: func(ctx context.Context, task *domain.Task) error {
+				createdTask = *task
+				task.Id = "the id"
+				return tt.err
+			}})
+
+			task := domain.Task{
+				ExecTime:   100,
+				Payload:    []byte("payload"),
+				Headers:    map[string]string{"key": "value"},
+				Recurrence: &domain.Recurrence{Cron: "@daily", Location: "UTC", MaxOccurrences: 3},
+				Queue:      "emails",
+				Priority:   10,
+			}
+			response, err := client.Create(context.Background(), &pb.CreateRequest{Task: fromDomainTask(task)})
+
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			assert.Equal(t, task, createdTask)
+			if tt.err == nil {
+				task.Id = "the id"
+				assert.Equal(t, task, toDomainTask(response.Task))
+			}
+		})
+	}
+
+	client := newClient(t, &triggerHookMock{})
+	_, err := client.Create(context.Background(), &pb.CreateRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "the task is required")
+}
+
+func TestCreateWithIdempotencyKey(t *testing.T) {
+	client := newClient(t, &triggerHookMock{CreateCtxMock: func(ctx context.Context, task *domain.Task) error {
+		if task.IdempotencyKey == "order-1" {
+			task.Id = "the existing id"
+			return contracts.TmErrorIdempotencyKeyExist
+		}
+		task.Id = "the id"
+		return nil
+	}})
+
+	task := domain.Task{ExecTime: 100, IdempotencyKey: "order-1"}
+	response, err := client.Create(context.Background(), &pb.CreateRequest{Task: fromDomainTask(task)})
+	assert.NoError(t, err)
+	assert.True(t, response.Existing, "the existing task must be returned")
+	task.Id = "the existing id"
+	assert.Equal(t, task, toDomainTask(response.Task))
+
+	task = domain.Task{ExecTime: 100, IdempotencyKey: "order-2"}
+	response, err = client.Create(context.Background(), &pb.CreateRequest{Task: fromDomainTask(task)})
+	assert.NoError(t, err)
+	assert.False(t, response.Existing)
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode codes.Code
+	}{
+		{"deleted", nil, codes.OK},
+		{"not found", contracts.TmErrorTaskNotFound, codes.NotFound},
+		{"incorrect uuid", contracts.TmErrorUuidIsNotCorrect, codes.InvalidArgument},
+		{"stopped", contracts.ErrStopped, codes.Unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deletedTaskId string
+			client := newClient(t, &triggerHookMock{DeleteCtxMock: func(ctx context.Context, taskId string) error {
+				deletedTaskId = taskId
+				return tt.err
+			}})
+
+			_, err := client.Delete(context.Background(), &pb.DeleteRequest{Id: "the id"})
+
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			assert.Equal(t, "the id", deletedTaskId)
+		})
+	}
+}
+
+func TestConsume(t *testing.T) {
+	tasks := make(chan contracts.TaskToSendInterface, 3)
+	client := newClient(t, &triggerHookMock{ConsumeQueueMock: consumeFrom(tasks)})
+
+	stream, err := client.Consume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries := make(chan *pb.Delivery, 3)
+	go func() {
+		for {
+			delivery, err := stream.Recv()
+			if err != nil {
+				close(deliveries)
+				return
+			}
+			deliveries <- delivery
+		}
+	}()
+
+	confirmed, rolledBack, notFinished := newTaskToSendMock(), newTaskToSendMock(), newTaskToSendMock()
+	tasks <- confirmed
+	tasks <- rolledBack
+	tasks <- notFinished
+
+	select {
+	case <-deliveries:
+		t.Fatal("the task must not be sent without credits")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, stream.Send(&pb.ConsumeRequest{Request: &pb.ConsumeRequest_Credits{Credits: &pb.Credits{Count: 2}}}))
+
+	delivery := <-deliveries
+	assert.Equal(t, confirmed.task, toDomainTask(delivery.Task))
+	assert.Equal(t, int32(1), delivery.Redeliveries)
+	assert.NoError(t, stream.Send(&pb.ConsumeRequest{Request: &pb.ConsumeRequest_Confirm{
+		Confirm: &pb.Confirm{DeliveryId: delivery.DeliveryId},
+	}}))
+	assert.Equal(t, "confirm", <-confirmed.result)
+
+	delivery = <-deliveries
+	assert.Equal(t, rolledBack.task.Id, delivery.Task.Id)
+	assert.NoError(t, stream.Send(&pb.ConsumeRequest{Request: &pb.ConsumeRequest_Rollback{
+		Rollback: &pb.Rollback{DeliveryId: delivery.DeliveryId, DelayMs: 1500},
+	}}))
+	assert.Equal(t, "rollback 1.5s", <-rolledBack.result)
+
+	select {
+	case <-deliveries:
+		t.Fatal("the credits are used up")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, stream.Send(&pb.ConsumeRequest{Request: &pb.ConsumeRequest_Credits{Credits: &pb.Credits{Count: 1}}}))
+	delivery = <-deliveries
+	assert.Equal(t, notFinished.task.Id, delivery.Task.Id)
+
+	assert.NoError(t, stream.CloseSend())
+	assert.Equal(t, "rollback", <-notFinished.result, "the delivery which is not finished must be rolled back")
+	_, ok := <-deliveries
+	assert.False(t, ok, "the stream must be ended")
+}
+
+func TestConsumeStopped(t *testing.T) {
+	tasks := make(chan contracts.TaskToSendInterface)
+	close(tasks)
+	client := newClient(t, &triggerHookMock{ConsumeQueueMock: consumeFrom(tasks)})
+
+	stream, err := client.Consume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, stream.Send(&pb.ConsumeRequest{Request: &pb.ConsumeRequest_Credits{Credits: &pb.Credits{Count: 1}}}))
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestConsumeQueue(t *testing.T) {
+	defaultTasks, emailTasks := make(chan contracts.TaskToSendInterface, 1), make(chan contracts.TaskToSendInterface, 1)
+	client := newClient(t, &triggerHookMock{ConsumeQueueMock: consumeQueues(map[string]chan contracts.TaskToSendInterface{
+		"":      defaultTasks,
+		"email": emailTasks,
+	})})
+
+	defaultTask, emailTask := newTaskToSendMock(), newTaskToSendMock()
+	defaultTasks <- defaultTask
+	emailTasks <- emailTask
+
+	stream, err := client.Consume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, stream.Send(&pb.ConsumeRequest{
+		Queue:   "email",
+		Request: &pb.ConsumeRequest_Credits{Credits: &pb.Credits{Count: 2}},
+	}))
+
+	delivery, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, emailTask.task.Id, delivery.Task.Id, "the task of the queue of the stream must be sent")
+
+	defaultStream, err := client.Consume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, defaultStream.Send(&pb.ConsumeRequest{Request: &pb.ConsumeRequest_Credits{Credits: &pb.Credits{Count: 1}}}))
+
+	delivery, err = defaultStream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, defaultTask.task.Id, delivery.Task.Id, "the task of the default queue must not be sent to the stream of the other queue")
+
+	assert.NoError(t, stream.CloseSend())
+	assert.Equal(t, "rollback", <-emailTask.result)
+}
+
+func TestGet(t *testing.T) {
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix(), Payload: []byte("payload")}
+	client := newClient(t, &triggerHookMock{GetMock: func(ctx context.Context, taskId string) (domain.Task, error) {
+		if taskId != task.Id {
+			return domain.Task{}, contracts.TmErrorTaskNotFound
+		}
+		return task, nil
+	}})
+
+	response, err := client.Get(context.Background(), &pb.GetRequest{Id: task.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, task, toDomainTask(response.Task))
+
+	_, err = client.Get(context.Background(), &pb.GetRequest{Id: util.NewId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestList(t *testing.T) {
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix()}
+	var actualFilter contracts.TaskFilter
+	client := newClient(t, &triggerHookMock{ListMock: func(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+		actualFilter = filter
+		return []domain.Task{task}, nil
+	}})
+
+	response, err := client.List(context.Background(), &pb.ListRequest{
+		ExecTimeFrom: 10,
+		ExecTimeTo:   20,
+		Ownership:    pb.ListRequest_NOT_TAKEN,
+		Limit:        5,
+		Offset:       15,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, response.Tasks, 1)
+	assert.Equal(t, task, toDomainTask(response.Tasks[0]))
+
+	taken := false
+	assert.Equal(t, contracts.TaskFilter{
+		ExecTimeFrom: time.Unix(10, 0),
+		ExecTimeTo:   time.Unix(20, 0),
+		Taken:        &taken,
+		Limit:        5,
+		Offset:       15,
+	}, actualFilter)
+}
@@ -0,0 +1,288 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// TriggerHookClient is the client API for TriggerHook service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TriggerHookClient interface {
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	// Tasks in order of time of execution. The fields of the request which are not specified do not filter the tasks.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// The server sends the tasks of the queue ready to execute while the client has credits.
+	// The queue is specified by the first request of the stream.
+	// Each delivery takes one credit. The client confirms or rolls back the delivery by its id.
+	// The deliveries which are not finished when the stream ends are rolled back.
+	Consume(ctx context.Context, opts ...grpc.CallOption) (TriggerHook_ConsumeClient, error)
+}
+
+type triggerHookClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTriggerHookClient(cc grpc.ClientConnInterface) TriggerHookClient {
+	return &triggerHookClient{cc}
+}
+
+func (c *triggerHookClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*CreateResponse, error) {
+	out := new(CreateResponse)
+	err := c.cc.Invoke(ctx, "/triggerhook.TriggerHook/Create", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *triggerHookClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/triggerhook.TriggerHook/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *triggerHookClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, "/triggerhook.TriggerHook/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *triggerHookClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/triggerhook.TriggerHook/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *triggerHookClient) Consume(ctx context.Context, opts ...grpc.CallOption) (TriggerHook_ConsumeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TriggerHook_ServiceDesc.Streams[0], "/triggerhook.TriggerHook/Consume", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &triggerHookConsumeClient{stream}
+	return x, nil
+}
+
+type TriggerHook_ConsumeClient interface {
+	Send(*ConsumeRequest) error
+	Recv() (*Delivery, error)
+	grpc.ClientStream
+}
+
+type triggerHookConsumeClient struct {
+	grpc.ClientStream
+}
+
+func (x *triggerHookConsumeClient) Send(m *ConsumeRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *triggerHookConsumeClient) Recv() (*Delivery, error) {
+	m := new(Delivery)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TriggerHookServer is the server API for TriggerHook service.
+// All implementations must embed UnimplementedTriggerHookServer
+// for forward compatibility
+type TriggerHookServer interface {
+	Create(context.Context, *CreateRequest) (*CreateResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	// Tasks in order of time of execution. The fields of the request which are not specified do not filter the tasks.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// The server sends the tasks of the queue ready to execute while the client has credits.
+	// The queue is specified by the first request of the stream.
+	// Each delivery takes one credit. The client confirms or rolls back the delivery by its id.
+	// The deliveries which are not finished when the stream ends are rolled back.
+	Consume(TriggerHook_ConsumeServer) error
+	mustEmbedUnimplementedTriggerHookServer()
+}
+
+// UnimplementedTriggerHookServer must be embedded to have forward compatible implementations.
+type UnimplementedTriggerHookServer struct {
+}
+
+func (UnimplementedTriggerHookServer) Create(context.Context, *CreateRequest) (*CreateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedTriggerHookServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedTriggerHookServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedTriggerHookServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedTriggerHookServer) Consume(TriggerHook_ConsumeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Consume not implemented")
+}
+func (UnimplementedTriggerHookServer) mustEmbedUnimplementedTriggerHookServer() {}
+
+// UnsafeTriggerHookServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TriggerHookServer will
+// result in compilation errors.
+type UnsafeTriggerHookServer interface {
+	mustEmbedUnimplementedTriggerHookServer()
+}
+
+func RegisterTriggerHookServer(s grpc.ServiceRegistrar, srv TriggerHookServer) {
+	s.RegisterService(&TriggerHook_ServiceDesc, srv)
+}
+
+func _TriggerHook_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TriggerHookServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/triggerhook.TriggerHook/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TriggerHookServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TriggerHook_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TriggerHookServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/triggerhook.TriggerHook/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TriggerHookServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TriggerHook_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TriggerHookServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/triggerhook.TriggerHook/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TriggerHookServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TriggerHook_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TriggerHookServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/triggerhook.TriggerHook/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TriggerHookServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TriggerHook_Consume_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TriggerHookServer).Consume(&triggerHookConsumeServer{stream})
+}
+
+type TriggerHook_ConsumeServer interface {
+	Send(*Delivery) error
+	Recv() (*ConsumeRequest, error)
+	grpc.ServerStream
+}
+
+type triggerHookConsumeServer struct {
+	grpc.ServerStream
+}
+
+func (x *triggerHookConsumeServer) Send(m *Delivery) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *triggerHookConsumeServer) Recv() (*ConsumeRequest, error) {
+	m := new(ConsumeRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TriggerHook_ServiceDesc is the grpc.ServiceDesc for TriggerHook service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TriggerHook_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "triggerhook.TriggerHook",
+	HandlerType: (*TriggerHookServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Create",
+			Handler:    _TriggerHook_Create_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _TriggerHook_Delete_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _TriggerHook_Get_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _TriggerHook_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Consume",
+			Handler:       _TriggerHook_Consume_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "triggerhook.proto",
+}
@@ -0,0 +1,96 @@
+package triggerhook
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/imdario/mergo"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+func (s *triggerHook) Handle(handler contracts.Handler, options *contracts.HandlerOptions) error {
+	if options == nil {
+		options = &contracts.HandlerOptions{}
+	}
+
+	if err := mergo.Merge(options, contracts.HandlerOptions{
+		WorkersCount: 10,
+		Timeout:      time.Minute,
+	}); err != nil {
+		panic(err)
+	}
+
+	//	The workers are not added after stopping, so Stop waits for all of them
+	s.Lock()
+	defer s.Unlock()
+
+	if s.isStopped {
+		return contracts.ErrStopped
+	}
+
+	for worker := 0; worker < options.WorkersCount; worker++ {
+		s.handlers.Add(1)
+		go func() {
+			defer s.handlers.Done()
+			for {
+				taskToSend, err := s.ConsumeQueueCtx(s.handlersCtx, options.Queue)
+				if err != nil {
+					return
+				}
+				s.execute(handler, taskToSend, options.Timeout)
+			}
+		}()
+	}
+
+	return nil
+}
+
+/*
+	Panic of the handler
+*/
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (e handlerPanic) Error() string {
+	return fmt.Sprintf("panic in the handler: %v", e.value)
+}
+
+func (s *triggerHook) execute(handler contracts.Handler, taskToSend contracts.TaskToSendInterface, timeout time.Duration) {
+	task := taskToSend.Task()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := call(ctx, handler, task)
+	switch err := err.(type) {
+	case nil:
+		taskToSend.Confirm()
+	case handlerPanic:
+		s.eventHandler.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"task id": task.Id,
+			"stack":   string(err.stack),
+		})
+		taskToSend.RollbackWithBackoff()
+	default:
+		s.eventHandler.New(contracts.LevelDebug, err.Error(), map[string]interface{}{"task id": task.Id})
+		taskToSend.RollbackWithBackoff()
+	}
+}
+
+/*
+	The panic is returned as handlerPanic, so the worker keeps working
+*/
+func call(ctx context.Context, handler contracts.Handler, task domain.Task) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = handlerPanic{value: value, stack: debug.Stack()}
+		}
+	}()
+
+	return handler(ctx, task)
+}
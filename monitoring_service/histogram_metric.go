@@ -0,0 +1,113 @@
+package monitoring_service
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pvelx/triggerhook/contracts"
+)
+
+/*
+	Counts the values by the buckets. The counts are reset after each snapshot
+*/
+type HistogramMetric struct {
+	MetricInterface
+	sync.Mutex
+	upperBounds []int64
+	percentiles []float64
+	counts      []int64
+	count       int64
+	sum         int64
+	max         int64
+}
+
+func NewHistogramMetric(upperBounds []int64, percentiles []float64) *HistogramMetric {
+	upperBounds = append([]int64(nil), upperBounds...)
+	sort.Slice(upperBounds, func(i, j int) bool {
+		return upperBounds[i] < upperBounds[j]
+	})
+
+	return &HistogramMetric{
+		upperBounds: upperBounds,
+		percentiles: percentiles,
+		counts:      make([]int64, len(upperBounds)),
+	}
+}
+
+func (m *HistogramMetric) Set(value int64) {
+	m.Lock()
+	defer m.Unlock()
+
+	i := sort.Search(len(m.upperBounds), func(i int) bool {
+		return m.upperBounds[i] >= value
+	})
+	if i < len(m.counts) {
+		m.counts[i]++
+	}
+
+	if m.count == 0 || value > m.max {
+		m.max = value
+	}
+	m.count++
+	m.sum += value
+}
+
+/*
+	Count of the values since the previous snapshot
+*/
+func (m *HistogramMetric) Get() int64 {
+	return m.Snapshot().Count
+}
+
+func (m *HistogramMetric) Snapshot() contracts.HistogramSnapshot {
+	m.Lock()
+	defer m.Unlock()
+
+	snapshot := contracts.HistogramSnapshot{
+		Buckets:     make([]contracts.HistogramBucket, len(m.upperBounds)),
+		Count:       m.count,
+		Sum:         m.sum,
+		Max:         m.max,
+		Percentiles: make(map[float64]int64, len(m.percentiles)),
+	}
+
+	var cumulative int64
+	for i, upperBound := range m.upperBounds {
+		cumulative += m.counts[i]
+		snapshot.Buckets[i] = contracts.HistogramBucket{UpperBound: upperBound, Count: cumulative}
+		m.counts[i] = 0
+	}
+
+	for _, percentile := range m.percentiles {
+		snapshot.Percentiles[percentile] = estimate(snapshot, percentile)
+	}
+
+	m.count, m.sum, m.max = 0, 0, 0
+
+	return snapshot
+}
+
+/*
+	The value is interpolated linearly inside the bucket. The values which do not fit any bucket
+	are estimated by the max value
+*/
+func estimate(snapshot contracts.HistogramSnapshot, percentile float64) int64 {
+	if snapshot.Count == 0 {
+		return 0
+	}
+
+	rank := percentile * float64(snapshot.Count)
+	var lowerBound, lowerCount int64
+	for _, bucket := range snapshot.Buckets {
+		if float64(bucket.Count) >= rank && bucket.Count > lowerCount {
+			value := lowerBound + int64(float64(bucket.UpperBound-lowerBound)*(rank-float64(lowerCount))/float64(bucket.Count-lowerCount))
+			if value > snapshot.Max {
+				return snapshot.Max
+			}
+			return value
+		}
+		lowerBound, lowerCount = bucket.UpperBound, bucket.Count
+	}
+
+	return snapshot.Max
+}
@@ -10,10 +10,13 @@ type MonitoringMock struct {
 	/*
 		You need to substitute *Mock methods to do substitute original functions
 	*/
-	InitMock    func(topic contracts.Topic, metricType contracts.MetricType) error
-	PublishMock func(topic contracts.Topic, measurement int64) error
-	ListenMock  func(topic contracts.Topic, callback func() int64) error
-	RunMock     func()
+	InitMock      func(topic contracts.Topic, metricType contracts.MetricType) error
+	PublishMock   func(topic contracts.Topic, measurement int64) error
+	ListenMock    func(topic contracts.Topic, callback func() int64) error
+	RemoveMock    func(topic contracts.Topic)
+	SubscribeMock func(topic contracts.Topic, callback func(event contracts.MeasurementEvent)) func()
+	RunMock       func()
+	StopMock      func()
 }
 
 func (m *MonitoringMock) Init(topic contracts.Topic, metricType contracts.MetricType) error {
@@ -43,3 +46,24 @@ func (m *MonitoringMock) Run() {
 	}
 	m.RunMock()
 }
+
+func (m *MonitoringMock) Remove(topic contracts.Topic) {
+	if m.RemoveMock == nil {
+		return
+	}
+	m.RemoveMock(topic)
+}
+
+func (m *MonitoringMock) Subscribe(topic contracts.Topic, callback func(event contracts.MeasurementEvent)) func() {
+	if m.SubscribeMock == nil {
+		return func() {}
+	}
+	return m.SubscribeMock(topic, callback)
+}
+
+func (m *MonitoringMock) Stop() {
+	if m.StopMock == nil {
+		return
+	}
+	m.StopMock()
+}
@@ -1,6 +1,7 @@
 package monitoring_service
 
 import (
+	"sync"
 	"time"
 
 	"github.com/imdario/mergo"
@@ -14,12 +15,27 @@ type MetricInterface interface {
 
 type Options struct {
 	PeriodMeasure time.Duration
-	EventCap      int
+
+	/*
+		Max count of the events waiting for the callback of the subscription. The events are dropped
+		if the callback does not keep up, see the topic DroppedEvents
+	*/
+	EventCap int
 
 	/*
 		Subscribing to measurement events
 	*/
 	Subscriptions map[contracts.Topic]func(event contracts.MeasurementEvent)
+
+	/*
+		Upper bounds of the buckets of the histogram metrics
+	*/
+	HistogramBuckets []int64
+
+	/*
+		Percentiles which are estimated in the snapshots of the histogram metrics
+	*/
+	Percentiles []float64
 }
 
 func New(options *Options) contracts.MonitoringInterface {
@@ -28,42 +44,67 @@ func New(options *Options) contracts.MonitoringInterface {
 	}
 
 	if err := mergo.Merge(options, Options{
-		PeriodMeasure: 10 * time.Second,
-		EventCap:      1000,
+		PeriodMeasure:    10 * time.Second,
+		EventCap:         1000,
+		HistogramBuckets: []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000},
+		Percentiles:      []float64{0.5, 0.9, 0.99},
 	}); err != nil {
 		panic(err)
 	}
 
-	subscriptionChs := make(map[contracts.Topic][]chan contracts.MeasurementEvent)
-	for topic, callback := range options.Subscriptions {
-		topic := topic
-		callback := callback
-		eventCh := make(chan contracts.MeasurementEvent, options.EventCap)
-		subscriptionChs[topic] = append(subscriptionChs[topic], eventCh)
-
-		go func() {
-			for measure := range eventCh {
-				callback(measure)
-			}
-		}()
+	droppedEvents := &VelocityMetric{}
+
+	monitoring := &Monitoring{
+		periodMeasure:    options.PeriodMeasure,
+		metrics:          map[contracts.Topic]MetricInterface{contracts.DroppedEvents: droppedEvents},
+		listeners:        make(map[contracts.Topic]chan struct{}),
+		subscriptions:    make(map[contracts.Topic]map[*subscription]struct{}),
+		droppedEvents:    droppedEvents,
+		EventCap:         options.EventCap,
+		histogramBuckets: options.HistogramBuckets,
+		percentiles:      options.Percentiles,
+		stopping:         make(chan struct{}),
 	}
 
-	return &Monitoring{
-		periodMeasure:   options.PeriodMeasure,
-		metrics:         make(map[contracts.Topic]MetricInterface),
-		subscriptionChs: subscriptionChs,
-		EventCap:        options.EventCap,
+	for topic, callback := range options.Subscriptions {
+		monitoring.Subscribe(topic, callback)
 	}
+
+	return monitoring
 }
 
 type Monitoring struct {
-	periodMeasure   time.Duration
-	metrics         map[contracts.Topic]MetricInterface
-	subscriptionChs map[contracts.Topic][]chan contracts.MeasurementEvent
-	EventCap        int
+	sync.RWMutex
+	periodMeasure time.Duration
+	metrics       map[contracts.Topic]MetricInterface
+
+	/*
+		Closed when the topic of the listening is removed
+	*/
+	listeners     map[contracts.Topic]chan struct{}
+	subscriptions map[contracts.Topic]map[*subscription]struct{}
+	droppedEvents MetricInterface
+
+	EventCap         int
+	histogramBuckets []int64
+	percentiles      []float64
+
+	stopOnce sync.Once
+	stopping chan struct{}
+}
+
+type subscription struct {
+	events chan contracts.MeasurementEvent
+
+	/*
+		Closed when the subscription is cancelled
+	*/
+	cancelled chan struct{}
 }
 
 func (m *Monitoring) Init(topic contracts.Topic, calcType contracts.MetricType) error {
+	m.Lock()
+	defer m.Unlock()
 
 	if _, ok := m.metrics[topic]; ok {
 		return contracts.MonitoringErrorTopicExist
@@ -78,6 +119,8 @@ func (m *Monitoring) Init(topic contracts.Topic, calcType contracts.MetricType)
 		metric = &VelocityMetric{}
 	case contracts.ValueMetricType:
 		metric = &ValueMetric{}
+	case contracts.HistogramMetricType:
+		metric = NewHistogramMetric(m.histogramBuckets, m.percentiles)
 	}
 
 	m.metrics[topic] = metric
@@ -86,7 +129,10 @@ func (m *Monitoring) Init(topic contracts.Topic, calcType contracts.MetricType)
 }
 
 func (m *Monitoring) Publish(topic contracts.Topic, measurement int64) error {
+	m.RLock()
 	metric, ok := m.metrics[topic]
+	m.RUnlock()
+
 	if !ok {
 		return contracts.MonitoringErrorTopicIsNotInitialized
 	}
@@ -97,42 +143,144 @@ func (m *Monitoring) Publish(topic contracts.Topic, measurement int64) error {
 }
 
 func (m *Monitoring) Listen(topic contracts.Topic, callback func() int64) error {
-
+	m.Lock()
 	if _, ok := m.metrics[topic]; ok {
+		m.Unlock()
 		return contracts.MonitoringErrorTopicExist
 	}
 	metric := &ValueMetric{}
 	m.metrics[topic] = metric
+	removed := make(chan struct{})
+	m.listeners[topic] = removed
+	m.Unlock()
 
 	go func() {
+		ticker := time.NewTicker(m.periodMeasure)
+		defer ticker.Stop()
+
 		for {
 			metric.Set(callback())
-			time.Sleep(m.periodMeasure)
+
+			select {
+			case <-ticker.C:
+			case <-removed:
+				return
+			case <-m.stopping:
+				return
+			}
 		}
 	}()
 
 	return nil
 }
 
-func (m *Monitoring) Run() {
-	for {
-		for topic, topicSubscriptions := range m.subscriptionChs {
-			metric := m.metrics[topic]
-			if metric == nil {
-				continue
+func (m *Monitoring) Remove(topic contracts.Topic) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.metrics, topic)
+
+	if removed, ok := m.listeners[topic]; ok {
+		close(removed)
+		delete(m.listeners, topic)
+	}
+}
+
+func (m *Monitoring) Subscribe(topic contracts.Topic, callback func(event contracts.MeasurementEvent)) func() {
+	s := &subscription{
+		events:    make(chan contracts.MeasurementEvent, m.EventCap),
+		cancelled: make(chan struct{}),
+	}
+
+	m.Lock()
+	if m.subscriptions[topic] == nil {
+		m.subscriptions[topic] = make(map[*subscription]struct{})
+	}
+	m.subscriptions[topic][s] = struct{}{}
+	m.Unlock()
+
+	go func() {
+		for {
+			select {
+			case event := <-s.events:
+				callback(event)
+			case <-s.cancelled:
+				return
+			case <-m.stopping:
+				return
 			}
-			measure := metric.Get()
-			now := time.Now()
-
-			for _, subscriptionCh := range topicSubscriptions {
-				subscriptionCh <- contracts.MeasurementEvent{
-					Measurement:   measure,
-					Time:          now,
-					PeriodMeasure: m.periodMeasure,
-				}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			m.Lock()
+			delete(m.subscriptions[topic], s)
+			if len(m.subscriptions[topic]) == 0 {
+				delete(m.subscriptions, topic)
 			}
+			m.Unlock()
+
+			close(s.cancelled)
+		})
+	}
+}
+
+func (m *Monitoring) Run() {
+	ticker := time.NewTicker(m.periodMeasure)
+	defer ticker.Stop()
+
+	for {
+		m.measure()
+
+		select {
+		case <-ticker.C:
+		case <-m.stopping:
+			return
+		}
+	}
+}
+
+/*
+	Sends the measurements to the subscribers. The event is dropped if the subscriber is not ready,
+	so a slow subscriber does not delay the others
+*/
+func (m *Monitoring) measure() {
+	m.RLock()
+	defer m.RUnlock()
+
+	for topic, subscriptions := range m.subscriptions {
+		metric := m.metrics[topic]
+		if metric == nil {
+			continue
+		}
+
+		event := contracts.MeasurementEvent{
+			Time:          time.Now(),
+			PeriodMeasure: m.periodMeasure,
+		}
+		if histogram, ok := metric.(*HistogramMetric); ok {
+			snapshot := histogram.Snapshot()
+			event.Measurement = snapshot.Count
+			event.Histogram = &snapshot
+		} else {
+			event.Measurement = metric.Get()
 		}
 
-		time.Sleep(m.periodMeasure)
+		for s := range subscriptions {
+			select {
+			case s.events <- event:
+			default:
+				m.droppedEvents.Set(1)
+			}
+		}
 	}
 }
+
+func (m *Monitoring) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopping)
+	})
+}
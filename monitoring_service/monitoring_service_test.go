@@ -1,6 +1,9 @@
 package monitoring_service
 
 import (
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -107,3 +110,159 @@ func TestMainFlow(t *testing.T) {
 		})
 	}
 }
+
+func TestHistogramMetric(t *testing.T) {
+	metric := NewHistogramMetric([]int64{100, 10, 1000}, []float64{0.5, 0.9, 1})
+
+	for _, value := range []int64{1, 5, 20, 40, 60, 80, 200, 400, 600, 5000} {
+		metric.Set(value)
+	}
+
+	snapshot := metric.Snapshot()
+	assert.Equal(t, []contracts.HistogramBucket{
+		{UpperBound: 10, Count: 2},
+		{UpperBound: 100, Count: 6},
+		{UpperBound: 1000, Count: 9},
+	}, snapshot.Buckets, "the buckets must be sorted and cumulative")
+	assert.Equal(t, int64(10), snapshot.Count)
+	assert.Equal(t, int64(6406), snapshot.Sum)
+	assert.Equal(t, int64(5000), snapshot.Max)
+	assert.Equal(t, map[float64]int64{
+		0.5: 77,   // the 5th value is in the bucket (10, 100] with 4 values: 10 + 90 * 3/4
+		0.9: 1000, // the 9th value is the last one in the bucket (100, 1000]
+		1:   5000, // the 10th value does not fit any bucket
+	}, snapshot.Percentiles)
+
+	snapshot = metric.Snapshot()
+	assert.Equal(t, int64(0), snapshot.Count, "the values must be reset after the snapshot")
+	assert.Equal(t, int64(0), snapshot.Buckets[2].Count)
+	assert.Equal(t, int64(0), snapshot.Percentiles[0.5])
+}
+
+func TestHistogramEvent(t *testing.T) {
+	var topicName contracts.Topic = "topic"
+	events := make(chan contracts.MeasurementEvent, 10)
+
+	monitoringService := New(&Options{
+		PeriodMeasure:    50 * time.Millisecond,
+		HistogramBuckets: []int64{10, 100},
+		Percentiles:      []float64{0.5},
+		Subscriptions: map[contracts.Topic]func(event contracts.MeasurementEvent){
+			topicName: func(event contracts.MeasurementEvent) {
+				events <- event
+			},
+		},
+	})
+	assert.NoError(t, monitoringService.Init(topicName, contracts.HistogramMetricType))
+	assert.NoError(t, monitoringService.Publish(topicName, 50))
+	assert.NoError(t, monitoringService.Publish(topicName, 70))
+	go monitoringService.Run()
+
+	event := <-events
+	assert.Equal(t, int64(2), event.Measurement, "the measurement must be the count of values")
+	assert.Equal(t, &contracts.HistogramSnapshot{
+		Buckets:     []contracts.HistogramBucket{{UpperBound: 10, Count: 0}, {UpperBound: 100, Count: 2}},
+		Count:       2,
+		Sum:         120,
+		Max:         70,
+		Percentiles: map[float64]int64{0.5: 55},
+	}, event.Histogram)
+
+	event = <-events
+	assert.Equal(t, int64(0), event.Measurement, "the values must be counted for the period")
+}
+
+func TestSubscribeAtRuntime(t *testing.T) {
+	monitoringService := New(&Options{PeriodMeasure: time.Millisecond})
+	go monitoringService.Run()
+	defer monitoringService.Stop()
+
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < 10; worker++ {
+		topic := contracts.Topic(fmt.Sprintf("topic_%d", worker))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			events := make(chan contracts.MeasurementEvent, 1)
+			assert.NoError(t, monitoringService.Init(topic, contracts.VelocityMetricType))
+			cancel := monitoringService.Subscribe(topic, func(event contracts.MeasurementEvent) {
+				select {
+				case events <- event:
+				default:
+				}
+			})
+			for i := 0; i < 100; i++ {
+				assert.NoError(t, monitoringService.Publish(topic, 1))
+			}
+			<-events
+			cancel()
+			cancel()
+
+			monitoringService.Remove(topic)
+			assert.Equal(t, contracts.MonitoringErrorTopicIsNotInitialized, monitoringService.Publish(topic, 1))
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	monitoringService := New(&Options{PeriodMeasure: time.Millisecond, EventCap: 1})
+	go monitoringService.Run()
+	defer monitoringService.Stop()
+
+	var topicName contracts.Topic = "topic"
+	assert.NoError(t, monitoringService.Init(topicName, contracts.ValueMetricType))
+
+	blocked := make(chan struct{})
+	defer close(blocked)
+	monitoringService.Subscribe(topicName, func(event contracts.MeasurementEvent) {
+		<-blocked
+	})
+
+	dropped := make(chan int64, 1)
+	monitoringService.Subscribe(contracts.DroppedEvents, func(event contracts.MeasurementEvent) {
+		select {
+		case dropped <- event.Measurement:
+		default:
+		}
+	})
+
+	assert.Eventually(t, func() bool {
+		return <-dropped > 0
+	}, time.Second, time.Millisecond, "the events of the slow subscriber must be dropped")
+}
+
+func TestStop(t *testing.T) {
+	monitoringService := New(&Options{PeriodMeasure: time.Millisecond})
+
+	var calls int64
+	assert.NoError(t, monitoringService.Listen("listened", func() int64 {
+		return atomic.AddInt64(&calls, 1)
+	}))
+	assert.NoError(t, monitoringService.Listen("removed", func() int64 {
+		return 0
+	}))
+	monitoringService.Remove("removed")
+
+	stopped := make(chan struct{})
+	go func() {
+		monitoringService.Run()
+		close(stopped)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	monitoringService.Stop()
+	monitoringService.Stop()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("the monitoring must be stopped")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	callsAfterStop := atomic.LoadInt64(&calls)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, callsAfterStop, atomic.LoadInt64(&calls), "the listening must be stopped")
+}
@@ -21,6 +21,17 @@ type Options struct {
 	WorkersCount             int
 	CtxTimeout               time.Duration
 	PreloadedTaskCap         int //Deprecated
+
+	/*
+		Interval between the heartbeats of the instance. Must be less than InstanceTimeout of the repository
+	*/
+	HeartbeatInterval time.Duration
+
+	/*
+		Queues of the tasks preloaded by the instance. The tasks of other queues are left to other instances.
+		The tasks of all queues are preloaded if the queues are not specified, the default queue is named ""
+	*/
+	Queues []string
 }
 
 func New(
@@ -40,6 +51,7 @@ func New(
 		TaskNumberInOneSearch:    1000,
 		WorkersCount:             10,
 		CtxTimeout:               5 * time.Second,
+		HeartbeatInterval:        5 * time.Second,
 	}
 
 	if err := mergo.Merge(options, defaultOptions); err != nil {
@@ -65,10 +77,15 @@ func New(
 		workersCount:             options.WorkersCount,
 		monitoring:               monitoring,
 		ctxTimeout:               options.CtxTimeout,
+		heartbeatInterval:        options.HeartbeatInterval,
+		queues:                   options.Queues,
+		stopping:                 make(chan struct{}),
+		done:                     make(chan struct{}),
 	}
 }
 
 type preloadingService struct {
+	sync.RWMutex
 	taskManager              contracts.TaskManagerInterface
 	eh                       contracts.EventHandlerInterface
 	preloadedTask            chan domain.Task
@@ -78,6 +95,14 @@ type preloadingService struct {
 	workersCount             int
 	monitoring               contracts.MonitoringInterface
 	ctxTimeout               time.Duration
+	heartbeatInterval        time.Duration
+	queues                   []string
+
+	/*
+		Closed when the service is stopped. done is closed when Run returns
+	*/
+	stopping chan struct{}
+	done     chan struct{}
 }
 
 func (s *preloadingService) GetPreloadedChan() <-chan domain.Task {
@@ -85,8 +110,10 @@ func (s *preloadingService) GetPreloadedChan() <-chan domain.Task {
 }
 
 func (s *preloadingService) AddNewTask(ctx context.Context, task *domain.Task) error {
-	relativeTimeToExec := time.Duration(task.ExecTime-time.Now().Unix()) * time.Second
-	isTaken := s.timePreload*time.Duration(s.coefTimePreloadOfNewTask) > relativeTimeToExec
+	s.RLock()
+	defer s.RUnlock()
+
+	isTaken := s.isTaken(task)
 
 	if err := s.taskManager.Create(ctx, task, isTaken); err != nil {
 		return err
@@ -103,15 +130,181 @@ func (s *preloadingService) AddNewTask(ctx context.Context, task *domain.Task) e
 	return nil
 }
 
+func (s *preloadingService) AddNewTasks(ctx context.Context, tasks []domain.Task) ([]error, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	results := make([]error, len(tasks))
+	var takenIndexes, notTakenIndexes []int
+	for i := range tasks {
+		if s.isTaken(&tasks[i]) {
+			takenIndexes = append(takenIndexes, i)
+		} else {
+			notTakenIndexes = append(notTakenIndexes, i)
+		}
+	}
+
+	//	The taken tasks are created first, so they are sent at once even if the rest is failed
+	var created int64
+	isFailed := true
+	for _, batch := range []struct {
+		indexes []int
+		isTaken bool
+	}{
+		{takenIndexes, true},
+		{notTakenIndexes, false},
+	} {
+		if len(batch.indexes) == 0 {
+			continue
+		}
+
+		batchTasks := make([]domain.Task, 0, len(batch.indexes))
+		for _, i := range batch.indexes {
+			batchTasks = append(batchTasks, tasks[i])
+		}
+
+		batchResults, err := s.taskManager.CreateBatch(ctx, batchTasks, batch.isTaken)
+		if err != nil {
+			for _, i := range batch.indexes {
+				results[i] = err
+			}
+			continue
+		}
+		isFailed = false
+
+		for j, i := range batch.indexes {
+			tasks[i] = batchTasks[j]
+			results[i] = batchResults[j]
+			if batchResults[j] != nil {
+				continue
+			}
+
+			created++
+			if batch.isTaken {
+				s.preloadedTask <- tasks[i]
+			}
+		}
+	}
+
+	if isFailed && len(tasks) > 0 {
+		return nil, results[0]
+	}
+
+	if err := s.monitoring.Publish(contracts.CreatingRate, created); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return results, nil
+}
+
+func (s *preloadingService) RequeueDeadLetter(ctx context.Context, task *domain.Task) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	isTaken := s.isTaken(task)
+
+	if err := s.taskManager.RequeueDeadLetter(ctx, task, isTaken); err != nil {
+		return err
+	}
+
+	if isTaken {
+		s.preloadedTask <- *task
+	}
+
+	if err := s.monitoring.Publish(contracts.CreatingRate, 1); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return nil
+}
+
+func (s *preloadingService) Reschedule(ctx context.Context, task *domain.Task) (bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	isTaken := s.isTaken(task)
+
+	if err := s.taskManager.Reschedule(ctx, task, isTaken); err != nil {
+		return false, err
+	}
+
+	return isTaken, nil
+}
+
+/*
+	The task which is executed soon is sent at once, so it is not needed to preload it.
+	After stopping the tasks are not taken, they are left to other instances. The tasks of the queues
+	which are not preloaded by the instance are not taken too
+*/
+func (s *preloadingService) isTaken(task *domain.Task) bool {
+	select {
+	case <-s.stopping:
+		return false
+	default:
+	}
+
+	if !s.isPreloaded(task.Queue) {
+		return false
+	}
+
+	relativeTimeToExec := time.Duration(task.ExecTime-time.Now().Unix()) * time.Second
+
+	return s.timePreload*time.Duration(s.coefTimePreloadOfNewTask) > relativeTimeToExec
+}
+
+func (s *preloadingService) isPreloaded(queue string) bool {
+	if len(s.queues) == 0 {
+		return true
+	}
+
+	for _, q := range s.queues {
+		if q == queue {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (s *preloadingService) Run() {
+	defer close(s.done)
+
+	s.heartbeat()
+	go func() {
+		ticker := time.NewTicker(s.heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.heartbeat()
+			case <-s.stopping:
+				return
+			}
+		}
+	}()
+
 	for {
+		select {
+		case <-s.stopping:
+			return
+		default:
+		}
+
 		ctx, stop := context.WithTimeout(context.Background(), s.ctxTimeout)
-		result, err := s.taskManager.GetTasksToComplete(ctx, s.timePreload)
+		result, err := s.taskManager.GetTasksToComplete(ctx, s.timePreload, s.queues)
 		switch {
 		case err == contracts.TmErrorCollectionsNotFound:
 			stop()
 			s.eh.New(contracts.LevelDebug, "I go to sleep because I don't get any tasks", nil)
-			time.Sleep(s.timePreload)
+
+			sleep := time.NewTimer(s.timePreload)
+			select {
+			case <-sleep.C:
+			case <-s.stopping:
+				sleep.Stop()
+				return
+			}
 
 			continue
 		case err != nil:
@@ -137,6 +330,12 @@ func (s *preloadingService) Run() {
 func (s *preloadingService) getBunchOfTask(ctx context.Context, wg *sync.WaitGroup, result contracts.CollectionsInterface, worker int) {
 	defer wg.Done()
 	for {
+		select {
+		case <-s.stopping:
+			return
+		default:
+		}
+
 		tasks, err := result.Next(ctx)
 		if err != nil {
 			if err == contracts.RepoErrorNoCollections {
@@ -164,8 +363,56 @@ func (s *preloadingService) getBunchOfTask(ctx context.Context, wg *sync.WaitGro
 			s.eh.New(contracts.LevelError, err.Error(), nil)
 		}
 
-		for _, task := range tasks {
-			s.preloadedTask <- task
+		for i, task := range tasks {
+			select {
+			case s.preloadedTask <- task:
+				continue
+			case <-s.stopping:
+			}
+
+			//	The tasks are not sent after stopping, so they are released
+			s.release(ctx, tasks[i:])
+			return
 		}
 	}
 }
+
+/*
+	The collections taken by the instance are not taken by other instances while the heartbeats are sent
+*/
+func (s *preloadingService) heartbeat() {
+	ctx, stop := context.WithTimeout(context.Background(), s.ctxTimeout)
+	defer stop()
+
+	if err := s.taskManager.Heartbeat(ctx); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+}
+
+func (s *preloadingService) release(ctx context.Context, tasks []domain.Task) {
+	if err := s.taskManager.Release(ctx, tasks); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"count of task": len(tasks),
+		})
+	}
+}
+
+/*
+	Stops preloading and waits for the tasks being added. Must be called after Run
+*/
+func (s *preloadingService) Stop(ctx context.Context) error {
+	s.Lock()
+	select {
+	case <-s.stopping:
+	default:
+		close(s.stopping)
+	}
+	s.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -57,6 +57,200 @@ func TestTaskAdding(t *testing.T) {
 	}
 }
 
+func TestTaskOfQueueAdding(t *testing.T) {
+	var isTakenActual bool
+	taskManagerMock := &task_manager.TaskManagerMock{CreateMock: func(ctx context.Context, task *domain.Task, isTaken bool) error {
+		isTakenActual = isTaken
+		return nil
+	}}
+
+	preloadingService := New(taskManagerMock, nil, &monitoring_service.MonitoringMock{}, &Options{Queues: []string{"emails"}})
+
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix(), Queue: "emails"}
+	assert.NoError(t, preloadingService.AddNewTask(context.Background(), &task))
+	assert.True(t, isTakenActual, "the task of the preloaded queue must be taken")
+	assert.Equal(t, task, <-preloadingService.GetPreloadedChan())
+
+	task = domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix(), Queue: "sms"}
+	assert.NoError(t, preloadingService.AddNewTask(context.Background(), &task))
+	assert.False(t, isTakenActual, "the task of the queue which is not preloaded must not be taken")
+	assert.Len(t, preloadingService.GetPreloadedChan(), 0)
+}
+
+func TestTasksAdding(t *testing.T) {
+	actualIds := make(map[bool][]string)
+	taskManagerMock := &task_manager.TaskManagerMock{
+		CreateBatchMock: func(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+			results := make([]error, len(tasks))
+			for i := range tasks {
+				actualIds[isTaken] = append(actualIds[isTaken], tasks[i].Id)
+				if tasks[i].Id == "" {
+					results[i] = contracts.TmErrorTaskExist
+				}
+				tasks[i].Payload = []byte("payload")
+			}
+			return results, nil
+		},
+	}
+
+	preloadingService := New(taskManagerMock, nil, &monitoring_service.MonitoringMock{}, nil)
+	preloadedTask := preloadingService.GetPreloadedChan()
+
+	now := time.Now().Unix()
+	tasks := []domain.Task{
+		{Id: util.NewId(), ExecTime: now + 3600},
+		{Id: util.NewId(), ExecTime: now},
+		{ExecTime: now + 1},
+		{Id: util.NewId(), ExecTime: now + 10},
+	}
+	results, err := preloadingService.AddNewTasks(context.Background(), tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, nil, contracts.TmErrorTaskExist, nil}, results)
+	assert.Equal(t, []string{tasks[1].Id, tasks[2].Id}, actualIds[true], "The tasks must be taken")
+	assert.Equal(t, []string{tasks[0].Id, tasks[3].Id}, actualIds[false], "The tasks must not be taken")
+	assert.Equal(t, []byte("payload"), tasks[0].Payload, "The tasks must be filled by the task manager")
+	assert.Len(t, preloadedTask, 1, "Only the created taken task must be send in channel")
+	assert.Equal(t, tasks[1], <-preloadedTask)
+
+	taskManagerMock.CreateBatchMock = func(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+		if isTaken {
+			return nil, contracts.TmErrorCreatingTasks
+		}
+		return make([]error, len(tasks)), nil
+	}
+	results, err = preloadingService.AddNewTasks(context.Background(), []domain.Task{
+		{Id: util.NewId(), ExecTime: now},
+		{Id: util.NewId(), ExecTime: now + 3600},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{contracts.TmErrorCreatingTasks, nil}, results, "The failed part must not fail the rest")
+
+	taskManagerMock.CreateBatchMock = func(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+		return nil, contracts.TmErrorCreatingTasks
+	}
+	_, err = preloadingService.AddNewTasks(context.Background(), []domain.Task{{Id: util.NewId(), ExecTime: now + 3600}})
+	assert.Equal(t, contracts.TmErrorCreatingTasks, err)
+}
+
+func TestRequeueDeadLetter(t *testing.T) {
+	var isTakenActual bool
+	taskManagerMock := &task_manager.TaskManagerMock{
+		RequeueDeadLetterMock: func(ctx context.Context, task *domain.Task, isTaken bool) error {
+			isTakenActual = isTaken
+			return nil
+		},
+	}
+
+	preloadingService := New(taskManagerMock, nil, &monitoring_service.MonitoringMock{}, nil)
+	preloadedTask := preloadingService.GetPreloadedChan()
+
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix()}
+	assert.NoError(t, preloadingService.RequeueDeadLetter(context.Background(), &task))
+	assert.True(t, isTakenActual, "The task must be taken")
+	assert.Equal(t, task, <-preloadedTask, "The task must be send in channel")
+
+	task = domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix() + 3600}
+	assert.NoError(t, preloadingService.RequeueDeadLetter(context.Background(), &task))
+	assert.False(t, isTakenActual, "The task must not be taken")
+	assert.Len(t, preloadedTask, 0, "Len of channel is wrong")
+
+	taskManagerMock.RequeueDeadLetterMock = func(ctx context.Context, task *domain.Task, isTaken bool) error {
+		return contracts.TmErrorTaskNotFound
+	}
+	assert.Equal(t, contracts.TmErrorTaskNotFound, preloadingService.RequeueDeadLetter(context.Background(), &task))
+}
+
+func TestReschedule(t *testing.T) {
+	var isTakenActual bool
+	taskManagerMock := &task_manager.TaskManagerMock{
+		RescheduleMock: func(ctx context.Context, task *domain.Task, isTaken bool) error {
+			isTakenActual = isTaken
+			task.Payload = []byte("payload")
+			return nil
+		},
+	}
+
+	preloadingService := New(taskManagerMock, nil, &monitoring_service.MonitoringMock{}, nil)
+	preloadedTask := preloadingService.GetPreloadedChan()
+
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix() + 1}
+	isTaken, err := preloadingService.Reschedule(context.Background(), &task)
+	assert.NoError(t, err)
+	assert.True(t, isTaken, "The task must be taken")
+	assert.True(t, isTakenActual, "The task must be taken")
+	assert.Equal(t, []byte("payload"), task.Payload, "The task must be filled by the task manager")
+	assert.Len(t, preloadedTask, 0, "The rescheduled task must not be send in channel")
+
+	task = domain.Task{Id: task.Id, ExecTime: time.Now().Unix() + 3600}
+	isTaken, err = preloadingService.Reschedule(context.Background(), &task)
+	assert.NoError(t, err)
+	assert.False(t, isTaken, "The task must not be taken")
+	assert.False(t, isTakenActual, "The task must not be taken")
+
+	taskManagerMock.RescheduleMock = func(ctx context.Context, task *domain.Task, isTaken bool) error {
+		return contracts.TmErrorTaskNotFound
+	}
+	_, err = preloadingService.Reschedule(context.Background(), &task)
+	assert.Equal(t, contracts.TmErrorTaskNotFound, err)
+}
+
+func TestStop(t *testing.T) {
+	tasks := []domain.Task{
+		{Id: util.NewId(), ExecTime: time.Now().Unix()},
+		{Id: util.NewId(), ExecTime: time.Now().Unix()},
+		{Id: util.NewId(), ExecTime: time.Now().Unix()},
+	}
+
+	var isFound, nextCalls int32
+	releasedTasks := make(chan []domain.Task, 1)
+	var isTakenActual bool
+	taskManagerMock := &task_manager.TaskManagerMock{
+		GetTasksToCompleteMock: func(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (contracts.CollectionsInterface, error) {
+			if !atomic.CompareAndSwapInt32(&isFound, 0, 1) {
+				return nil, contracts.TmErrorCollectionsNotFound
+			}
+
+			//	There are more collections, but they must not be read after stopping
+			return &repository.CollectionsMock{NextMock: func(ctx context.Context) ([]domain.Task, error) {
+				atomic.AddInt32(&nextCalls, 1)
+				return tasks, nil
+			}}, nil
+		},
+		ReleaseMock: func(ctx context.Context, tasks []domain.Task) error {
+			releasedTasks <- tasks
+			return nil
+		},
+		CreateMock: func(ctx context.Context, task *domain.Task, isTaken bool) error {
+			isTakenActual = isTaken
+			return nil
+		},
+	}
+
+	preloadingService := New(
+		taskManagerMock,
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{WorkersCount: 1},
+	)
+	go preloadingService.Run()
+
+	// nobody receives the preloaded tasks, so the preloader waits for sending the second task
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, preloadingService.Stop(ctx))
+
+	assert.Equal(t, tasks[0], <-preloadingService.GetPreloadedChan())
+	assert.Equal(t, tasks[1:], <-releasedTasks, "the tasks which are not sent must be released")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&nextCalls), "the tasks must not be preloaded after stopping")
+
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix()}
+	assert.NoError(t, preloadingService.AddNewTask(context.Background(), &task))
+	assert.False(t, isTakenActual, "the task must not be taken after stopping")
+	assert.Len(t, preloadingService.GetPreloadedChan(), 0)
+}
+
 func TestMainFlow(t *testing.T) {
 
 	type collectionsType []struct {
@@ -105,7 +299,7 @@ func TestMainFlow(t *testing.T) {
 	var globalCurrentFinding int32 = 0
 
 	taskManagerMock := &task_manager.TaskManagerMock{
-		GetTasksToCompleteMock: func(ctx context.Context, preloadingTimeRange time.Duration) (contracts.CollectionsInterface, error) {
+		GetTasksToCompleteMock: func(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (contracts.CollectionsInterface, error) {
 
 			currentFinding := atomic.LoadInt32(&globalCurrentFinding)
 			if len(data) > int(currentFinding) {
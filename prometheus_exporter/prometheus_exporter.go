@@ -0,0 +1,317 @@
+package prometheus_exporter
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/imdario/mergo"
+	"github.com/pvelx/triggerhook/contracts"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+/*
+	Escaping of the values of the labels by the text format of Prometheus. The other characters are written as is
+*/
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+/*
+	Metric types of the topics of the trigger hook
+*/
+var defaultTopics = map[contracts.Topic]contracts.MetricType{
+	contracts.All:                    contracts.IntegralMetricType,
+	contracts.Preloaded:              contracts.ValueMetricType,
+	contracts.WaitingForConfirmation: contracts.IntegralMetricType,
+	contracts.CreatingRate:           contracts.VelocityMetricType,
+	contracts.DeletingRate:           contracts.VelocityMetricType,
+	contracts.PreloadingRate:         contracts.VelocityMetricType,
+	contracts.SendingRate:            contracts.VelocityMetricType,
+	contracts.ConfirmationRate:       contracts.VelocityMetricType,
+	contracts.LeaseExpirationRate:    contracts.VelocityMetricType,
+	contracts.DeadLettered:           contracts.VelocityMetricType,
+	contracts.Lateness:               contracts.HistogramMetricType,
+	contracts.ProcessingTime:         contracts.HistogramMetricType,
+	contracts.DroppedEvents:          contracts.VelocityMetricType,
+}
+
+/*
+	Topics which are measured for each named queue, see contracts.QueueTopic
+*/
+var queueTopics = map[contracts.Topic]string{
+	contracts.Preloaded:   "Number of tasks of the queue preloaded into memory",
+	contracts.SendingRate: "Number of sent tasks of the queue",
+}
+
+var help = map[contracts.Topic]string{
+	contracts.All:                    "Number of all tasks",
+	contracts.Preloaded:              "Number of tasks preloaded into memory",
+	contracts.WaitingForConfirmation: "Number of tasks waiting for confirmation after sending",
+	contracts.CreatingRate:           "Number of created tasks",
+	contracts.DeletingRate:           "Number of deleted tasks",
+	contracts.PreloadingRate:         "Number of preloaded tasks",
+	contracts.SendingRate:            "Number of sent tasks",
+	contracts.ConfirmationRate:       "Number of confirmed tasks",
+	contracts.LeaseExpirationRate:    "Number of tasks which were not confirmed or rolled back in time",
+	contracts.DeadLettered:           "Number of tasks moved to the dead letter store",
+	contracts.Lateness:               "Time between the time of execution of the task and the sending to the consumer",
+	contracts.ProcessingTime:         "Time between the sending of the task to the consumer and the confirmation",
+	contracts.DroppedEvents:          "Number of measurement events dropped because the subscriber did not keep up",
+}
+
+type Options struct {
+	/*
+		Prefix of the names of the metrics
+	*/
+	Namespace string
+
+	/*
+		Metric types of other topics, for example of your own topics. The topics of the trigger hook are exported always
+	*/
+	Topics map[contracts.Topic]contracts.MetricType
+
+	/*
+		Named queues whose topics are exported with the label "queue", for example
+		triggerhook_queue_sending_total{queue="emails"}
+	*/
+	Queues []string
+}
+
+/*
+	The monitoring or the trigger hook which sends the measurement events to the subscribers
+*/
+type Publisher interface {
+	Subscribe(topic contracts.Topic, callback func(event contracts.MeasurementEvent)) (cancel func())
+}
+
+/*
+	Exports the measurements of the monitoring in the text format of Prometheus.
+	Velocity metrics are exported as counters which are the sum of the measurements,
+	value and integral metrics are exported as gauges with the last measurement,
+	histogram metrics are exported as histograms in milliseconds which summarize the snapshots
+*/
+func New(options *Options) *Exporter {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if err := mergo.Merge(options, Options{
+		Namespace: "triggerhook",
+	}); err != nil {
+		panic(err)
+	}
+
+	exporter := &Exporter{metrics: make(map[contracts.Topic]*metric)}
+	for topic, metricType := range defaultTopics {
+		exporter.add(options.Namespace, topic, metricType)
+	}
+	for topic, metricType := range options.Topics {
+		exporter.add(options.Namespace, topic, metricType)
+	}
+	for _, queue := range options.Queues {
+		for topic, text := range queueTopics {
+			exporter.addQueue(options.Namespace, topic, text, queue)
+		}
+	}
+
+	return exporter
+}
+
+type Exporter struct {
+	sync.RWMutex
+	metrics map[contracts.Topic]*metric
+}
+
+type metric struct {
+	name       string
+	help       string
+	metricType contracts.MetricType
+	value      int64
+
+	/*
+		Label of the metric of the named queue
+	*/
+	queue string
+
+	/*
+		Only for the histogram metric. Cumulative counts of the buckets
+	*/
+	buckets []contracts.HistogramBucket
+	sum     int64
+}
+
+func (e *Exporter) add(namespace string, topic contracts.Topic, metricType contracts.MetricType) {
+	text, ok := help[topic]
+	if !ok {
+		text = fmt.Sprintf("Measurements of the topic %s", topic)
+	}
+
+	e.metrics[topic] = &metric{name: metricName(namespace, topic, metricType), help: text, metricType: metricType}
+}
+
+/*
+	The metrics of the queues are named as the topic with the prefix "queue", the name of the queue is the label
+*/
+func (e *Exporter) addQueue(namespace string, topic contracts.Topic, text string, queue string) {
+	metricType := defaultTopics[topic]
+	e.metrics[contracts.QueueTopic(topic, queue)] = &metric{
+		name:       metricName(namespace, "queue_"+topic, metricType),
+		help:       text,
+		metricType: metricType,
+		queue:      queue,
+	}
+}
+
+func metricName(namespace string, topic contracts.Topic, metricType contracts.MetricType) string {
+	name := strings.Trim(namespace+"_"+sanitize(string(topic)), "_")
+	switch metricType {
+	case contracts.VelocityMetricType:
+		name = strings.TrimSuffix(name, "_rate") + "_total"
+	case contracts.HistogramMetricType:
+		name += "_milliseconds"
+	}
+
+	return name
+}
+
+/*
+	Subscribes to the topics of the exporter at runtime. The topics of the queues may be registered
+	by the monitoring later, their measurements are exported since then. The subscriptions are cancelled
+	by the returned func.
+	The monitoring drops the events if the exporter does not keep up, so the counters may be less
+	than the actual values. The dropped events are counted by the topic DroppedEvents
+*/
+func (e *Exporter) Subscribe(publisher Publisher) (cancel func()) {
+	e.RLock()
+	topics := make([]contracts.Topic, 0, len(e.metrics))
+	for topic := range e.metrics {
+		topics = append(topics, topic)
+	}
+	e.RUnlock()
+
+	cancels := make([]func(), 0, len(topics))
+	for _, topic := range topics {
+		topic := topic
+		cancels = append(cancels, publisher.Subscribe(topic, func(event contracts.MeasurementEvent) {
+			e.Observe(topic, event)
+		}))
+	}
+
+	return func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+/*
+	Saves the measurement of the topic. The measurements of unknown topics are ignored
+*/
+func (e *Exporter) Observe(topic contracts.Topic, event contracts.MeasurementEvent) {
+	e.Lock()
+	defer e.Unlock()
+
+	m, ok := e.metrics[topic]
+	if !ok {
+		return
+	}
+
+	switch m.metricType {
+	case contracts.VelocityMetricType:
+		m.value += event.Measurement
+	case contracts.HistogramMetricType:
+		if event.Histogram != nil {
+			m.observeHistogram(*event.Histogram)
+		}
+	default:
+		m.value = event.Measurement
+	}
+}
+
+/*
+	The buckets of the snapshots must be the same, the buckets of the first snapshot are used
+*/
+func (m *metric) observeHistogram(snapshot contracts.HistogramSnapshot) {
+	if m.buckets == nil {
+		m.buckets = make([]contracts.HistogramBucket, len(snapshot.Buckets))
+		for i, bucket := range snapshot.Buckets {
+			m.buckets[i].UpperBound = bucket.UpperBound
+		}
+	}
+
+	for i, bucket := range snapshot.Buckets {
+		if i < len(m.buckets) && m.buckets[i].UpperBound == bucket.UpperBound {
+			m.buckets[i].Count += bucket.Count
+		}
+	}
+	m.value += snapshot.Count
+	m.sum += snapshot.Sum
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+
+	e.RLock()
+	metrics := make([]metric, 0, len(e.metrics))
+	for _, m := range e.metrics {
+		copied := *m
+		copied.buckets = append([]contracts.HistogramBucket(nil), m.buckets...)
+		metrics = append(metrics, copied)
+	}
+	e.RUnlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].name != metrics[j].name {
+			return metrics[i].name < metrics[j].name
+		}
+		return metrics[i].queue < metrics[j].queue
+	})
+
+	//	The metrics of the queues have the same name, so the description is written once
+	for i, m := range metrics {
+		if i == 0 || metrics[i-1].name != m.name {
+			_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, prometheusType(m.metricType))
+		}
+
+		labels := ""
+		if m.queue != "" {
+			labels = `{queue="` + labelValueEscaper.Replace(m.queue) + `"}`
+		}
+
+		switch m.metricType {
+		case contracts.HistogramMetricType:
+			for _, bucket := range m.buckets {
+				_, _ = fmt.Fprintf(w, "%s_bucket{le=\"%d\"} %d\n", m.name, bucket.UpperBound, bucket.Count)
+			}
+			_, _ = fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %d\n%s_count %d\n",
+				m.name, m.value, m.name, m.sum, m.name, m.value)
+		default:
+			_, _ = fmt.Fprintf(w, "%s%s %d\n", m.name, labels, m.value)
+		}
+	}
+}
+
+func prometheusType(metricType contracts.MetricType) string {
+	switch metricType {
+	case contracts.VelocityMetricType:
+		return "counter"
+	case contracts.HistogramMetricType:
+		return "histogram"
+	default:
+		return "gauge"
+	}
+}
+
+/*
+	Only letters, digits and underscores are allowed in the names of the metrics
+*/
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
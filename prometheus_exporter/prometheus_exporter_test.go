@@ -0,0 +1,115 @@
+package prometheus_exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/monitoring_service"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, exporter *Exporter) string {
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, contentType, recorder.Header().Get("Content-Type"))
+
+	return recorder.Body.String()
+}
+
+func TestObserve(t *testing.T) {
+	var custom contracts.Topic = "custom.topic"
+	exporter := New(&Options{
+		Topics: map[contracts.Topic]contracts.MetricType{
+			custom: contracts.ValueMetricType,
+		},
+		Queues: []string{"sms", "emails"},
+	})
+
+	exporter.Observe(contracts.SendingRate, contracts.MeasurementEvent{Measurement: 10})
+	exporter.Observe(contracts.SendingRate, contracts.MeasurementEvent{Measurement: 5})
+	exporter.Observe(contracts.All, contracts.MeasurementEvent{Measurement: 100})
+	exporter.Observe(contracts.All, contracts.MeasurementEvent{Measurement: 90})
+	exporter.Observe(custom, contracts.MeasurementEvent{Measurement: 7})
+	exporter.Observe("unknown", contracts.MeasurementEvent{Measurement: 1})
+	exporter.Observe(contracts.QueueTopic(contracts.Preloaded, "sms"), contracts.MeasurementEvent{Measurement: 3})
+	exporter.Observe(contracts.QueueTopic(contracts.Preloaded, "emails"), contracts.MeasurementEvent{Measurement: 4})
+
+	body := scrape(t, exporter)
+
+	assert.Contains(t, body, "# HELP triggerhook_sending_total Number of sent tasks\n"+
+		"# TYPE triggerhook_sending_total counter\n"+
+		"triggerhook_sending_total 15\n", "the velocity must be summarized")
+	assert.Contains(t, body, "# TYPE triggerhook_all gauge\ntriggerhook_all 90\n", "the last measurement must be exported")
+	assert.Contains(t, body, "# TYPE triggerhook_dead_lettered_total counter\ntriggerhook_dead_lettered_total 0\n")
+	assert.Contains(t, body, "# TYPE triggerhook_custom_topic gauge\ntriggerhook_custom_topic 7\n")
+	assert.NotContains(t, body, "unknown")
+	assert.Contains(t, body, "# TYPE triggerhook_queue_preloaded gauge\n"+
+		"triggerhook_queue_preloaded{queue=\"emails\"} 4\n"+
+		"triggerhook_queue_preloaded{queue=\"sms\"} 3\n", "the metrics of the queues must be described once")
+}
+
+func TestQueueLabel(t *testing.T) {
+	exporter := New(&Options{Queues: []string{"письма \"new\"\\\n"}})
+	exporter.Observe(contracts.QueueTopic(contracts.Preloaded, "письма \"new\"\\\n"), contracts.MeasurementEvent{Measurement: 1})
+
+	assert.Contains(t, scrape(t, exporter), "triggerhook_queue_preloaded{queue=\"письма \\\"new\\\"\\\\\\n\"} 1\n",
+		"only the backslash, the double quote and the line feed must be escaped")
+}
+
+func TestSubscribe(t *testing.T) {
+	exporter := New(&Options{Namespace: "app", Queues: []string{"emails"}})
+
+	monitoring := monitoring_service.New(&monitoring_service.Options{PeriodMeasure: 10 * time.Millisecond})
+	assert.NoError(t, monitoring.Init(contracts.CreatingRate, contracts.VelocityMetricType))
+	go monitoring.Run()
+	defer monitoring.Stop()
+
+	cancel := exporter.Subscribe(monitoring)
+
+	assert.NoError(t, monitoring.Publish(contracts.CreatingRate, 3))
+	assert.NoError(t, monitoring.Publish(contracts.CreatingRate, 4))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(scrape(t, exporter), "app_creating_total 7\n")
+	}, time.Second, 10*time.Millisecond, "the measurements must be exported")
+
+	//	The topic of the queue is registered when the queue is used for the first time
+	emailsSendingRate := contracts.QueueTopic(contracts.SendingRate, "emails")
+	assert.NoError(t, monitoring.Init(emailsSendingRate, contracts.VelocityMetricType))
+	assert.NoError(t, monitoring.Publish(emailsSendingRate, 2))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(scrape(t, exporter), "# HELP app_queue_sending_total Number of sent tasks of the queue\n"+
+			"# TYPE app_queue_sending_total counter\n"+
+			"app_queue_sending_total{queue=\"emails\"} 2\n")
+	}, time.Second, 10*time.Millisecond, "the measurements of the queue must be exported with the label")
+
+	cancel()
+	assert.NoError(t, monitoring.Publish(contracts.CreatingRate, 5))
+	time.Sleep(50 * time.Millisecond)
+	assert.Contains(t, scrape(t, exporter), "app_creating_total 7\n", "the measurements must not be exported after cancelling")
+}
+
+func TestHistogram(t *testing.T) {
+	exporter := New(nil)
+
+	exporter.Observe(contracts.Lateness, contracts.MeasurementEvent{Measurement: 3, Histogram: &contracts.HistogramSnapshot{
+		Buckets: []contracts.HistogramBucket{{UpperBound: 10, Count: 1}, {UpperBound: 100, Count: 2}},
+		Count:   3,
+		Sum:     1050,
+	}})
+	exporter.Observe(contracts.Lateness, contracts.MeasurementEvent{Measurement: 1, Histogram: &contracts.HistogramSnapshot{
+		Buckets: []contracts.HistogramBucket{{UpperBound: 10, Count: 1}, {UpperBound: 100, Count: 1}},
+		Count:   1,
+		Sum:     5,
+	}})
+
+	assert.Contains(t, scrape(t, exporter), "# TYPE triggerhook_lateness_milliseconds histogram\n"+
+		"triggerhook_lateness_milliseconds_bucket{le=\"10\"} 2\n"+
+		"triggerhook_lateness_milliseconds_bucket{le=\"100\"} 3\n"+
+		"triggerhook_lateness_milliseconds_bucket{le=\"+Inf\"} 4\n"+
+		"triggerhook_lateness_milliseconds_sum 1055\n"+
+		"triggerhook_lateness_milliseconds_count 4\n", "the snapshots must be summarized")
+}
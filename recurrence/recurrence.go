@@ -0,0 +1,176 @@
+package recurrence
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	ErrorScheduleNotSpecified = errors.New("either cron or interval must be specified")
+	ErrorAmbiguousSchedule    = errors.New("cron and interval cannot be specified together")
+	ErrorNegativeInterval     = errors.New("interval must be positive")
+	ErrorNegativeLimit        = errors.New("end time and max occurrences cannot be negative")
+	ErrorNoOccurrences        = errors.New("the schedule has no occurrences")
+)
+
+/*
+	Standard cron expression with 5 fields: minute, hour, day of month, month, day of week.
+	Descriptors like @daily, @hourly or @every 15m are supported too.
+*/
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+/*
+	Checks the recurrence for correctness
+*/
+func Validate(recurrence domain.Recurrence) error {
+	switch {
+	case recurrence.Cron == "" && recurrence.Interval == 0:
+		return ErrorScheduleNotSpecified
+	case recurrence.Cron != "" && recurrence.Interval != 0:
+		return ErrorAmbiguousSchedule
+	case recurrence.Interval < 0:
+		return ErrorNegativeInterval
+	case recurrence.EndTime < 0 || recurrence.MaxOccurrences < 0:
+		return ErrorNegativeLimit
+	}
+
+	if recurrence.Cron != "" {
+		if _, _, err := parse(recurrence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Calculates the time of the first occurrence after now
+*/
+func First(recurrence domain.Recurrence, now time.Time) (int64, error) {
+	if err := Validate(recurrence); err != nil {
+		return 0, err
+	}
+
+	if recurrence.Interval > 0 {
+		return now.Add(time.Duration(recurrence.Interval) * time.Second).Unix(), nil
+	}
+
+	schedule, location, err := parse(recurrence)
+	if err != nil {
+		return 0, err
+	}
+
+	first := schedule.Next(now.In(location))
+	if first.IsZero() {
+		return 0, ErrorNoOccurrences
+	}
+
+	return first.Unix(), nil
+}
+
+/*
+	Returns the next occurrence of the recurrent task. The occurrences missed before now are skipped.
+	Returns false when the task is not recurrent or its recurrence is over.
+
+	Occurrences of the cron expression are calculated in the local time of the location:
+	- the occurrence at the local time that does not exist due to the DST transition is skipped;
+	- the occurrence at the local time that repeats due to the DST transition is executed once.
+	The interval does not depend on the location and DST transitions.
+*/
+func Next(task domain.Task, now time.Time) (next domain.Task, ok bool, err error) {
+	if task.Recurrence == nil {
+		return
+	}
+
+	recurrence := *task.Recurrence
+	if recurrence.MaxOccurrences > 0 && recurrence.Occurrence+1 >= recurrence.MaxOccurrences {
+		return
+	}
+
+	if err = Validate(recurrence); err != nil {
+		return
+	}
+
+	//	The delayed task is repeated by the time of its occurrence rather than the time of the retry
+	current := util.FromMs(task.ExecTimeInMs())
+	if recurrence.ScheduledTimeMs != 0 {
+		current = util.FromMs(recurrence.ScheduledTimeMs)
+		recurrence.ScheduledTimeMs = 0
+	}
+	var nextTime time.Time
+
+	if recurrence.Interval > 0 {
+		interval := time.Duration(recurrence.Interval) * time.Second
+		nextTime = current.Add(interval)
+		if !nextTime.After(now) {
+			missed := now.Sub(nextTime)/interval + 1
+			nextTime = nextTime.Add(missed * interval)
+		}
+	} else {
+		schedule, location, errParsing := parse(recurrence)
+		if errParsing != nil {
+			err = errParsing
+			return
+		}
+
+		after := current
+		if now.After(after) {
+			after = now
+		}
+
+		nextTime = schedule.Next(after.In(location))
+
+		/*
+			When clocks are turned back the local time repeats.
+			The occurrence at the repeated local time is executed only once.
+		*/
+		if currentLocal := current.In(location); !nextTime.IsZero() && isSameWallClock(nextTime, currentLocal) {
+			nextTime = schedule.Next(nextTime)
+		}
+
+		if nextTime.IsZero() {
+			return
+		}
+	}
+
+	if recurrence.EndTime > 0 && nextTime.Unix() > recurrence.EndTime {
+		return
+	}
+
+	recurrence.Occurrence++
+
+	next = task
+	next.SetExecTimeMs(util.ToMs(nextTime))
+	next.Recurrence = &recurrence
+
+	return next, true, nil
+}
+
+func parse(recurrence domain.Recurrence) (cron.Schedule, *time.Location, error) {
+	location := time.UTC
+	if recurrence.Location != "" {
+		var err error
+		if location, err = time.LoadLocation(recurrence.Location); err != nil {
+			return nil, nil, errors.Wrap(err, "location of the recurrence is not correct")
+		}
+	}
+
+	schedule, err := parser.Parse(recurrence.Cron)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cron expression is not correct")
+	}
+
+	return schedule, location, nil
+}
+
+func isSameWallClock(a, b time.Time) bool {
+	aYear, aMonth, aDay := a.Date()
+	bYear, bMonth, bDay := b.Date()
+
+	return aYear == bYear && aMonth == bMonth && aDay == bDay &&
+		a.Hour() == b.Hour() && a.Minute() == b.Minute()
+}
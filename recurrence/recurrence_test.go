@@ -0,0 +1,226 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDSTTransitions(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		recurrence        domain.Recurrence
+		firstExecTime     time.Time
+		expectedExecTimes []time.Time
+	}{
+		{
+			name:          "daily cron keeps the local time when clocks are turned forward",
+			recurrence:    domain.Recurrence{Cron: "0 3 * * *", Location: "America/New_York"},
+			firstExecTime: time.Date(2021, 3, 13, 3, 0, 0, 0, newYork),
+			expectedExecTimes: []time.Time{
+				time.Date(2021, 3, 14, 3, 0, 0, 0, newYork),
+				time.Date(2021, 3, 15, 3, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name:          "daily cron keeps the local time when clocks are turned back",
+			recurrence:    domain.Recurrence{Cron: "0 3 * * *", Location: "America/New_York"},
+			firstExecTime: time.Date(2021, 11, 6, 3, 0, 0, 0, newYork),
+			expectedExecTimes: []time.Time{
+				time.Date(2021, 11, 7, 3, 0, 0, 0, newYork),
+				time.Date(2021, 11, 8, 3, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name:          "not existing local time is skipped",
+			recurrence:    domain.Recurrence{Cron: "30 2 * * *", Location: "America/New_York"},
+			firstExecTime: time.Date(2021, 3, 13, 2, 30, 0, 0, newYork),
+			expectedExecTimes: []time.Time{
+				time.Date(2021, 3, 15, 2, 30, 0, 0, newYork),
+				time.Date(2021, 3, 16, 2, 30, 0, 0, newYork),
+			},
+		},
+		{
+			name:          "repeated local time is executed once",
+			recurrence:    domain.Recurrence{Cron: "30 1 * * *", Location: "America/New_York"},
+			firstExecTime: time.Date(2021, 11, 6, 1, 30, 0, 0, newYork),
+			expectedExecTimes: []time.Time{
+				time.Date(2021, 11, 7, 1, 30, 0, 0, newYork),
+				time.Date(2021, 11, 8, 1, 30, 0, 0, newYork),
+			},
+		},
+		{
+			name:          "frequent cron is executed in both repeated hours",
+			recurrence:    domain.Recurrence{Cron: "*/30 * * * *", Location: "America/New_York"},
+			firstExecTime: time.Date(2021, 11, 7, 0, 30, 0, 0, newYork),
+			expectedExecTimes: []time.Time{
+				time.Unix(1636261200, 0), // 01:00 EDT
+				time.Unix(1636263000, 0), // 01:30 EDT
+				time.Unix(1636264800, 0), // 01:00 EST
+				time.Unix(1636266600, 0), // 01:30 EST
+				time.Unix(1636268400, 0), // 02:00 EST
+			},
+		},
+		{
+			name:          "interval does not depend on the DST transition",
+			recurrence:    domain.Recurrence{Interval: 24 * 60 * 60, Location: "America/New_York"},
+			firstExecTime: time.Date(2021, 3, 13, 3, 0, 0, 0, newYork),
+			expectedExecTimes: []time.Time{
+				time.Date(2021, 3, 14, 4, 0, 0, 0, newYork),
+				time.Date(2021, 3, 15, 4, 0, 0, 0, newYork),
+			},
+		},
+		{
+			name:          "cron in UTC by default",
+			recurrence:    domain.Recurrence{Cron: "0 3 * * *"},
+			firstExecTime: time.Date(2021, 3, 13, 3, 0, 0, 0, time.UTC),
+			expectedExecTimes: []time.Time{
+				time.Date(2021, 3, 14, 3, 0, 0, 0, time.UTC),
+				time.Date(2021, 3, 15, 3, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recurrence := test.recurrence
+			task := domain.Task{Id: "id", ExecTime: test.firstExecTime.Unix(), Recurrence: &recurrence}
+
+			for i, expectedExecTime := range test.expectedExecTimes {
+				next, ok, err := Next(task, time.Unix(task.ExecTime, 0))
+
+				assert.NoError(t, err)
+				assert.True(t, ok, "the next occurrence must exist")
+				assert.Equal(t, expectedExecTime.Unix(), next.ExecTime,
+					"occurrence %d: expected %s, actual %s", i, expectedExecTime, time.Unix(next.ExecTime, 0).In(expectedExecTime.Location()))
+				assert.Equal(t, i+1, next.Recurrence.Occurrence, "number of the occurrence is not correct")
+				assert.Equal(t, task.Id, next.Id, "the next occurrence must have the same id")
+
+				task = next
+			}
+		})
+	}
+}
+
+func TestNextLimits(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		recurrence       domain.Recurrence
+		execTime         time.Time
+		now              time.Time
+		expectedOk       bool
+		expectedExecTime time.Time
+	}{
+		{
+			name:             "interval",
+			recurrence:       domain.Recurrence{Interval: 15 * 60},
+			execTime:         now,
+			now:              now,
+			expectedOk:       true,
+			expectedExecTime: now.Add(15 * time.Minute),
+		},
+		{
+			name:             "missed occurrences of interval are skipped",
+			recurrence:       domain.Recurrence{Interval: 15 * 60},
+			execTime:         now,
+			now:              now.Add(time.Hour + time.Minute),
+			expectedOk:       true,
+			expectedExecTime: now.Add(time.Hour + 15*time.Minute),
+		},
+		{
+			name:             "missed occurrences of cron are skipped",
+			recurrence:       domain.Recurrence{Cron: "@hourly"},
+			execTime:         now,
+			now:              now.Add(5*time.Hour + time.Minute),
+			expectedOk:       true,
+			expectedExecTime: now.Add(6 * time.Hour),
+		},
+		{
+			name:             "delayed task is repeated by the time of its occurrence",
+			recurrence:       domain.Recurrence{Interval: 15 * 60, ScheduledTimeMs: now.Unix() * 1000},
+			execTime:         now.Add(40 * time.Second),
+			now:              now.Add(41 * time.Second),
+			expectedOk:       true,
+			expectedExecTime: now.Add(15 * time.Minute),
+		},
+		{
+			name:             "max occurrences is not reached",
+			recurrence:       domain.Recurrence{Interval: 60, MaxOccurrences: 3, Occurrence: 1},
+			execTime:         now,
+			now:              now,
+			expectedOk:       true,
+			expectedExecTime: now.Add(time.Minute),
+		},
+		{
+			name:       "max occurrences is reached",
+			recurrence: domain.Recurrence{Interval: 60, MaxOccurrences: 3, Occurrence: 2},
+			execTime:   now,
+			now:        now,
+			expectedOk: false,
+		},
+		{
+			name:             "end time is not reached",
+			recurrence:       domain.Recurrence{Interval: 60, EndTime: now.Add(time.Minute).Unix()},
+			execTime:         now,
+			now:              now,
+			expectedOk:       true,
+			expectedExecTime: now.Add(time.Minute),
+		},
+		{
+			name:       "end time is reached",
+			recurrence: domain.Recurrence{Interval: 60, EndTime: now.Add(time.Minute - time.Second).Unix()},
+			execTime:   now,
+			now:        now,
+			expectedOk: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recurrence := test.recurrence
+			next, ok, err := Next(domain.Task{ExecTime: test.execTime.Unix(), Recurrence: &recurrence}, test.now)
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedOk, ok)
+			if test.expectedOk {
+				assert.Equal(t, test.expectedExecTime.Unix(), next.ExecTime)
+				assert.Zero(t, next.Recurrence.ScheduledTimeMs, "the next occurrence is not delayed")
+			}
+		})
+	}
+
+	_, ok, err := Next(domain.Task{ExecTime: now.Unix()}, now)
+	assert.NoError(t, err)
+	assert.False(t, ok, "not recurrent task must not have the next occurrence")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		recurrence domain.Recurrence
+		isValid    bool
+	}{
+		{domain.Recurrence{Cron: "0 3 * * *"}, true},
+		{domain.Recurrence{Cron: "@daily", Location: "Europe/Berlin"}, true},
+		{domain.Recurrence{Interval: 900}, true},
+		{domain.Recurrence{}, false},
+		{domain.Recurrence{Cron: "0 3 * * *", Interval: 900}, false},
+		{domain.Recurrence{Interval: -1}, false},
+		{domain.Recurrence{Interval: 900, MaxOccurrences: -1}, false},
+		{domain.Recurrence{Cron: "0 3 * *"}, false},
+		{domain.Recurrence{Cron: "0 3 * * *", Location: "Mars/Olympus"}, false},
+	}
+
+	for _, test := range tests {
+		err := Validate(test.recurrence)
+		assert.Equal(t, test.isValid, err == nil, "recurrence: %+v, error: %v", test.recurrence, err)
+	}
+}
@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+/*
+	Count of the tasks inserted or found by one statement
+*/
+const batchStatementSize = 1000
+
+/*
+	Free space of the collection which is not filled yet
+*/
+type collectionSpace struct {
+	id    int64
+	space int
+}
+
+/*
+	The tasks with the same time of execution of the collection and the same queue are added to one collection
+*/
+type collectionKey struct {
+	execTime int64
+	queue    string
+}
+
+type batchRow struct {
+	task         domain.Task
+	collectionId int64
+}
+
+/*
+	Creates the tasks in the transaction. The tasks which exist or are repeated in the batch are not created,
+	as well as the tasks whose idempotency keys are reserved. The collections which are not filled yet
+	are filled first, the collections for the rest are created by createCollection
+*/
+func (o *Options) createBatch(
+	ctx context.Context,
+	tx *sql.Tx,
+	rebind func(query string) string,
+	tasks []domain.Task,
+	takenByInstance string,
+	createCollection func(key collectionKey) (int64, error),
+) ([]error, error) {
+
+	results := make([]error, len(tasks))
+
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.Id)
+	}
+
+	existing, err := findIds(ctx, tx, rebind, ids, "")
+	if err != nil {
+		return nil, err
+	}
+
+	reservedKeys, err := findReservedKeys(ctx, tx, rebind, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	//	The tasks are grouped by the collections in the order of the batch
+	var keys []collectionKey
+	var created []domain.Task
+	groups := make(map[collectionKey][]domain.Task)
+	for i, task := range tasks {
+		if existing[task.Id] {
+			results[i] = contracts.RepoErrorTaskExist
+			continue
+		}
+
+		if task.IdempotencyKey != "" {
+			if reservedKeys[task.IdempotencyKey] {
+				results[i] = contracts.RepoErrorIdempotencyKeyExist
+				continue
+			}
+			reservedKeys[task.IdempotencyKey] = true
+		}
+		existing[task.Id] = true
+		created = append(created, task)
+
+		key := collectionKey{execTime: o.collectionExecTime(task), queue: task.Queue}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], task)
+	}
+
+	var rows []batchRow
+	for _, key := range keys {
+		spaces, err := o.findCollectionSpaces(ctx, tx, rebind, key, takenByInstance)
+		if err != nil {
+			return nil, err
+		}
+
+		for group := groups[key]; len(group) > 0; {
+			var collection collectionSpace
+			if len(spaces) > 0 {
+				collection, spaces = spaces[0], spaces[1:]
+			} else {
+				id, err := createCollection(key)
+				if err != nil {
+					return nil, errors.Wrap(err, "creating collection error")
+				}
+				collection = collectionSpace{id: id, space: o.MaxCountTasksInCollection}
+			}
+
+			count := collection.space
+			if count > len(group) {
+				count = len(group)
+			}
+			for _, task := range group[:count] {
+				rows = append(rows, batchRow{task: task, collectionId: collection.id})
+			}
+			group = group[count:]
+		}
+	}
+
+	for len(rows) > 0 {
+		statementRows := rows
+		if len(statementRows) > batchStatementSize {
+			statementRows = statementRows[:batchStatementSize]
+		}
+		rows = rows[len(statementRows):]
+
+		if err := o.insertTasks(ctx, tx, rebind, statementRows); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := o.reserveIdempotencyKeys(ctx, tx, rebind, created); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (o *Options) findCollectionSpaces(
+	ctx context.Context,
+	tx *sql.Tx,
+	rebind func(query string) string,
+	key collectionKey,
+	takenByInstance string,
+) (spaces []collectionSpace, err error) {
+
+	findCollectionsQuery := `SELECT c.id, count(t.uuid)
+		FROM collection c LEFT JOIN task t ON c.id = t.collection_id
+		WHERE c.exec_time = ? AND c.taken_by_instance = ? AND c.queue = ?
+		GROUP BY c.id HAVING count(t.uuid) < ?
+		ORDER BY c.id`
+
+	rows, err := tx.QueryContext(
+		ctx,
+		rebind(findCollectionsQuery),
+		key.execTime,
+		takenByInstance,
+		key.queue,
+		o.MaxCountTasksInCollection,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "finding collections error")
+	}
+
+	defer func() {
+		if errClosing := rows.Close(); errClosing != nil && err == nil {
+			err = errors.Wrap(errClosing, "closing rows error")
+		}
+	}()
+
+	for rows.Next() {
+		var id int64
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, errors.Wrap(err, "scan error")
+		}
+		spaces = append(spaces, collectionSpace{id: id, space: o.MaxCountTasksInCollection - count})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "scan error")
+	}
+
+	return spaces, nil
+}
+
+func (o *Options) insertTasks(ctx context.Context, tx *sql.Tx, rebind func(query string) string, rows []batchRow) error {
+	args := make([]interface{}, 0, len(rows)*8)
+	for _, row := range rows {
+		headers, err := encodeHeaders(row.task.Headers)
+		if err != nil {
+			return err
+		}
+
+		recurrence, err := encodeRecurrence(row.task.Recurrence)
+		if err != nil {
+			return err
+		}
+
+		args = append(args,
+			row.task.Id,
+			row.collectionId,
+			o.taskExecTime(row.task),
+			row.task.Payload,
+			headers,
+			recurrence,
+			row.task.Priority,
+			row.task.IdempotencyKey,
+		)
+	}
+
+	insertTasksQuery := fmt.Sprintf(
+		"INSERT INTO task (uuid, collection_id, exec_time_ms, payload, headers, recurrence, priority, idempotency_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?)%s",
+		strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?)", len(rows)-1),
+	)
+
+	if _, err := tx.ExecContext(ctx, rebind(insertTasksQuery), args...); err != nil {
+		return errors.Wrap(err, "creating tasks error")
+	}
+
+	return nil
+}
+
+/*
+	Deletes the tasks in the transaction. The tasks are locked by lockClause before deleting,
+	so the tasks which are not found are known. The idempotency keys of the tasks are kept for the retention
+*/
+func (o *Options) deleteBatch(
+	ctx context.Context,
+	tx *sql.Tx,
+	rebind func(query string) string,
+	taskIds []string,
+	lockClause string,
+) ([]error, error) {
+
+	existing, err := findIds(ctx, tx, rebind, taskIds, lockClause)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]error, len(taskIds))
+	var ids []interface{}
+	for i, id := range taskIds {
+		if !existing[id] {
+			results[i] = contracts.RepoErrorTaskNotFound
+			continue
+		}
+		delete(existing, id)
+		ids = append(ids, id)
+	}
+
+	for len(ids) > 0 {
+		statementIds := ids
+		if len(statementIds) > batchStatementSize {
+			statementIds = statementIds[:batchStatementSize]
+		}
+		ids = ids[len(statementIds):]
+
+		if err := o.retainIdempotencyKeys(ctx, tx, rebind, statementIds); err != nil {
+			return nil, err
+		}
+
+		deleteTasksQuery := fmt.Sprintf("DELETE FROM task WHERE uuid IN (?%s)",
+			strings.Repeat(",?", len(statementIds)-1))
+
+		if _, err := tx.ExecContext(ctx, rebind(deleteTasksQuery), statementIds...); err != nil {
+			return nil, errors.Wrap(err, "deleting tasks error")
+		}
+	}
+
+	return results, nil
+}
+
+/*
+	Ids of the tasks which exist
+*/
+func findIds(
+	ctx context.Context,
+	tx *sql.Tx,
+	rebind func(query string) string,
+	taskIds []string,
+	lockClause string,
+) (map[string]bool, error) {
+
+	existing := make(map[string]bool, len(taskIds))
+	for len(taskIds) > 0 {
+		statementIds := taskIds
+		if len(statementIds) > batchStatementSize {
+			statementIds = statementIds[:batchStatementSize]
+		}
+		taskIds = taskIds[len(statementIds):]
+
+		args := make([]interface{}, 0, len(statementIds))
+		for _, id := range statementIds {
+			args = append(args, id)
+		}
+
+		findTasksQuery := fmt.Sprintf("SELECT uuid FROM task WHERE uuid IN (?%s)%s",
+			strings.Repeat(",?", len(statementIds)-1), lockClause)
+
+		if err := func() (err error) {
+			rows, err := tx.QueryContext(ctx, rebind(findTasksQuery), args...)
+			if err != nil {
+				return errors.Wrap(err, "finding tasks error")
+			}
+
+			defer func() {
+				if errClosing := rows.Close(); errClosing != nil && err == nil {
+					err = errors.Wrap(errClosing, "closing rows error")
+				}
+			}()
+
+			for rows.Next() {
+				var id string
+				if err := rows.Scan(&id); err != nil {
+					return errors.Wrap(err, "scan error")
+				}
+				existing[id] = true
+			}
+
+			return errors.Wrap(rows.Err(), "scan error")
+		}(); err != nil {
+			return nil, err
+		}
+	}
+
+	return existing, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+/*
+	The task which was dead lettered earlier is replaced
+*/
+const (
+	deleteDeadLetterQuery = "DELETE FROM dead_letter WHERE uuid = ?"
+	insertDeadLetterQuery = `INSERT INTO dead_letter
+		(uuid, exec_time, payload, headers, recurrence, attempts, priority, idempotency_key, queue, reason, dead_lettered_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	selectDeadLetterQuery = `SELECT uuid, exec_time, payload, headers, recurrence, attempts, priority, idempotency_key, queue, reason,
+		dead_lettered_at
+		FROM dead_letter`
+	findDeadLettersQuery  = selectDeadLetterQuery + " ORDER BY dead_lettered_at, uuid LIMIT ? OFFSET ?"
+	getDeadLetterQuery    = selectDeadLetterQuery + " WHERE uuid = ?"
+	purgeDeadLettersQuery = "DELETE FROM dead_letter WHERE dead_lettered_at < ?"
+)
+
+/*
+	Arguments of insertDeadLetterQuery
+*/
+func deadLetterArgs(task domain.Task, reason string) ([]interface{}, error) {
+	headers, err := encodeHeaders(task.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	recurrence, err := encodeRecurrence(task.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{
+		task.Id,
+		task.ExecTime,
+		task.Payload,
+		headers,
+		recurrence,
+		task.Attempts,
+		task.Priority,
+		task.IdempotencyKey,
+		task.Queue,
+		reason,
+		time.Now().Unix(),
+	}, nil
+}
+
+/*
+	Executes the query based on selectDeadLetterQuery
+*/
+func queryDeadLetters(ctx context.Context, client *sql.DB, query string, args ...interface{}) (
+	deadLetters []domain.DeadLetter, err error) {
+
+	rows, err := client.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	defer func() {
+		if errClosing := rows.Close(); errClosing != nil && err == nil {
+			err = errors.Wrap(errClosing, "closing rows error")
+		}
+	}()
+
+	for rows.Next() {
+		var deadLetter domain.DeadLetter
+		var headers, recurrence sql.NullString
+		if err := rows.Scan(
+			&deadLetter.Task.Id,
+			&deadLetter.Task.ExecTime,
+			&deadLetter.Task.Payload,
+			&headers,
+			&recurrence,
+			&deadLetter.Task.Attempts,
+			&deadLetter.Task.Priority,
+			&deadLetter.Task.IdempotencyKey,
+			&deadLetter.Task.Queue,
+			&deadLetter.Reason,
+			&deadLetter.DeadLetteredAt,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan error")
+		}
+
+		if deadLetter.Task.Headers, err = decodeHeaders(headers); err != nil {
+			return nil, err
+		}
+
+		if deadLetter.Task.Recurrence, err = decodeRecurrence(recurrence); err != nil {
+			return nil, err
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "scan error")
+	}
+
+	return deadLetters, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+func encodeHeaders(headers map[string]string) (sql.NullString, error) {
+	if headers == nil {
+		return sql.NullString{}, nil
+	}
+
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return sql.NullString{}, errors.Wrap(err, "encoding headers error")
+	}
+
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+func decodeHeaders(encoded sql.NullString) (headers map[string]string, err error) {
+	if !encoded.Valid {
+		return nil, nil
+	}
+
+	if err := json.Unmarshal([]byte(encoded.String), &headers); err != nil {
+		return nil, errors.Wrap(err, "decoding headers error")
+	}
+
+	return headers, nil
+}
+
+func encodeRecurrence(recurrence *domain.Recurrence) (sql.NullString, error) {
+	if recurrence == nil {
+		return sql.NullString{}, nil
+	}
+
+	encoded, err := json.Marshal(recurrence)
+	if err != nil {
+		return sql.NullString{}, errors.Wrap(err, "encoding recurrence error")
+	}
+
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+func decodeRecurrence(encoded sql.NullString) (*domain.Recurrence, error) {
+	if !encoded.Valid {
+		return nil, nil
+	}
+
+	recurrence := &domain.Recurrence{}
+	if err := json.Unmarshal([]byte(encoded.String), recurrence); err != nil {
+		return nil, errors.Wrap(err, "decoding recurrence error")
+	}
+
+	return recurrence, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
+)
+
+/*
+	Converts the time to the unit of the times of execution stored in the database
+*/
+func (o *Options) toStored(t time.Time) int64 {
+	if o.Milliseconds {
+		return util.ToMs(t)
+	}
+
+	return t.Unix()
+}
+
+/*
+	Time of execution of the collection of the task. In the mode of milliseconds the tasks
+	are grouped by the ranges of CollectionWidth, the exact time is stored in the task.
+	In the mode of seconds the time in milliseconds is rounded up, so the task is not executed earlier
+*/
+func (o *Options) collectionExecTime(task domain.Task) int64 {
+	if !o.Milliseconds {
+		return (task.ExecTimeInMs() + 999) / 1000
+	}
+
+	execTime := task.ExecTimeInMs()
+	if width := o.CollectionWidth.Milliseconds(); width > 1 {
+		execTime -= execTime % width
+	}
+
+	return execTime
+}
+
+/*
+	The time of execution of the task is stored only in the mode of milliseconds
+*/
+func (o *Options) taskExecTime(task domain.Task) sql.NullInt64 {
+	if !o.Milliseconds {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: task.ExecTimeInMs(), Valid: true}
+}
+
+func (o *Options) restoreExecTime(task *domain.Task, collectionExecTime int64, taskExecTime sql.NullInt64) {
+	switch {
+	case o.Milliseconds && taskExecTime.Valid:
+		task.SetExecTimeMs(taskExecTime.Int64)
+	case o.Milliseconds:
+		task.SetExecTimeMs(collectionExecTime)
+	default:
+		task.ExecTime, task.ExecTimeMs = collectionExecTime, 0
+	}
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
+)
+
+/*
+	The idempotency key is reserved while the task exists and until the reservation expires after the task
+	is deleted. The released key is deleted when the key is used again or by the cleaning
+*/
+const (
+	getIdByIdempotencyKeyQuery = "SELECT uuid FROM idempotency_key WHERE idempotency_key = ?"
+	releasedKeyCondition       = "expires_at < ? AND NOT EXISTS(SELECT t.uuid FROM task t WHERE t.uuid = idempotency_key.uuid)"
+	deleteReleasedKeysQuery    = "DELETE FROM idempotency_key WHERE " + releasedKeyCondition
+)
+
+/*
+	The statements are executed by the client or in the transaction
+*/
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+/*
+	The key is reserved until the retention is over after the time of execution at least,
+	the reservation is prolonged when the task is deleted later
+*/
+func (o *Options) idempotencyKeyExpiresAt(task domain.Task) int64 {
+	return util.FromMs(task.ExecTimeInMs()).Add(o.IdempotencyKeyRetention).Unix()
+}
+
+/*
+	Idempotency keys of the tasks which are reserved by other tasks.
+	The released keys of the tasks are deleted, so they may be reserved again
+*/
+func findReservedKeys(
+	ctx context.Context,
+	tx *sql.Tx,
+	rebind func(query string) string,
+	tasks []domain.Task,
+) (map[string]bool, error) {
+
+	var keys []interface{}
+	isRepeated := make(map[string]bool)
+	for _, task := range tasks {
+		if task.IdempotencyKey != "" && !isRepeated[task.IdempotencyKey] {
+			isRepeated[task.IdempotencyKey] = true
+			keys = append(keys, task.IdempotencyKey)
+		}
+	}
+
+	reserved := make(map[string]bool, len(keys))
+	now := time.Now().Unix()
+	for len(keys) > 0 {
+		statementKeys := keys
+		if len(statementKeys) > batchStatementSize {
+			statementKeys = statementKeys[:batchStatementSize]
+		}
+		keys = keys[len(statementKeys):]
+
+		placeholders := "?" + strings.Repeat(",?", len(statementKeys)-1)
+
+		deleteKeysQuery := fmt.Sprintf("DELETE FROM idempotency_key WHERE idempotency_key IN (%s) AND %s",
+			placeholders, releasedKeyCondition)
+
+		args := append(append([]interface{}{}, statementKeys...), now)
+		if _, err := tx.ExecContext(ctx, rebind(deleteKeysQuery), args...); err != nil {
+			return nil, errors.Wrap(err, "deleting released idempotency keys error")
+		}
+
+		findKeysQuery := fmt.Sprintf("SELECT idempotency_key FROM idempotency_key WHERE idempotency_key IN (%s)",
+			placeholders)
+
+		if err := func() (err error) {
+			rows, err := tx.QueryContext(ctx, rebind(findKeysQuery), statementKeys...)
+			if err != nil {
+				return errors.Wrap(err, "finding idempotency keys error")
+			}
+
+			defer func() {
+				if errClosing := rows.Close(); errClosing != nil && err == nil {
+					err = errors.Wrap(errClosing, "closing rows error")
+				}
+			}()
+
+			for rows.Next() {
+				var key string
+				if err := rows.Scan(&key); err != nil {
+					return errors.Wrap(err, "scan error")
+				}
+				reserved[key] = true
+			}
+
+			return errors.Wrap(rows.Err(), "scan error")
+		}(); err != nil {
+			return nil, err
+		}
+	}
+
+	return reserved, nil
+}
+
+/*
+	Reserves the idempotency keys of the created tasks. The key reserved by the concurrent transaction
+	fails the statement with the unique violation, see reservingError
+*/
+func (o *Options) reserveIdempotencyKeys(
+	ctx context.Context,
+	tx *sql.Tx,
+	rebind func(query string) string,
+	tasks []domain.Task,
+) error {
+
+	var args []interface{}
+	count := 0
+	for _, task := range tasks {
+		if task.IdempotencyKey == "" {
+			continue
+		}
+		args = append(args, task.IdempotencyKey, task.Id, o.idempotencyKeyExpiresAt(task))
+		count++
+	}
+
+	for count > 0 {
+		statementCount := count
+		if statementCount > batchStatementSize {
+			statementCount = batchStatementSize
+		}
+		count -= statementCount
+
+		insertKeysQuery := fmt.Sprintf(
+			"INSERT INTO idempotency_key (idempotency_key, uuid, expires_at) VALUES (?, ?, ?)%s",
+			strings.Repeat(", (?, ?, ?)", statementCount-1),
+		)
+
+		if _, err := tx.ExecContext(ctx, rebind(insertKeysQuery), args[:statementCount*3]...); err != nil {
+			return errors.Wrap(err, "reserving idempotency keys error")
+		}
+		args = args[statementCount*3:]
+	}
+
+	return nil
+}
+
+/*
+	The unique violation of reserving the key is caused by the key reserved concurrently,
+	not by the task with the same uuid, so the id of the existing task is found by the key
+*/
+func reservingError(err error) error {
+	if err == contracts.RepoErrorTaskExist {
+		return contracts.RepoErrorIdempotencyKeyExist
+	}
+
+	return err
+}
+
+/*
+	Prolongs the reservation of the idempotency keys of the deleted tasks for the retention
+*/
+func (o *Options) retainIdempotencyKeys(
+	ctx context.Context,
+	client execer,
+	rebind func(query string) string,
+	taskIds []interface{},
+) error {
+
+	if len(taskIds) == 0 {
+		return nil
+	}
+
+	retainKeysQuery := fmt.Sprintf("UPDATE idempotency_key SET expires_at = ? WHERE uuid IN (?%s)",
+		strings.Repeat(",?", len(taskIds)-1))
+
+	args := append([]interface{}{time.Now().Add(o.IdempotencyKeyRetention).Unix()}, taskIds...)
+	if _, err := client.ExecContext(ctx, rebind(retainKeysQuery), args...); err != nil {
+		return errors.Wrap(err, "retaining idempotency keys error")
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package repository
+
+import "time"
+
+/*
+	Collections of the instance are returned to the pool when the instance is unregistered
+*/
+const (
+	releaseInstanceQuery     = "UPDATE collection SET taken_by_instance = '' WHERE taken_by_instance = ?"
+	deleteInstanceQuery      = "DELETE FROM instance WHERE id = ?"
+	deleteDeadInstancesQuery = "DELETE FROM instance WHERE heartbeat_at < ?"
+)
+
+/*
+	The instance which has sent the heartbeat after this time (unix) is alive
+*/
+func aliveSince(instanceTimeout time.Duration) int64 {
+	return time.Now().Add(-instanceTimeout).Unix()
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+const (
+	selectTaskQuery = `SELECT t.uuid, c.exec_time, t.exec_time_ms, t.payload, t.headers, t.recurrence, t.attempts, t.priority, t.idempotency_key, c.queue
+		FROM task t
+		INNER JOIN collection c ON t.collection_id = c.id`
+	getTaskQuery = selectTaskQuery + " WHERE t.uuid = ?"
+)
+
+/*
+	The stored task with the time of execution of the given task, as it is restored from the database
+*/
+func (o *Options) rescheduled(stored domain.Task, task domain.Task) domain.Task {
+	o.restoreExecTime(&stored, o.collectionExecTime(task), o.taskExecTime(task))
+
+	return stored
+}
+
+/*
+	In the mode of milliseconds the exact time of execution is stored in the task
+*/
+func (o *Options) execTimeColumn() string {
+	if o.Milliseconds {
+		return "t.exec_time_ms"
+	}
+
+	return "c.exec_time"
+}
+
+/*
+	Query based on selectTaskQuery and its arguments
+*/
+func (o *Options) listTasksQuery(filter contracts.TaskFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if !filter.ExecTimeFrom.IsZero() {
+		conditions = append(conditions, o.execTimeColumn()+" >= ?")
+		args = append(args, o.toStored(filter.ExecTimeFrom))
+	}
+
+	if !filter.ExecTimeTo.IsZero() {
+		conditions = append(conditions, o.execTimeColumn()+" < ?")
+		args = append(args, o.toStored(filter.ExecTimeTo))
+	}
+
+	if filter.Taken != nil {
+		if *filter.Taken {
+			conditions = append(conditions, "c.taken_by_instance != ''")
+		} else {
+			conditions = append(conditions, "c.taken_by_instance = ''")
+		}
+	}
+
+	if filter.TakenBy != "" {
+		conditions = append(conditions, "c.taken_by_instance = ?")
+		args = append(args, filter.TakenBy)
+	}
+
+	query := selectTaskQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + o.execTimeColumn() + ", t.uuid LIMIT ? OFFSET ?"
+
+	return query, append(args, filter.Limit, filter.Offset)
+}
+
+/*
+	The tasks are queried by the client or in the transaction
+*/
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+/*
+	Executes the query based on selectTaskQuery
+*/
+func (o *Options) queryTasks(ctx context.Context, client queryer, query string, args ...interface{}) (
+	tasks []domain.Task, err error) {
+
+	rows, err := client.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	defer func() {
+		if errClosing := rows.Close(); errClosing != nil && err == nil {
+			err = errors.Wrap(errClosing, "closing rows error")
+		}
+	}()
+
+	tasks = []domain.Task{}
+	for rows.Next() {
+		var task domain.Task
+		var collectionExecTime int64
+		var taskExecTime sql.NullInt64
+		var headers, recurrence sql.NullString
+		if err := rows.Scan(
+			&task.Id,
+			&collectionExecTime,
+			&taskExecTime,
+			&task.Payload,
+			&headers,
+			&recurrence,
+			&task.Attempts,
+			&task.Priority,
+			&task.IdempotencyKey,
+			&task.Queue,
+		); err != nil {
+			return nil, errors.Wrap(err, "scan error")
+		}
+		o.restoreExecTime(&task, collectionExecTime, taskExecTime)
+
+		if task.Headers, err = decodeHeaders(headers); err != nil {
+			return nil, err
+		}
+
+		if task.Recurrence, err = decodeRecurrence(recurrence); err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "scan error")
+	}
+
+	return tasks, nil
+}
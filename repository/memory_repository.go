@@ -0,0 +1,669 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
+)
+
+/*
+	The repository keeps tasks in the memory of the process. Tasks are lost when the application stops,
+	so it is suitable for tests and schedulers which do not need durability.
+	Tasks are grouped into collections in the same way as in the database.
+*/
+func NewMemory(
+	appInstanceId string,
+	eh contracts.EventHandlerInterface,
+	options *Options,
+) contracts.RepositoryInterface {
+
+	return &memoryRepository{
+		appInstanceId:         appInstanceId,
+		eh:                    eh,
+		options:               mergeDefaultOptions(options),
+		collections:           make(map[int64]*memoryCollection),
+		collectionsByExecTime: make(map[int64][]int64),
+		collectionIdByTaskId:  make(map[string]int64),
+		deadLetters:           make(map[string]memoryDeadLetter),
+		idempotencyKeys:       make(map[string]memoryIdempotencyKey),
+		instances:             make(map[string]int64),
+	}
+}
+
+type memoryCollection struct {
+	id              int64
+	execTime        int64
+	takenByInstance string
+	queue           string
+	tasks           map[string]domain.Task
+}
+
+type memoryDeadLetter struct {
+	task           domain.Task
+	reason         string
+	deadLetteredAt int64
+}
+
+func (d memoryDeadLetter) toDomain() domain.DeadLetter {
+	return domain.DeadLetter{
+		Task:           copyTask(d.task),
+		Reason:         d.reason,
+		DeadLetteredAt: d.deadLetteredAt,
+	}
+}
+
+/*
+	The key is released when it expires and the task does not exist
+*/
+type memoryIdempotencyKey struct {
+	taskId    string
+	expiresAt int64
+}
+
+type memoryRepository struct {
+	sync.RWMutex
+	appInstanceId         string
+	eh                    contracts.EventHandlerInterface
+	options               *Options
+	lastCollectionId      int64
+	collections           map[int64]*memoryCollection
+	collectionsByExecTime map[int64][]int64
+	collectionIdByTaskId  map[string]int64
+	deadLetters           map[string]memoryDeadLetter
+	idempotencyKeys       map[string]memoryIdempotencyKey
+
+	/*
+		Time of the last heartbeat of the instances
+	*/
+	instances map[string]int64
+}
+
+func (r *memoryRepository) Count() (int, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	return len(r.collectionIdByTaskId), nil
+}
+
+func (r *memoryRepository) Create(ctx context.Context, task domain.Task, isTaken bool) error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.createWithIdempotencyKey(task, isTaken)
+}
+
+/*
+	Creates the task which is new and reserves its idempotency key
+*/
+func (r *memoryRepository) createWithIdempotencyKey(task domain.Task, isTaken bool) error {
+	if _, ok := r.collectionIdByTaskId[task.Id]; ok {
+		return contracts.RepoErrorTaskExist
+	}
+
+	if task.IdempotencyKey == "" {
+		return r.create(task, isTaken)
+	}
+
+	if r.isReserved(task.IdempotencyKey, time.Now().Unix()) {
+		return contracts.RepoErrorIdempotencyKeyExist
+	}
+
+	if err := r.create(task, isTaken); err != nil {
+		return err
+	}
+	r.idempotencyKeys[task.IdempotencyKey] = memoryIdempotencyKey{
+		taskId:    task.Id,
+		expiresAt: r.options.idempotencyKeyExpiresAt(task),
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) isReserved(idempotencyKey string, now int64) bool {
+	key, ok := r.idempotencyKeys[idempotencyKey]
+	if !ok {
+		return false
+	}
+	_, isTaskExist := r.collectionIdByTaskId[key.taskId]
+
+	return isTaskExist || key.expiresAt >= now
+}
+
+/*
+	Prolongs the reservation of the idempotency key of the task which is deleted for the retention
+*/
+func (r *memoryRepository) retainIdempotencyKey(taskId string) {
+	collectionId, ok := r.collectionIdByTaskId[taskId]
+	if !ok {
+		return
+	}
+
+	idempotencyKey := r.collections[collectionId].tasks[taskId].IdempotencyKey
+	if key, ok := r.idempotencyKeys[idempotencyKey]; ok && key.taskId == taskId {
+		key.expiresAt = time.Now().Add(r.options.IdempotencyKeyRetention).Unix()
+		r.idempotencyKeys[idempotencyKey] = key
+	}
+}
+
+/*
+	Adds the task to the collection with the same time of execution which is not filled yet.
+	If there is no such collection, a new one is created.
+*/
+func (r *memoryRepository) create(task domain.Task, isTaken bool) error {
+	if _, ok := r.collectionIdByTaskId[task.Id]; ok {
+		return contracts.RepoErrorTaskExist
+	}
+
+	takenByInstance := ""
+	if isTaken {
+		takenByInstance = r.appInstanceId
+	}
+
+	execTime := r.options.collectionExecTime(task)
+
+	var collection *memoryCollection
+	for _, id := range r.collectionsByExecTime[execTime] {
+		c := r.collections[id]
+		if c.takenByInstance == takenByInstance && c.queue == task.Queue &&
+			len(c.tasks) < r.options.MaxCountTasksInCollection {
+
+			collection = c
+			break
+		}
+	}
+
+	if collection == nil {
+		r.lastCollectionId++
+		collection = &memoryCollection{
+			id:              r.lastCollectionId,
+			execTime:        execTime,
+			takenByInstance: takenByInstance,
+			queue:           task.Queue,
+			tasks:           make(map[string]domain.Task),
+		}
+		r.collections[collection.id] = collection
+		r.collectionsByExecTime[execTime] = append(r.collectionsByExecTime[execTime], collection.id)
+	}
+
+	task = copyTask(task)
+	task.Attempts = 0
+	collection.tasks[task.Id] = task
+	r.collectionIdByTaskId[task.Id] = collection.id
+
+	return nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, tasks []domain.Task) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var deleted int64
+	for _, task := range tasks {
+		r.retainIdempotencyKey(task.Id)
+		if r.delete(task.Id) {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+/*
+	Empty collections are deleted at once, there is no need to clean them up later
+*/
+func (r *memoryRepository) delete(taskId string) bool {
+	collectionId, ok := r.collectionIdByTaskId[taskId]
+	if !ok {
+		return false
+	}
+
+	delete(r.collectionIdByTaskId, taskId)
+
+	collection := r.collections[collectionId]
+	delete(collection.tasks, taskId)
+	if len(collection.tasks) > 0 {
+		return true
+	}
+
+	delete(r.collections, collectionId)
+
+	ids := r.collectionsByExecTime[collection.execTime]
+	for i, id := range ids {
+		if id == collectionId {
+			ids = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(r.collectionsByExecTime, collection.execTime)
+	} else {
+		r.collectionsByExecTime[collection.execTime] = ids
+	}
+
+	return true
+}
+
+func (r *memoryRepository) CreateBatch(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	results := make([]error, len(tasks))
+	for i, task := range tasks {
+		results[i] = r.createWithIdempotencyKey(task, isTaken)
+	}
+
+	return results, nil
+}
+
+func (r *memoryRepository) DeleteBatch(ctx context.Context, taskIds []string) ([]error, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	results := make([]error, len(taskIds))
+	for i, taskId := range taskIds {
+		r.retainIdempotencyKey(taskId)
+		if !r.delete(taskId) {
+			results[i] = contracts.RepoErrorTaskNotFound
+		}
+	}
+
+	return results, nil
+}
+
+func (r *memoryRepository) DeleteAndCreate(ctx context.Context, tasks []domain.Task, nextTasks []domain.Task) (
+	deleted int64, created int64, error error) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	/*
+		The next occurrence must not be created if the task was deleted by someone else
+	*/
+	existingTasks := make(map[string]bool, len(nextTasks))
+	for _, task := range nextTasks {
+		_, existingTasks[task.Id] = r.collectionIdByTaskId[task.Id]
+	}
+
+	for _, task := range tasks {
+		r.retainIdempotencyKey(task.Id)
+		if r.delete(task.Id) {
+			deleted++
+		}
+	}
+
+	for _, task := range nextTasks {
+		if !existingTasks[task.Id] {
+			continue
+		}
+
+		if err := r.create(task, false); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task": task})
+
+			return deleted, created, contracts.RepoErrorCreatingTask
+		}
+		created++
+	}
+
+	return
+}
+
+func (r *memoryRepository) FindBySecToExecTime(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (
+	collection contracts.CollectionsInterface, error error) {
+
+	toNextExecTime := r.options.toStored(time.Now().Add(preloadingTimeRange))
+	alive := aliveSince(r.options.InstanceTimeout)
+
+	r.Lock()
+	defer r.Unlock()
+
+	var takenCollections []*memoryCollection
+	for _, c := range r.collections {
+		if c.execTime <= toNextExecTime && c.takenByInstance != r.appInstanceId && !r.isAlive(c.takenByInstance, alive) &&
+			inQueues(c.queue, queues) {
+			c.takenByInstance = r.appInstanceId
+			takenCollections = append(takenCollections, c)
+		}
+	}
+
+	if len(takenCollections) == 0 {
+		return nil, contracts.RepoErrorNoTasksFound
+	}
+
+	sort.Slice(takenCollections, func(i, j int) bool {
+		if takenCollections[i].execTime == takenCollections[j].execTime {
+			return takenCollections[i].id < takenCollections[j].id
+		}
+		return takenCollections[i].execTime < takenCollections[j].execTime
+	})
+
+	collectionIds := make([]int64, 0, len(takenCollections))
+	for _, c := range takenCollections {
+		collectionIds = append(collectionIds, c.id)
+	}
+
+	collection = &Collections{
+		collections: collectionIds,
+		r:           r,
+	}
+
+	return
+}
+
+func (r *memoryRepository) getTasksByCollection(ctx context.Context, collectionId int64) ([]domain.Task, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	collection, ok := r.collections[collectionId]
+	if !ok {
+		return nil, nil
+	}
+
+	tasks := make([]domain.Task, 0, len(collection.tasks))
+	for taskId := range collection.tasks {
+		tasks = append(tasks, r.restoreTask(collection, taskId))
+	}
+
+	return tasks, nil
+}
+
+/*
+	The precision of the time of execution is the same as in the database
+*/
+func (r *memoryRepository) restoreTask(collection *memoryCollection, taskId string) domain.Task {
+	task := copyTask(collection.tasks[taskId])
+	r.options.restoreExecTime(&task, collection.execTime, r.options.taskExecTime(task))
+
+	return task
+}
+
+func (r *memoryRepository) UpdateAttempts(ctx context.Context, task domain.Task) error {
+	r.Lock()
+	defer r.Unlock()
+
+	collectionId, ok := r.collectionIdByTaskId[task.Id]
+	if !ok {
+		return nil
+	}
+
+	storedTask := r.collections[collectionId].tasks[task.Id]
+	storedTask.Attempts = task.Attempts
+	r.collections[collectionId].tasks[task.Id] = storedTask
+
+	return nil
+}
+
+func (r *memoryRepository) MoveToDeadLetter(ctx context.Context, task domain.Task, reason string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.retainIdempotencyKey(task.Id)
+	if !r.delete(task.Id) {
+		return contracts.RepoErrorTaskNotFound
+	}
+
+	r.deadLetters[task.Id] = memoryDeadLetter{
+		task:           copyTask(task),
+		reason:         reason,
+		deadLetteredAt: time.Now().Unix(),
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	collectionId, ok := r.collectionIdByTaskId[taskId]
+	if !ok {
+		return domain.Task{}, contracts.RepoErrorTaskNotFound
+	}
+
+	return r.restoreTask(r.collections[collectionId], taskId), nil
+}
+
+func (r *memoryRepository) GetIdByIdempotencyKey(ctx context.Context, idempotencyKey string) (string, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	key, ok := r.idempotencyKeys[idempotencyKey]
+	if !ok {
+		return "", contracts.RepoErrorTaskNotFound
+	}
+
+	return key.taskId, nil
+}
+
+func (r *memoryRepository) List(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	tasks := make([]domain.Task, 0)
+	for _, collection := range r.collections {
+		if filter.Taken != nil && *filter.Taken != (collection.takenByInstance != "") ||
+			filter.TakenBy != "" && filter.TakenBy != collection.takenByInstance {
+
+			continue
+		}
+
+		for taskId := range collection.tasks {
+			task := r.restoreTask(collection, taskId)
+			execTime := task.ExecTimeInMs()
+			if !filter.ExecTimeFrom.IsZero() && execTime < util.ToMs(filter.ExecTimeFrom) ||
+				!filter.ExecTimeTo.IsZero() && execTime >= util.ToMs(filter.ExecTimeTo) {
+
+				continue
+			}
+			tasks = append(tasks, task)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].ExecTimeInMs() == tasks[j].ExecTimeInMs() {
+			return tasks[i].Id < tasks[j].Id
+		}
+		return tasks[i].ExecTimeInMs() < tasks[j].ExecTimeInMs()
+	})
+
+	if filter.Offset >= len(tasks) {
+		return []domain.Task{}, nil
+	}
+	tasks = tasks[filter.Offset:]
+	if len(tasks) > filter.Limit {
+		tasks = tasks[:filter.Limit]
+	}
+
+	return tasks, nil
+}
+
+func (r *memoryRepository) Reschedule(ctx context.Context, task domain.Task, isTaken bool) (domain.Task, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	collectionId, ok := r.collectionIdByTaskId[task.Id]
+	if !ok {
+		return domain.Task{}, contracts.RepoErrorTaskNotFound
+	}
+	rescheduled := r.options.rescheduled(r.restoreTask(r.collections[collectionId], task.Id), task)
+
+	r.delete(task.Id)
+	if err := r.create(rescheduled, isTaken); err != nil {
+		return domain.Task{}, err
+	}
+
+	//	The task is created without attempts
+	collection := r.collections[r.collectionIdByTaskId[task.Id]]
+	storedTask := collection.tasks[task.Id]
+	storedTask.Attempts = rescheduled.Attempts
+	collection.tasks[task.Id] = storedTask
+
+	return rescheduled, nil
+}
+
+func (r *memoryRepository) FindDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	deadLetters := make([]domain.DeadLetter, 0, len(r.deadLetters))
+	for _, deadLetter := range r.deadLetters {
+		deadLetters = append(deadLetters, deadLetter.toDomain())
+	}
+
+	sort.Slice(deadLetters, func(i, j int) bool {
+		if deadLetters[i].DeadLetteredAt == deadLetters[j].DeadLetteredAt {
+			return deadLetters[i].Task.Id < deadLetters[j].Task.Id
+		}
+		return deadLetters[i].DeadLetteredAt < deadLetters[j].DeadLetteredAt
+	})
+
+	if offset >= len(deadLetters) {
+		return []domain.DeadLetter{}, nil
+	}
+	deadLetters = deadLetters[offset:]
+	if len(deadLetters) > limit {
+		deadLetters = deadLetters[:limit]
+	}
+
+	return deadLetters, nil
+}
+
+func (r *memoryRepository) GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error) {
+	r.RLock()
+	defer r.RUnlock()
+
+	deadLetter, ok := r.deadLetters[taskId]
+	if !ok {
+		return domain.DeadLetter{}, contracts.RepoErrorTaskNotFound
+	}
+
+	return deadLetter.toDomain(), nil
+}
+
+func (r *memoryRepository) Requeue(ctx context.Context, task domain.Task, isTaken bool) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.deadLetters[task.Id]; !ok {
+		return contracts.RepoErrorTaskNotFound
+	}
+
+	if err := r.create(task, isTaken); err != nil {
+		return err
+	}
+	delete(r.deadLetters, task.Id)
+
+	return nil
+}
+
+func (r *memoryRepository) PurgeDeadLetters(ctx context.Context, deadLetteredBefore int64) (int64, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	var deleted int64
+	for id, deadLetter := range r.deadLetters {
+		if deadLetter.deadLetteredAt < deadLetteredBefore {
+			delete(r.deadLetters, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func (r *memoryRepository) Release(ctx context.Context, tasks []domain.Task) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, task := range tasks {
+		collectionId, ok := r.collectionIdByTaskId[task.Id]
+		if !ok {
+			continue
+		}
+
+		if collection := r.collections[collectionId]; collection.takenByInstance == r.appInstanceId {
+			collection.takenByInstance = ""
+		}
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) Heartbeat(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+
+	r.instances[r.appInstanceId] = time.Now().Unix()
+
+	alive := aliveSince(r.options.InstanceTimeout)
+	for id, heartbeatAt := range r.instances {
+		if heartbeatAt < alive {
+			delete(r.instances, id)
+		}
+	}
+
+	//	The released keys are cleaned up with the dead instances
+	now := time.Now().Unix()
+	for idempotencyKey := range r.idempotencyKeys {
+		if !r.isReserved(idempotencyKey, now) {
+			delete(r.idempotencyKeys, idempotencyKey)
+		}
+	}
+
+	return nil
+}
+
+/*
+	The instance is alive if it has sent the heartbeat after the time
+*/
+func (r *memoryRepository) isAlive(instanceId string, since int64) bool {
+	heartbeatAt, ok := r.instances[instanceId]
+
+	return ok && heartbeatAt >= since
+}
+
+func (r *memoryRepository) Unregister(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, collection := range r.collections {
+		if collection.takenByInstance == r.appInstanceId {
+			collection.takenByInstance = ""
+		}
+	}
+	delete(r.instances, r.appInstanceId)
+
+	return nil
+}
+
+func (r *memoryRepository) Up() error {
+	return nil
+}
+
+/*
+	The stored task must not be changed by the code that created or received it
+*/
+func copyTask(task domain.Task) domain.Task {
+	if task.Payload != nil {
+		task.Payload = append([]byte(nil), task.Payload...)
+	}
+
+	if task.Headers != nil {
+		headers := make(map[string]string, len(task.Headers))
+		for key, value := range task.Headers {
+			headers[key] = value
+		}
+		task.Headers = headers
+	}
+
+	if task.Recurrence != nil {
+		recurrence := *task.Recurrence
+		task.Recurrence = &recurrence
+	}
+
+	return task
+}
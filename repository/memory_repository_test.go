@@ -0,0 +1,316 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/error_service"
+	"github.com/pvelx/triggerhook/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCreate(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, &Options{MaxCountTasksInCollection: 2})
+	execTime := time.Now().Unix()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, repository.Create(context.Background(), domain.Task{Id: util.NewId(), ExecTime: execTime}, false))
+	}
+	assert.NoError(t, repository.Create(context.Background(), domain.Task{Id: util.NewId(), ExecTime: execTime}, true))
+
+	task := domain.Task{Id: util.NewId(), ExecTime: execTime}
+	assert.NoError(t, repository.Create(context.Background(), task, false))
+	assert.Equal(t, contracts.RepoErrorTaskExist, repository.Create(context.Background(), task, false))
+
+	count, err := repository.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	memory := repository.(*memoryRepository)
+	assert.Len(t, memory.collections, 3, "tasks are packed into collections incorrectly")
+	for _, collection := range memory.collections {
+		assert.LessOrEqual(t, len(collection.tasks), 2)
+	}
+}
+
+func TestMemoryFindBySecToExecTime(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+	now := time.Now().Unix()
+
+	tasks := []struct {
+		task    domain.Task
+		isTaken bool
+	}{
+		{domain.Task{Id: "task-5", ExecTime: now + 5}, false},
+		{domain.Task{Id: "task-1", ExecTime: now - 1}, false},
+		{domain.Task{Id: "task-2", ExecTime: now + 2, Payload: []byte("payload")}, false},
+		{domain.Task{Id: "task-taken", ExecTime: now + 1}, true},
+		{domain.Task{Id: "task-100", ExecTime: now + 100}, false},
+	}
+	for _, item := range tasks {
+		assert.NoError(t, repository.Create(context.Background(), item.task, item.isTaken))
+	}
+
+	collections, err := repository.FindBySecToExecTime(context.Background(), 10*time.Second, nil)
+	assert.NoError(t, err)
+
+	var actualTasks []domain.Task
+	for {
+		tasks, err := collections.Next(context.Background())
+		if err == contracts.RepoErrorNoCollections {
+			break
+		}
+		assert.NoError(t, err)
+		actualTasks = append(actualTasks, tasks...)
+	}
+
+	assert.Equal(t, []domain.Task{
+		{Id: "task-1", ExecTime: now - 1},
+		{Id: "task-2", ExecTime: now + 2, Payload: []byte("payload")},
+		{Id: "task-5", ExecTime: now + 5},
+	}, actualTasks, "tasks must be taken in order of execution time, taken tasks must be skipped")
+
+	_, err = repository.FindBySecToExecTime(context.Background(), 10*time.Second, nil)
+	assert.Equal(t, contracts.RepoErrorNoTasksFound, err, "the collections must be taken once")
+
+	otherRepository := repository.(*memoryRepository)
+	otherRepository.appInstanceId = util.NewId()
+	collections, err = otherRepository.FindBySecToExecTime(context.Background(), 10*time.Second, nil)
+	assert.NoError(t, err, "collections taken by other instance must be taken again")
+	tasksOfCollection, err := collections.Next(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "task-1", tasksOfCollection[0].Id)
+}
+
+func TestMemoryDeleteAndCreate(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+	now := time.Now().Unix()
+
+	assert.NoError(t, repository.Create(context.Background(), domain.Task{Id: "recurrent", ExecTime: now}, true))
+	assert.NoError(t, repository.Create(context.Background(), domain.Task{Id: "simple", ExecTime: now}, true))
+
+	deleted, created, err := repository.DeleteAndCreate(
+		context.Background(),
+		[]domain.Task{{Id: "recurrent"}, {Id: "simple"}, {Id: "deleted"}},
+		[]domain.Task{{Id: "recurrent", ExecTime: now + 60}, {Id: "deleted", ExecTime: now + 60}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.Equal(t, int64(1), created, "the next occurrence of the deleted task must not be created")
+
+	memory := repository.(*memoryRepository)
+	assert.Len(t, memory.collections, 1, "empty collections must be deleted")
+	assert.Len(t, memory.collectionsByExecTime, 1, "empty collections must be deleted")
+
+	collectionId := memory.collectionIdByTaskId["recurrent"]
+	assert.Equal(t, now+60, memory.collections[collectionId].execTime)
+	assert.Equal(t, "", memory.collections[collectionId].takenByInstance, "the next occurrence must not be taken")
+}
+
+func TestMemoryMoveToDeadLetter(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix(), Attempts: 5}
+
+	assert.NoError(t, repository.Create(context.Background(), task, true))
+
+	memory := repository.(*memoryRepository)
+	collectionId := memory.collectionIdByTaskId[task.Id]
+	assert.Equal(t, 0, memory.collections[collectionId].tasks[task.Id].Attempts, "the new task has no attempts")
+
+	task.Attempts = 2
+	assert.NoError(t, repository.UpdateAttempts(context.Background(), task))
+	assert.Equal(t, 2, memory.collections[collectionId].tasks[task.Id].Attempts)
+
+	assert.NoError(t, repository.MoveToDeadLetter(context.Background(), task, "reason"))
+	assert.Len(t, memory.collections, 0)
+	assert.Equal(t, task, memory.deadLetters[task.Id].task)
+	assert.Equal(t, "reason", memory.deadLetters[task.Id].reason)
+
+	assert.Equal(t, contracts.RepoErrorTaskNotFound, repository.MoveToDeadLetter(context.Background(), task, "reason"))
+}
+
+func TestMemoryDeadLetters(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+	now := time.Now().Unix()
+
+	ids := []string{"task-1", "task-2", "task-3"}
+	for _, id := range ids {
+		task := domain.Task{Id: id, ExecTime: now}
+		assert.NoError(t, repository.Create(context.Background(), task, false))
+		assert.NoError(t, repository.MoveToDeadLetter(context.Background(), task, "reason"))
+	}
+
+	deadLetters, err := repository.FindDeadLetters(context.Background(), 2, 1)
+	assert.NoError(t, err)
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, "task-2", deadLetters[0].Task.Id, "dead letters must be sorted")
+	assert.Equal(t, "task-3", deadLetters[1].Task.Id, "dead letters must be sorted")
+
+	assert.NoError(t, repository.Requeue(context.Background(), domain.Task{Id: "task-1", ExecTime: now + 10}, false))
+	memory := repository.(*memoryRepository)
+	assert.Equal(t, now+10, memory.collections[memory.collectionIdByTaskId["task-1"]].execTime)
+	_, err = repository.GetDeadLetter(context.Background(), "task-1")
+	assert.Equal(t, contracts.RepoErrorTaskNotFound, err)
+
+	deleted, err := repository.PurgeDeadLetters(context.Background(), now+1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.Len(t, memory.deadLetters, 0)
+}
+
+func TestMemoryRelease(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix()}
+
+	assert.NoError(t, repository.Create(context.Background(), task, true))
+	assert.NoError(t, repository.Release(context.Background(), []domain.Task{task, {Id: util.NewId()}}))
+
+	memory := repository.(*memoryRepository)
+	assert.Equal(t, "", memory.collections[memory.collectionIdByTaskId[task.Id]].takenByInstance)
+}
+
+func TestMemoryInstances(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+	memory := repository.(*memoryRepository)
+	now := time.Now().Unix()
+
+	otherInstanceId := util.NewId()
+	memory.instances[otherInstanceId] = now
+	memory.instances["dead"] = now - 3600
+
+	assert.NoError(t, repository.Create(context.Background(), domain.Task{Id: "alive", ExecTime: now}, true))
+	assert.NoError(t, repository.Create(context.Background(), domain.Task{Id: "dead", ExecTime: now + 1}, true))
+	memory.collections[memory.collectionIdByTaskId["alive"]].takenByInstance = otherInstanceId
+	memory.collections[memory.collectionIdByTaskId["dead"]].takenByInstance = "dead"
+
+	collections, err := repository.FindBySecToExecTime(context.Background(), time.Second, nil)
+	assert.NoError(t, err)
+	tasks, err := collections.Next(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Task{{Id: "dead", ExecTime: now + 1}}, tasks, "only collections of the dead instance must be taken")
+	_, err = collections.Next(context.Background())
+	assert.Equal(t, contracts.RepoErrorNoCollections, err)
+
+	assert.NoError(t, repository.Heartbeat(context.Background()))
+	assert.NotContains(t, memory.instances, "dead", "the dead instance must be deleted from the registry")
+
+	assert.NoError(t, repository.Unregister(context.Background()))
+	assert.NotContains(t, memory.instances, appInstanceId)
+	assert.Equal(t, "", memory.collections[memory.collectionIdByTaskId["dead"]].takenByInstance)
+}
+
+func TestMemoryRaceCondition(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, &Options{MaxCountTasksInCollection: 10})
+	now := time.Now().Unix()
+
+	workersCount := 10
+	tasksCountPerWorker := 1000
+
+	foundTasks := make(chan domain.Task, workersCount*tasksCountPerWorker)
+	createdAll := make(chan bool)
+	wg := sync.WaitGroup{}
+
+	for worker := 0; worker < workersCount; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < tasksCountPerWorker; i++ {
+				task := domain.Task{Id: util.NewId(), ExecTime: now + int64(i%5)}
+				assert.NoError(t, repository.Create(context.Background(), task, false))
+			}
+		}()
+	}
+
+	findingDone := sync.WaitGroup{}
+	for worker := 0; worker < workersCount; worker++ {
+		findingDone.Add(1)
+		go func() {
+			defer findingDone.Done()
+			for {
+				collections, err := repository.FindBySecToExecTime(context.Background(), 10*time.Second, nil)
+				if err == contracts.RepoErrorNoTasksFound {
+					select {
+					case <-createdAll:
+						return
+					default:
+						continue
+					}
+				}
+				assert.NoError(t, err)
+
+				for {
+					tasks, err := collections.Next(context.Background())
+					if err == contracts.RepoErrorNoCollections {
+						break
+					}
+					assert.NoError(t, err)
+					for _, task := range tasks {
+						foundTasks <- task
+					}
+
+					_, err = repository.Delete(context.Background(), tasks)
+					assert.NoError(t, err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(createdAll)
+	findingDone.Wait()
+	close(foundTasks)
+
+	uniqueTasks := make(map[string]bool)
+	for task := range foundTasks {
+		assert.False(t, uniqueTasks[task.Id], "the task must be found once")
+		uniqueTasks[task.Id] = true
+	}
+	assert.Len(t, uniqueTasks, workersCount*tasksCountPerWorker)
+
+	count, err := repository.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMemoryMilliseconds(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, &Options{Milliseconds: true})
+	secondsRepository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+
+	task := domain.Task{Id: util.NewId()}
+	task.SetExecTimeMs(util.ToMs(time.Now()) + 10)
+	assert.NoError(t, repository.Create(context.Background(), task, false))
+	assert.NoError(t, secondsRepository.Create(context.Background(), task, false))
+
+	assert.Equal(t, []domain.Task{task}, takeTasks(t, repository), "the time in milliseconds must be kept")
+	assert.Equal(t, []domain.Task{{Id: task.Id, ExecTime: (task.ExecTimeMs + 999) / 1000}}, takeTasks(t, secondsRepository),
+		"the time must be rounded up to seconds")
+}
+
+func TestMemoryGetAndList(t *testing.T) {
+	testListing(t, NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil))
+}
+
+func TestMemoryCreateAndDeleteBatch(t *testing.T) {
+	repository := NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, &Options{MaxCountTasksInCollection: 2})
+
+	testBatch(t, repository, func(execTime int64) int {
+		return len(repository.(*memoryRepository).collectionsByExecTime[execTime])
+	})
+}
+
+func TestMemoryReschedule(t *testing.T) {
+	testRescheduling(t, NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil))
+}
+
+func TestMemoryQueues(t *testing.T) {
+	testQueues(t, NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, nil))
+}
+
+func TestMemoryIdempotencyKeys(t *testing.T) {
+	options := &Options{IdempotencyKeyRetention: time.Hour}
+	testIdempotencyKeys(t, NewMemory(appInstanceId, &error_service.ErrorHandlerMock{}, options), options)
+}
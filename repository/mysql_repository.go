@@ -6,13 +6,11 @@ import (
 	"fmt"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/VividCortex/mysqlerr"
 	"github.com/go-sql-driver/mysql"
 	"github.com/imdario/mergo"
-	"github.com/pkg/errors"
 	"github.com/pvelx/triggerhook/contracts"
 	"github.com/pvelx/triggerhook/domain"
 )
@@ -29,6 +27,28 @@ type Options struct {
 		n - delete empty collections every n times
 	*/
 	CleaningFrequency int
+
+	/*
+		Time after the last heartbeat of the instance after which the instance is considered dead.
+		Collections taken by the dead instance are taken by other instances
+	*/
+	InstanceTimeout time.Duration
+
+	/*
+		Stores the times of execution in milliseconds. Otherwise they are stored in seconds and the tasks
+		are executed with the precision of seconds. The mode must not be changed while there are tasks in the database
+	*/
+	Milliseconds bool
+
+	/*
+		Range of the times of execution of the tasks in one collection in the mode of milliseconds
+	*/
+	CollectionWidth time.Duration
+
+	/*
+		Time after the deleting or the execution of the task during which its idempotency key is reserved
+	*/
+	IdempotencyKeyRetention time.Duration
 }
 
 func New(
@@ -38,6 +58,16 @@ func New(
 	options *Options,
 ) contracts.RepositoryInterface {
 
+	return &sqlRepository{
+		client:        client,
+		appInstanceId: appInstanceId,
+		eh:            eh,
+		options:       mergeDefaultOptions(options),
+		dialect:       mysqlDialect{},
+	}
+}
+
+func mergeDefaultOptions(options *Options) *Options {
 	if options == nil {
 		options = &Options{}
 	}
@@ -45,445 +75,163 @@ func New(
 	if err := mergo.Merge(options, Options{
 		MaxCountTasksInCollection: 1000,
 		CleaningFrequency:         10,
+		InstanceTimeout:           30 * time.Second,
+		CollectionWidth:           time.Second,
+		IdempotencyKeyRetention:   24 * time.Hour,
 	}); err != nil {
 		panic(err)
 	}
 
-	return &mysqlRepository{
-		client,
-		appInstanceId,
-		eh,
-		0,
-		options,
-	}
-}
-
-type mysqlRepository struct {
-	client            *sql.DB
-	appInstanceId     string
-	eh                contracts.EventHandlerInterface
-	cleanRequestCount int32
-	options           *Options
-}
-
-func (r *mysqlRepository) Count() (int, error) {
-	var count int
-	if err := r.client.QueryRow("SELECT count(*) FROM task").Scan(&count); err != nil {
-		r.eh.New(contracts.LevelError, err.Error(), nil)
-
-		return 0, contracts.RepoErrorCountingTasks
-	}
-
-	return count, nil
-}
-
-func (r *mysqlRepository) Create(ctx context.Context, task domain.Task, isTaken bool) error {
-	createTaskQuery := "CALL create_task(?, ?, ?, ?, ?)"
-	args := []interface{}{
-		r.appInstanceId,
-		task.Id,
-		task.ExecTime,
-		isTaken,
-		r.options.MaxCountTasksInCollection,
-	}
-
-	if _, err := r.client.ExecContext(ctx, createTaskQuery, args...); err != nil {
-		errCreating := contracts.RepoErrorCreatingTask
-
-		if err, ok := err.(*mysql.MySQLError); ok {
-			switch {
-			case err.Number == mysqlerr.ER_DUP_ENTRY:
-				errCreating = contracts.RepoErrorTaskExist
-			case err.Number == mysqlerr.ER_LOCK_DEADLOCK:
-				errCreating = contracts.RepoErrorDeadlock
-			}
-		}
-
-		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task": task})
-
-		return errCreating
-	}
-
-	return nil
+	return options
 }
 
-func (r *mysqlRepository) Delete(ctx context.Context, tasks []domain.Task) (int64, error) {
-	if len(tasks) == 0 {
-		return 0, nil
-	}
-
-	var args []interface{}
-	for _, task := range tasks {
-		args = append(args, task.Id)
-	}
+type mysqlDialect struct{}
 
-	deletingTaskQuery := fmt.Sprintf("DELETE FROM task WHERE uuid IN (?%s)",
-		strings.Repeat(",?", len(tasks)-1))
-
-	result, errDeleting := r.client.ExecContext(ctx, deletingTaskQuery, args...)
-	if errDeleting != nil {
-		errorReturn := contracts.RepoErrorDeletingTask
-
-		if err, ok := errDeleting.(*mysql.MySQLError); ok {
-			switch {
-			case err.Number == mysqlerr.ER_LOCK_DEADLOCK:
-				errorReturn = contracts.RepoErrorDeadlock
-			}
-		}
-
-		r.eh.New(contracts.LevelError, errDeleting.Error(), nil)
-
-		return 0, errorReturn
+func (mysqlDialect) schema(options *Options) []string {
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS collection
+		(
+			id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+			exec_time BIGINT NOT NULL,
+			taken_by_instance VARCHAR(36) DEFAULT '' NOT NULL,
+			queue VARCHAR(255) DEFAULT '' NOT NULL,
+			INDEX (exec_time),
+			INDEX collection_taken_by_instance_idx (taken_by_instance, exec_time)
+		)`,
+		`CREATE TABLE IF NOT EXISTS task
+		(
+			uuid VARCHAR (36) NOT NULL PRIMARY KEY,
+			collection_id BIGINT UNSIGNED NOT NULL,
+			exec_time_ms BIGINT NULL,
+			payload MEDIUMBLOB NULL,
+			headers MEDIUMTEXT NULL,
+			recurrence TEXT NULL,
+			attempts INT DEFAULT 0 NOT NULL,
+			priority INT DEFAULT 0 NOT NULL,
+			idempotency_key VARCHAR(255) DEFAULT '' NOT NULL,
+			INDEX task_exec_time_ms_idx (exec_time_ms),
+			CONSTRAINT task_collection_id_fk FOREIGN KEY (collection_id) REFERENCES collection (id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS dead_letter
+		(
+			uuid VARCHAR (36) NOT NULL PRIMARY KEY,
+			exec_time INT NOT NULL,
+			payload MEDIUMBLOB NULL,
+			headers MEDIUMTEXT NULL,
+			recurrence TEXT NULL,
+			attempts INT DEFAULT 0 NOT NULL,
+			priority INT DEFAULT 0 NOT NULL,
+			idempotency_key VARCHAR(255) DEFAULT '' NOT NULL,
+			queue VARCHAR(255) DEFAULT '' NOT NULL,
+			reason TEXT NOT NULL,
+			dead_lettered_at INT NOT NULL,
+			INDEX (dead_lettered_at)
+		)`,
+		`CREATE TABLE IF NOT EXISTS idempotency_key
+		(
+			idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+			uuid VARCHAR(36) NOT NULL,
+			expires_at BIGINT NOT NULL,
+			INDEX idempotency_key_uuid_idx (uuid),
+			INDEX idempotency_key_expires_at_idx (expires_at)
+		)`,
+		`CREATE TABLE IF NOT EXISTS instance
+		(
+			id VARCHAR(36) NOT NULL PRIMARY KEY,
+			heartbeat_at INT NOT NULL
+		)`,
+		"ALTER TABLE task ADD COLUMN payload MEDIUMBLOB NULL",
+		"ALTER TABLE task ADD COLUMN headers MEDIUMTEXT NULL",
+		"ALTER TABLE task ADD COLUMN recurrence TEXT NULL",
+		"ALTER TABLE task ADD COLUMN attempts INT DEFAULT 0 NOT NULL",
+		"ALTER TABLE task ADD COLUMN exec_time_ms BIGINT NULL",
+		"ALTER TABLE task ADD INDEX task_exec_time_ms_idx (exec_time_ms)",
+		"ALTER TABLE collection ADD INDEX collection_taken_by_instance_idx (taken_by_instance, exec_time)",
+		"ALTER TABLE collection ADD COLUMN queue VARCHAR(255) DEFAULT '' NOT NULL",
+		"ALTER TABLE dead_letter ADD COLUMN queue VARCHAR(255) DEFAULT '' NOT NULL",
+		"ALTER TABLE task ADD COLUMN priority INT DEFAULT 0 NOT NULL",
+		"ALTER TABLE dead_letter ADD COLUMN priority INT DEFAULT 0 NOT NULL",
+		"ALTER TABLE task ADD COLUMN idempotency_key VARCHAR(255) DEFAULT '' NOT NULL",
+		"ALTER TABLE dead_letter ADD COLUMN idempotency_key VARCHAR(255) DEFAULT '' NOT NULL",
+
+		//	The tasks were created by the procedure in previous versions
+		"DROP PROCEDURE IF EXISTS create_task",
 	}
 
-	affected, _ := result.RowsAffected()
-
 	/*
-		Cleaning empty collections of tasks.
-		It is enough do sometimes.
+		The times of execution in milliseconds do not fit INT of the schema created by previous versions
 	*/
-	atomic.AddInt32(&r.cleanRequestCount, 1)
-	if r.options.CleaningFrequency > 0 &&
-		atomic.LoadInt32(&r.cleanRequestCount)%int32(r.options.CleaningFrequency) == 0 {
-
-		if err := r.deleteEmptyCollections(ctx); err != nil {
-			r.eh.New(contracts.LevelError, err.Error(), nil)
-		}
-		atomic.StoreInt32(&r.cleanRequestCount, 0)
+	if options.Milliseconds {
+		schema = append(schema, "ALTER TABLE collection MODIFY exec_time BIGINT NOT NULL")
 	}
 
-	return affected, nil
+	return schema
 }
 
-func (r *mysqlRepository) deleteEmptyCollections(ctx context.Context) error {
-	findCollectionsQuery := `SELECT c.id
-		FROM collection c WHERE c.exec_time < unix_timestamp()-5
-		AND NOT EXISTS(
-			SELECT t.uuid FROM task t WHERE t.collection_id = c.id
-		)`
-
-	rows, errFinding := r.client.QueryContext(ctx, findCollectionsQuery)
-	if errFinding != nil {
-		return errors.Wrap(errFinding, "deleting empty collections is fail")
-	}
-
-	defer func() {
-		if err := rows.Close(); err != nil {
-			r.eh.New(contracts.LevelError, err.Error(), nil)
-		}
-	}()
-
-	var ids []interface{}
-	for rows.Next() {
-		var id int64
-		if err := rows.Scan(&id); err != nil {
-			return errors.Wrap(err, "scan collection error")
-		}
-		ids = append(ids, id)
-	}
-	if err := rows.Err(); err != nil {
-		return errors.Wrap(err, "scan collection error")
-	}
-
-	if len(ids) > 0 {
-		deleteCollectionsQuery := fmt.Sprintf("DELETE FROM collection WHERE id IN(?%s)",
-			strings.Repeat(",?", len(ids)-1))
-
-		if _, err := r.client.ExecContext(ctx, deleteCollectionsQuery, ids...); err != nil {
-			return errors.Wrap(err, "clearing collections was fail")
-		}
-	}
-
-	return nil
+/*
+	MySQL uses the placeholders "?", so the query is not changed
+*/
+func (mysqlDialect) rebind(query string) string {
+	return query
 }
 
-func (r *mysqlRepository) getTasksByCollection(ctx context.Context, collectionId int64) (tasks []domain.Task, error error) {
-	queryFindBySecToExecTime := `SELECT t.uuid, c.exec_time
-		FROM task t
-		INNER JOIN collection c on t.collection_id = c.id
-		WHERE t.collection_id = ?`
-
-	rows, errFinding := r.client.QueryContext(ctx, queryFindBySecToExecTime, collectionId)
-	if errFinding != nil {
-		error = contracts.RepoErrorGettingTasks
-		r.eh.New(contracts.LevelError, errFinding.Error(), map[string]interface{}{"collection id": collectionId})
-
-		return
-	}
-
-	defer func() {
-		if err := rows.Close(); err != nil {
-			r.eh.New(contracts.LevelError, err.Error(), nil)
-		}
-	}()
-
-	for rows.Next() {
-		var task domain.Task
-		if err := rows.Scan(&task.Id, &task.ExecTime); err != nil {
-			error = contracts.RepoErrorGettingTasks
-			r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"collection id": collectionId})
-
-			return
-		}
-		tasks = append(tasks, task)
-	}
-	if err := rows.Err(); err != nil {
-		error = contracts.RepoErrorGettingTasks
-		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"collection id": collectionId})
-
-		return
-	}
-
-	return
+/*
+	SKIP LOCKED is not supported by MySQL 5.7, so the locked collections are waited for
+*/
+func (mysqlDialect) lockClause(skipLocked bool) string {
+	return " FOR UPDATE"
 }
 
-func (r *mysqlRepository) FindBySecToExecTime(ctx context.Context, preloadingTimeRange time.Duration) (
-	collection contracts.CollectionsInterface, error error) {
-
-	toNextExecTime := time.Now().Add(preloadingTimeRange).Unix()
-
-	tx, errTx := r.client.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelRepeatableRead,
-	})
-	if errTx != nil {
-		error = contracts.RepoErrorFindingTasks
-		r.eh.New(contracts.LevelError, errTx.Error(), nil)
-
-		return
-	}
-
-	queryFindBySecToExecTime := `SELECT id
-		FROM collection
-		WHERE exec_time <= ? AND taken_by_instance != ?
-		ORDER BY exec_time
-		FOR UPDATE`
-
-	rows, errFinding := tx.QueryContext(ctx, queryFindBySecToExecTime, toNextExecTime, r.appInstanceId)
-	if errFinding != nil {
-		error = contracts.RepoErrorFindingTasks
-
-		if err, ok := errFinding.(*mysql.MySQLError); ok {
-			switch {
-			case err.Number == mysqlerr.ER_LOCK_DEADLOCK:
-				error = contracts.RepoErrorDeadlock
-			}
-		}
-
-		childError := errFinding
-		if err := tx.Rollback(); err != nil {
-			childError = errors.Wrap(childError, err.Error())
-		}
-		r.eh.New(contracts.LevelError, childError.Error(), nil)
-
-		return
-	}
-
-	defer func() {
-		if err := rows.Close(); err != nil {
-			r.eh.New(contracts.LevelError, err.Error(), nil)
-		}
-	}()
-
-	var collectionIds []int64
-	var args []interface{}
-	args = append(args, r.appInstanceId)
-	for rows.Next() {
-		var collectionId int64
-		if err := rows.Scan(&collectionId); err != nil {
-			error = contracts.RepoErrorFindingTasks
-			r.eh.New(contracts.LevelError, err.Error(), nil)
-
-			return
-		}
-
-		args = append(args, collectionId)
-		collectionIds = append(collectionIds, collectionId)
-	}
-	if err := rows.Err(); err != nil {
-		childError := err
-		if err := tx.Rollback(); err != nil {
-			childError = errors.Wrap(childError, err.Error())
-		}
-
-		error = contracts.RepoErrorFindingTasks
-
-		if err, ok := err.(*mysql.MySQLError); ok {
-			switch {
-			case err.Number == mysqlerr.ER_LOCK_WAIT_TIMEOUT:
-				error = contracts.RepoErrorLockWaitTimeout
-			}
-		}
-
-		r.eh.New(contracts.LevelError, childError.Error(), nil)
-
-		return
-	}
-
-	if len(collectionIds) == 0 {
-		if err := tx.Commit(); err != nil {
-			error = contracts.RepoErrorFindingTasks
-			r.eh.New(contracts.LevelError, err.Error(), nil)
-			return
-		}
-		return nil, contracts.RepoErrorNoTasksFound
-	}
-
-	newQueryLockTasks := fmt.Sprintf("UPDATE collection SET taken_by_instance = ? WHERE id IN(?%s)",
-		strings.Repeat(",?", len(collectionIds)-1))
-
-	if _, err := tx.ExecContext(ctx, newQueryLockTasks, args...); err != nil {
-		error = contracts.RepoErrorFindingTasks
-		childError := err
-
-		if err, ok := err.(*mysql.MySQLError); ok {
-			switch {
-			case err.Number == mysqlerr.ER_LOCK_DEADLOCK:
-				error = contracts.RepoErrorDeadlock
-			}
-		}
-
-		if err := tx.Rollback(); err != nil {
-			childError = errors.Wrap(childError, err.Error())
-		}
-
-		r.eh.New(contracts.LevelError, childError.Error(), nil)
-
-		return
-	}
-
-	if err := tx.Commit(); err != nil {
-		error = contracts.RepoErrorFindingTasks
-		r.eh.New(contracts.LevelError, err.Error(), nil)
-
-		return
-	}
-
-	collection = &Collections{
-		collections: collectionIds,
-		r:           r,
+func (mysqlDialect) insertId(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
 	}
 
-	return
+	return result.LastInsertId()
 }
 
-func (r *mysqlRepository) Up() (error error) {
-	ctx := context.Background()
-	tx, errorTx := r.client.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelRepeatableRead,
-	})
-	if errorTx != nil {
-		error = contracts.RepoErrorSchemaSetup
-		r.eh.New(contracts.LevelError, errorTx.Error(), nil)
-
-		return
+func (mysqlDialect) upsertClause(key string, columns ...string) string {
+	var updates []string
+	for _, column := range columns {
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
 	}
 
-	createCollectionTableQuery := `CREATE TABLE IF NOT EXISTS collection
-		(
-			id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-			exec_time INT NOT NULL,
-			taken_by_instance VARCHAR(36) DEFAULT '' NOT NULL,
-			INDEX (exec_time)
-		)`
-
-	if _, err := tx.ExecContext(ctx, createCollectionTableQuery); err != nil {
-		childError := err
-		if err := tx.Rollback(); err != nil {
-			childError = errors.Wrap(childError, err.Error())
-		}
-
-		error = contracts.RepoErrorSchemaSetup
-		r.eh.New(contracts.LevelError, childError.Error(), nil)
-
-		return
-	}
-
-	createTaskTableQuery := `CREATE TABLE IF NOT EXISTS task
-		(
-			uuid VARCHAR (36) NOT NULL PRIMARY KEY,
-			collection_id BIGINT UNSIGNED NOT NULL ,
-			CONSTRAINT task_collection_id_fk FOREIGN KEY (collection_id) REFERENCES collection (id)
-		)`
-
-	if _, err := tx.ExecContext(ctx, createTaskTableQuery); err != nil {
-		childError := err
-		if err := tx.Rollback(); err != nil {
-			childError = errors.Wrap(childError, err.Error())
-		}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
 
-		error = contracts.RepoErrorSchemaSetup
-		r.eh.New(contracts.LevelError, childError.Error(), nil)
+/*
+	The existing indexes are added by the same statements as the columns
+*/
+func (mysqlDialect) isDuplicateColumn(err error) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
 
-		return
-	}
+	return ok && (mysqlErr.Number == mysqlerr.ER_DUP_FIELDNAME || mysqlErr.Number == mysqlerr.ER_DUP_KEYNAME)
+}
 
-	createCreateTaskProcedure := `CREATE PROCEDURE create_task(
-			param_app_instance VARCHAR(36),
-			param_uuid VARCHAR(36),
-			param_exec_time INT,
-            is_taken BOOL,
-            count_task_in_collection INT
-        )
-		BEGIN
-			SET @var_collection_id = 0;
-			SET @var_exec_time = param_exec_time;
-			SET @var_app_instance = param_app_instance;
-			SET @var_count_task_in_collection = count_task_in_collection;
-
-			IF is_taken THEN
-				SET @app_instance = param_app_instance;
-				SET @compare_operator = '=';
-			else
-				SET @app_instance = '';
-				SET @compare_operator = '!=';
-			end if;
-
-			SET @find_collection_query = CONCAT('SELECT c.id INTO  @var_collection_id
-				FROM collection c LEFT JOIN task t on c.id = t.collection_id
-				WHERE c.exec_time = ? AND c.taken_by_instance ', @compare_operator, ' ?
-				GROUP BY c.id HAVING count(t.uuid) < ? LIMIT 1');
-
-			PREPARE stmt FROM @find_collection_query;
-			EXECUTE stmt USING @var_exec_time, @var_app_instance, @var_count_task_in_collection;
-			DEALLOCATE PREPARE stmt;
-
-			IF (@var_collection_id = 0) THEN
-				INSERT INTO collection (exec_time, taken_by_instance) VALUE (param_exec_time, @app_instance);
-				SET @var_collection_id = LAST_INSERT_ID();
-			END IF;
-
-			INSERT INTO task (uuid, collection_id) VALUE (param_uuid, @var_collection_id);
-		END;`
-
-	if _, errorQuery := tx.ExecContext(ctx, createCreateTaskProcedure); errorQuery != nil {
-		mysqlErr, ok := errorQuery.(*mysql.MySQLError)
-		if !ok || mysqlErr.Number != mysqlerr.ER_SP_ALREADY_EXISTS {
-			error = contracts.RepoErrorSchemaSetup
-			childError := errorQuery
-			if err := tx.Rollback(); err != nil {
-				childError = errors.Wrap(childError, err.Error())
-			}
-
-			r.eh.New(contracts.LevelError, childError.Error(), nil)
-
-			return
+func (mysqlDialect) convertError(err error, defaultError error) error {
+	if err, ok := err.(*mysql.MySQLError); ok {
+		switch err.Number {
+		case mysqlerr.ER_DUP_ENTRY:
+			return contracts.RepoErrorTaskExist
+		case mysqlerr.ER_LOCK_DEADLOCK:
+			return contracts.RepoErrorDeadlock
+		case mysqlerr.ER_LOCK_WAIT_TIMEOUT:
+			return contracts.RepoErrorLockWaitTimeout
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		error = contracts.RepoErrorFindingTasks
-		r.eh.New(contracts.LevelError, err.Error(), nil)
-
-		return
-	}
+	return defaultError
+}
 
-	return
+type tasksByCollectionGetter interface {
+	getTasksByCollection(ctx context.Context, collectionId int64) ([]domain.Task, error)
 }
 
 type Collections struct {
 	sync.Mutex
 	collections []int64
-	r           *mysqlRepository
+	r           tasksByCollectionGetter
 }
 
 func (c *Collections) takeCollectionId() (id int64, isEnd bool) {
@@ -14,20 +14,35 @@ import (
 )
 
 func BenchmarkDelete1000(b *testing.B) {
-	benchmarkDelete(1000, b)
+	forEachBackendBenchmark(b, func(b *testing.B, backend *backend) {
+		benchmarkDelete(backend, 1000, b)
+	})
 }
 
 func BenchmarkDelete500(b *testing.B) {
-	benchmarkDelete(500, b)
+	forEachBackendBenchmark(b, func(b *testing.B, backend *backend) {
+		benchmarkDelete(backend, 500, b)
+	})
 }
 
 func BenchmarkDelete100(b *testing.B) {
-	benchmarkDelete(100, b)
+	forEachBackendBenchmark(b, func(b *testing.B, backend *backend) {
+		benchmarkDelete(backend, 100, b)
+	})
+}
+
+func forEachBackendBenchmark(b *testing.B, benchmark func(b *testing.B, backend *backend)) {
+	for _, backend := range backends {
+		backend := backend
+		b.Run(backend.name, func(b *testing.B) {
+			benchmark(b, backend)
+		})
+	}
 }
 
-func benchmarkDelete(countTaskToDeleteAtOnce int, b *testing.B) {
+func benchmarkDelete(backend *backend, countTaskToDeleteAtOnce int, b *testing.B) {
 	b.ResetTimer()
-	clear()
+	clear(backend)
 	countTaskInCollection := 100
 	countCollections := 2000
 	mu := sync.Mutex{}
@@ -58,10 +73,10 @@ func benchmarkDelete(countTaskToDeleteAtOnce int, b *testing.B) {
 		}
 	}
 
-	upFixtures(collections, tasks)
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{
-		1000,
-		10})
+	upFixtures(backend, collections, tasks)
+	repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{
+		MaxCountTasksInCollection: 1000,
+		CleaningFrequency:         10})
 
 	b.SetParallelism(4)
 	b.ReportAllocs()
@@ -85,26 +100,28 @@ func benchmarkDelete(countTaskToDeleteAtOnce int, b *testing.B) {
 }
 
 func BenchmarkCreate(b *testing.B) {
-	clear()
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{MaxCountTasksInCollection: 1000})
+	forEachBackendBenchmark(b, func(b *testing.B, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{MaxCountTasksInCollection: 1000})
 
-	b.ReportAllocs()
-	b.ResetTimer()
-	b.StartTimer()
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.StartTimer()
 
-	b.RunParallel(func(pb *testing.PB) {
-		for pb.Next() {
-			err := repository.Create(
-				context.Background(),
-				domain.Task{
-					Id:       util.NewId(),
-					ExecTime: time.Now().Unix(),
-				},
-				false,
-			)
-			if err != nil {
-				fmt.Println(err)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				err := repository.Create(
+					context.Background(),
+					domain.Task{
+						Id:       util.NewId(),
+						ExecTime: time.Now().Unix(),
+					},
+					false,
+				)
+				if err != nil {
+					fmt.Println(err)
+				}
 			}
-		}
+		})
 	})
 }
@@ -14,11 +14,27 @@ type RepositoryMock struct {
 	/*
 		You need to substitute *Mock methods to do substitute original functions
 	*/
-	CreateMock              func(ctx context.Context, task domain.Task, isTaken bool) error
-	DeleteMock              func(ctx context.Context, tasks []domain.Task) (int64, error)
-	FindBySecToExecTimeMock func(ctx context.Context, preloadingTimeRange time.Duration) (contracts.CollectionsInterface, error)
-	UpMock                  func() error
-	CountMock               func() (int, error)
+	CreateMock                func(ctx context.Context, task domain.Task, isTaken bool) error
+	DeleteMock                func(ctx context.Context, tasks []domain.Task) (int64, error)
+	GetMock                   func(ctx context.Context, taskId string) (domain.Task, error)
+	GetIdByIdempotencyKeyMock func(ctx context.Context, idempotencyKey string) (string, error)
+	ListMock                  func(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error)
+	CreateBatchMock           func(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error)
+	DeleteBatchMock           func(ctx context.Context, taskIds []string) ([]error, error)
+	RescheduleMock            func(ctx context.Context, task domain.Task, isTaken bool) (domain.Task, error)
+	DeleteAndCreateMock       func(ctx context.Context, tasks []domain.Task, nextTasks []domain.Task) (int64, int64, error)
+	FindBySecToExecTimeMock   func(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (contracts.CollectionsInterface, error)
+	UpdateAttemptsMock        func(ctx context.Context, task domain.Task) error
+	MoveToDeadLetterMock      func(ctx context.Context, task domain.Task, reason string) error
+	FindDeadLettersMock       func(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error)
+	GetDeadLetterMock         func(ctx context.Context, taskId string) (domain.DeadLetter, error)
+	RequeueMock               func(ctx context.Context, task domain.Task, isTaken bool) error
+	PurgeDeadLettersMock      func(ctx context.Context, deadLetteredBefore int64) (int64, error)
+	ReleaseMock               func(ctx context.Context, tasks []domain.Task) error
+	HeartbeatMock             func(ctx context.Context) error
+	UnregisterMock            func(ctx context.Context) error
+	UpMock                    func() error
+	CountMock                 func() (int, error)
 }
 
 func (r *RepositoryMock) Create(ctx context.Context, task domain.Task, isTaken bool) error {
@@ -29,6 +45,70 @@ func (r *RepositoryMock) Delete(ctx context.Context, tasks []domain.Task) (int64
 	return r.DeleteMock(ctx, tasks)
 }
 
+func (r *RepositoryMock) DeleteAndCreate(ctx context.Context, tasks []domain.Task, nextTasks []domain.Task) (int64, int64, error) {
+	return r.DeleteAndCreateMock(ctx, tasks, nextTasks)
+}
+
+func (r *RepositoryMock) UpdateAttempts(ctx context.Context, task domain.Task) error {
+	return r.UpdateAttemptsMock(ctx, task)
+}
+
+func (r *RepositoryMock) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	return r.GetMock(ctx, taskId)
+}
+
+func (r *RepositoryMock) GetIdByIdempotencyKey(ctx context.Context, idempotencyKey string) (string, error) {
+	return r.GetIdByIdempotencyKeyMock(ctx, idempotencyKey)
+}
+
+func (r *RepositoryMock) List(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+	return r.ListMock(ctx, filter)
+}
+
+func (r *RepositoryMock) CreateBatch(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+	return r.CreateBatchMock(ctx, tasks, isTaken)
+}
+
+func (r *RepositoryMock) DeleteBatch(ctx context.Context, taskIds []string) ([]error, error) {
+	return r.DeleteBatchMock(ctx, taskIds)
+}
+
+func (r *RepositoryMock) Reschedule(ctx context.Context, task domain.Task, isTaken bool) (domain.Task, error) {
+	return r.RescheduleMock(ctx, task, isTaken)
+}
+
+func (r *RepositoryMock) MoveToDeadLetter(ctx context.Context, task domain.Task, reason string) error {
+	return r.MoveToDeadLetterMock(ctx, task, reason)
+}
+
+func (r *RepositoryMock) FindDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error) {
+	return r.FindDeadLettersMock(ctx, limit, offset)
+}
+
+func (r *RepositoryMock) GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error) {
+	return r.GetDeadLetterMock(ctx, taskId)
+}
+
+func (r *RepositoryMock) Requeue(ctx context.Context, task domain.Task, isTaken bool) error {
+	return r.RequeueMock(ctx, task, isTaken)
+}
+
+func (r *RepositoryMock) PurgeDeadLetters(ctx context.Context, deadLetteredBefore int64) (int64, error) {
+	return r.PurgeDeadLettersMock(ctx, deadLetteredBefore)
+}
+
+func (r *RepositoryMock) Release(ctx context.Context, tasks []domain.Task) error {
+	return r.ReleaseMock(ctx, tasks)
+}
+
+func (r *RepositoryMock) Heartbeat(ctx context.Context) error {
+	return r.HeartbeatMock(ctx)
+}
+
+func (r *RepositoryMock) Unregister(ctx context.Context) error {
+	return r.UnregisterMock(ctx)
+}
+
 func (r *RepositoryMock) Up() (error error) {
 	if r.UpMock == nil {
 		return nil
@@ -43,11 +123,11 @@ func (r *RepositoryMock) Count() (int, error) {
 	return r.CountMock()
 }
 
-func (r *RepositoryMock) FindBySecToExecTime(ctx context.Context, preloadingTimeRange time.Duration) (
+func (r *RepositoryMock) FindBySecToExecTime(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (
 	collection contracts.CollectionsInterface,
 	error error,
 ) {
-	return r.FindBySecToExecTimeMock(ctx, preloadingTimeRange)
+	return r.FindBySecToExecTimeMock(ctx, preloadingTimeRange, queues)
 }
 
 type CollectionsMock struct {
@@ -22,384 +22,1136 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+/*
+	The same test scenarios are run against every database.
+	SQLite is tested unless it is excluded by the build tag nosqlite. MySQL is tested unless MYSQL_DSN is specified empty.
+	PostgreSQL is tested if POSTGRES_DSN is specified, for example:
+	POSTGRES_DSN="postgres://postgres:@127.0.0.1:5432/task?sslmode=disable"
+*/
+type backend struct {
+	name          string
+	db            *sql.DB
+	new           func(client *sql.DB, appInstanceId string, eh contracts.EventHandlerInterface, options *Options) contracts.RepositoryInterface
+	rebind        func(query string) string
+	resetSequence string
+
+	/*
+		Removes the files of the database after the tests if it is specified
+	*/
+	remove func()
+}
+
 var (
-	db       *sql.DB
-	dialect  = "mysql"
-	user     = "root"
-	password = ""
-	dbName   = "task"
-	port     = "3306"
-	dsn      = "%s:%s@tcp(127.0.0.1:%s)/%s?charset=utf8"
+	mysqlDsn = getEnv("MYSQL_DSN", "root:@tcp(127.0.0.1:3306)/task?charset=utf8")
 	maxConn  = 25
 	idleConn = 25
 
+	backends []*backend
+
+	/*
+		The backends of PostgreSQL and SQLite are added by their files, so they are excluded
+		with the drivers by the build tags
+	*/
+	newBackends []func() *backend
+
 	appInstanceId = util.NewId()
 )
 
 func TestMain(m *testing.M) {
 
-	dsn = fmt.Sprintf(dsn, user, password, port, dbName)
+	for _, newBackend := range newBackends {
+		if backend := newBackend(); backend != nil {
+			backends = append(backends, backend)
+		}
+	}
+
+	if mysqlDsn != "" {
+		backends = append(backends, &backend{
+			name:   "mysql",
+			db:     open("mysql", mysqlDsn),
+			new:    New,
+			rebind: mysqlDialect{}.rebind,
+		})
+	}
 
-	var err error
-	db, err = sql.Open(dialect, dsn)
+	for _, backend := range backends {
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+
+		if err := repository.Up(); err != nil {
+			log.Fatalf("Up schema of %s is fail: %s", backend.name, err)
+		}
+	}
+
+	code := m.Run()
+
+	for _, backend := range backends {
+		if backend.remove != nil {
+			backend.remove()
+		}
+	}
+	os.Exit(code)
+}
+
+func open(driverName, dsn string) *sql.DB {
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		panic(err)
 	}
 	db.SetMaxIdleConns(idleConn)
 	db.SetMaxOpenConns(maxConn)
 
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+	return db
+}
 
-	if err := repository.Up(); err != nil {
-		log.Fatalf("Up schema is fail: %s", err)
+func getEnv(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
 	}
 
-	code := m.Run()
+	return defaultValue
+}
 
-	os.Exit(code)
+func forEachBackend(t *testing.T, test func(t *testing.T, backend *backend)) {
+	for _, backend := range backends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend)
+		})
+	}
 }
 
 // Testing race condition in case parallel access to tasks
 func Test_FindBySecToExecTimeRaceCondition(t *testing.T) {
-	clear()
-	loadFixtures("data_1")
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		loadFixtures(backend, "data_1")
 
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, nil)
 
-	expectedTaskCount := 35060
-	workersCount := 10
+		expectedTaskCount := 35060
+		workersCount := 10
 
-	workersDone := sync.WaitGroup{}
-	workersDone.Add(workersCount)
+		workersDone := sync.WaitGroup{}
+		workersDone.Add(workersCount)
 
-	startWorkers := make(chan bool)
-	foundTasks := make(chan domain.Task, expectedTaskCount*2)
+		startWorkers := make(chan bool)
+		foundTasks := make(chan domain.Task, expectedTaskCount*2)
 
-	result, err := repository.FindBySecToExecTime(context.Background(), 5*time.Second)
-	if err != nil {
-		log.Fatal(err, "Error while get tasks")
-	}
-	allTasks := make(map[string]domain.Task)
-
-	foundedCountOfTasks := 0
-	for worker := 0; worker < workersCount; worker++ {
-		go func() {
-			defer workersDone.Done()
-			<-startWorkers
-
-			for {
-				tasks, err := result.Next(context.Background())
-				if err != nil {
-					if err == contracts.RepoErrorNoCollections {
-						break
-					} else {
-						log.Fatal(err, "Getting next part is fail")
+		result, err := repository.FindBySecToExecTime(context.Background(), 5*time.Second, nil)
+		if err != nil {
+			log.Fatal(err, "Error while get tasks")
+		}
+		allTasks := make(map[string]domain.Task)
+
+		foundedCountOfTasks := 0
+		for worker := 0; worker < workersCount; worker++ {
+			go func() {
+				defer workersDone.Done()
+				<-startWorkers
+
+				for {
+					tasks, err := result.Next(context.Background())
+					if err != nil {
+						if err == contracts.RepoErrorNoCollections {
+							break
+						} else {
+							log.Fatal(err, "Getting next part is fail")
+						}
+					}
+
+					for _, task := range tasks {
+						foundTasks <- task
 					}
 				}
+			}()
+		}
+
+		close(startWorkers)
+
+		workersDone.Wait()
+		close(foundTasks)
+
+		for task := range foundTasks {
+			if _, exist := allTasks[task.Id]; exist {
+				assert.Fail(t, "The task already was founded in previous time")
+			}
+			allTasks[task.Id] = task
+			foundedCountOfTasks++
+		}
+
+		assert.Equal(t, expectedTaskCount, foundedCountOfTasks, "Count of tasks is not equal")
+	})
+}
+
+func TestFindBySecToExecTime(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		loadFixtures(backend, "data_2")
+
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+
+		expectedCountTaskOnIteration := []int{33, 981, 894, 128, 212, 174, 90, 148, 167, 108, 26, 966, 967, 0, 835, 140,
+			538, 127, 209, 356, 605, 354, 591, 0, 0, 0, 0, 0}
+
+		expectedCollectionCount := len(expectedCountTaskOnIteration)
+
+		var countAllTask int
+		for _, count := range expectedCountTaskOnIteration {
+			countAllTask = countAllTask + count
+		}
+
+		collections, err := repository.FindBySecToExecTime(context.Background(), 5*time.Second, nil)
+		if err != nil {
+			log.Fatal(err, "Error while get tasks")
+		}
 
-				for _, task := range tasks {
-					foundTasks <- task
+		allTasks := make(map[string]domain.Task)
+		actualCollectionCount := 0
+
+		for {
+			tasks, errNext := collections.Next(context.Background())
+			if errNext != nil {
+				if errNext == contracts.RepoErrorNoCollections {
+					break
+				} else {
+					log.Fatal(errNext, "Getting next part is fail")
 				}
 			}
-		}()
-	}
 
-	close(startWorkers)
+			for _, task := range tasks {
+				if _, exist := allTasks[task.Id]; exist {
+					assert.Fail(t, "Task was founded in previous time")
+				}
+				allTasks[task.Id] = task
+			}
+			actualCollectionCount++
 
-	workersDone.Wait()
-	close(foundTasks)
+			var isFound bool
 
-	for task := range foundTasks {
-		if _, exist := allTasks[task.Id]; exist {
-			assert.Fail(t, "The task already was founded in previous time")
+			expectedCountTaskOnIteration, isFound = find(expectedCountTaskOnIteration, len(tasks))
+			if !isFound {
+				assert.Fail(t, "Founded count of task in for collection is not correct")
+			}
 		}
-		allTasks[task.Id] = task
-		foundedCountOfTasks++
-	}
 
-	assert.Equal(t, expectedTaskCount, foundedCountOfTasks, "Count of tasks is not equal")
+		assert.Equal(
+			t,
+			expectedCollectionCount,
+			actualCollectionCount,
+			"Founded count of collection is not correct",
+		)
+
+		assert.Equal(
+			t,
+			countAllTask,
+			len(allTasks),
+			"Founded count of all task is not correct",
+		)
+	})
 }
 
-func TestFindBySecToExecTime(t *testing.T) {
-	clear()
-	loadFixtures("data_2")
+func TestCreateRaceCondition(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+
+		maxCountTasksInCollection := 100
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{
+			MaxCountTasksInCollection: maxCountTasksInCollection,
+			CleaningFrequency:         10,
+		})
+
+		now := time.Now().Unix()
+		input := []struct {
+			tasksCount       int
+			isTaken          bool
+			relativeExecTime int64
+		}{
+			{180, false, -5},
+			{150, false, 0},
+			{150, false, 1},
+			{220, false, 2},
+			{140, true, -5},
+			{250, true, 0},
+			{120, true, 1},
+			{80, true, 2},
+		}
 
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+		workersCount := 5
+		workersDone := sync.WaitGroup{}
+		workersDone.Add(workersCount)
+		startWorkers := make(chan bool)
+		for worker := 0; worker < workersCount; worker++ {
+			go func() {
+				defer workersDone.Done()
+				<-startWorkers
+				for _, item := range input {
+					for i := 0; i < item.tasksCount; i++ {
+						errCreate := repository.Create(context.Background(), getTaskInstance(now+item.relativeExecTime), item.isTaken)
+						if errCreate != nil {
+							log.Fatal(errCreate, "Error while create")
+						}
+					}
+				}
+			}()
+		}
 
-	expectedCountTaskOnIteration := []int{33, 981, 894, 128, 212, 174, 90, 148, 167, 108, 26, 966, 967, 0, 835, 140,
-		538, 127, 209, 356, 605, 354, 591, 0, 0, 0, 0, 0}
+		close(startWorkers)
+		workersDone.Wait()
+
+		for i, item := range input {
+			assert.Equal(t,
+				item.tasksCount*workersCount,
+				getCountTasksByParamsInDb(backend, item.isTaken, now+item.relativeExecTime),
+				fmt.Sprintf("Count of tasks is not correct (isTaken:%t, execTime:%d, input item: %d)",
+					item.isTaken, now+item.relativeExecTime, i),
+			)
+
+			/*
+				Due to concurrent access to DB may be created extra collection. It is not big problem.
+				It is assumed that the number of extra collections should not exceed twice the norm.
+			*/
+			maxApproximatelyCountOfCollections :=
+				int(float64(item.tasksCount*workersCount) / float64(maxCountTasksInCollection) * 2)
+
+			assert.LessOrEqual(t,
+				getCountCollectionsByParamsInDb(backend, item.isTaken, now+item.relativeExecTime),
+				maxApproximatelyCountOfCollections,
+				fmt.Sprintf("Count of collections is not correct (isTaken:%t, execTime:%d, input item: %d)",
+					item.isTaken, now+item.relativeExecTime, i),
+			)
+		}
+	})
+}
 
-	expectedCollectionCount := len(expectedCountTaskOnIteration)
+func TestDeleteBunch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		loadFixtures(backend, "data_3")
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, &Options{
+			MaxCountTasksInCollection: 1000,
+			CleaningFrequency:         1,
+		})
+
+		tasksMustNotBeDeleted := []domain.Task{
+			{Id: "1657bd33-83d0-4a02-ab23-288a8ea33452"},
+			{Id: "19dd8fce-b3f6-4347-b6fa-d9d075a22c71"},
+			{Id: "1b959ecb-6b18-48aa-8804-cc604201675e"},
+			{Id: "05721684-22ec-499b-9267-6498e57d5755"},
+			{Id: "087c988b-1b68-46d8-8a45-efdf916a84b1"},
+			{Id: "01c796df-7e2d-4981-86b0-67eb1a7fc45b"},
+			{Id: "02ab39b5-d3ed-4de4-8f1c-bd4894cbee02"},
+			{Id: "a0d96c2e-46f3-11eb-9f26-5ee87590738f"},
+			{Id: "a128b982-46f3-11eb-9f26-5ee87590738f"},
+			{Id: "131290fe-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "13287d06-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "13763ed8-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "aa1de8ae-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "aa29fc84-46f4-11eb-9f26-5ee87590738f"},
+		}
 
-	var countAllTask int
-	for _, count := range expectedCountTaskOnIteration {
-		countAllTask = countAllTask + count
-	}
+		tasksMustBeDeleted := []domain.Task{
+			{Id: "0aa6c808-207d-453f-8d8f-a3775f99f05c"},
+			{Id: "13cbc999-c428-4d4f-bad3-3959e747769c"},
+			{Id: "02b9bc69-4fb7-4519-b51f-8e66dc48a26a"},
+			{Id: "059de99b-30a5-4018-8c79-f44767580cd5"},
+			{Id: "070df841-9a71-4a06-bb55-12cf096acbda"},
+			{Id: "0eaadd31-71e8-4dc2-a687-ed981e1d78ad"},
+			{Id: "0f798362-2e12-48df-b1ea-f78736807005"},
+			{Id: "021cba07-8fa0-4111-bf5c-338b40fd856c"},
+			{Id: "03070b08-df29-4ef7-8226-d628548ff0ed"},
+			{Id: "03e4d83d-9c42-461f-b663-205c26950dce"},
+			{Id: "058b3fd3-2a07-46b0-aa95-099aa2abbc78"},
+			{Id: "05db598c-abbb-4026-b0fe-ee19289c25f2"},
+			{Id: "0a62e9d9-a4b4-4053-8563-b24b8ce4bcaa"},
+			{Id: "0aa88a99-9872-4cf9-aca7-ad23a45e8f60"},
+			{Id: "0ba79a57-b5c7-409c-b15a-bf70222b7d13"},
+			{Id: "0c1acbde-aeec-4cee-866b-bb455b98f5d5"},
+			{Id: "0e0cba0f-493b-4294-a11c-e26e917d0ab7"},
+			{Id: "000c1709-b8a6-4674-9e6b-7415bd9308a3"},
+			{Id: "002e256b-30c6-4562-a2af-5d778a6caabd"},
+			{Id: "003f5516-31e3-4f81-b819-9af1c1cc6a4a"},
+			{Id: "00717d01-7d07-48ba-b007-7979a6b14940"},
+			{Id: "008797b1-340f-4440-80f8-b5e76622501a"},
+			{Id: "005b5228-97e2-450c-adb7-c7f2248cab46"},
+			{Id: "00785c9a-9b5e-4b9d-aad1-055ca1543cce"},
+			{Id: "00a0ace5-45e1-4353-a007-e23fd1d1dbff"},
+			{Id: "00a60038-d738-44c7-bfe8-a6741ab0024b"},
+			{Id: "01a17c46-7536-406c-bda6-3b204ddcdba0"},
+			{Id: "03ffca7b-fb47-4db6-8bd9-8a8f729d8801"},
+			{Id: "043158b7-8a22-4ea1-83b0-46b76a34822f"},
+			{Id: "0432ff60-1fef-42f7-9d43-c116a60bfdf3"},
+			{Id: "0012f748-fb2a-49cd-a455-e73c0212466a"},
+			{Id: "00613adf-8104-47bc-b655-7589bef7bdd6"},
+			{Id: "00c8851e-a675-4ed4-81f1-5cd94c0c5d6a"},
+			{Id: "9ebdfa40-46f3-11eb-9f26-5ee87590738f"},
+			{Id: "a0064d94-46f3-11eb-9f26-5ee87590738f"},
+			{Id: "a0876816-46f3-11eb-9f26-5ee87590738f"},
+			{Id: "12dceaa8-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "12f7adb6-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "85dc6970-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "86283026-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "86347c50-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "8642c4e0-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "86501370-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "a9b74ec8-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "aa03602e-46f4-11eb-9f26-5ee87590738f"},
+			{Id: "aa10b454-46f4-11eb-9f26-5ee87590738f"},
+		}
 
-	collections, err := repository.FindBySecToExecTime(context.Background(), 5*time.Second)
-	if err != nil {
-		log.Fatal(err, "Error while get tasks")
-	}
+		collectionsMustBeDeleted := []int{25, 67, 107, 129}
+		collectionsMustNotBeDeleted := []int{14, 37, 42, 48, 63, 74, 81, 94, 100, 110, 122, 123, 124, 125, 126, 127, 128}
 
-	allTasks := make(map[string]domain.Task)
-	actualCollectionCount := 0
+		_, err := repository.Delete(context.Background(), tasksMustBeDeleted)
+		if err != nil {
+			log.Fatal(err, "Error while delete")
+		}
 
-	for {
-		tasks, errNext := collections.Next(context.Background())
-		if errNext != nil {
+		for _, task := range tasksMustNotBeDeleted {
+			assert.True(t, isTaskExistInDb(backend, task.Id), fmt.Sprintf("the task %s not found", task.Id))
+		}
+
+		for _, id := range collectionsMustNotBeDeleted {
+			assert.True(t, isCollectionExistInDb(backend, id), fmt.Sprintf("the collection %d not found", id))
+		}
+
+		for _, id := range collectionsMustBeDeleted {
+			assert.False(t, isCollectionExistInDb(backend, id), fmt.Sprintf("the collection %d was found", id))
+		}
+	})
+}
+
+func TestCreate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		maxCountTasksInCollection := 100
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{
+			MaxCountTasksInCollection: maxCountTasksInCollection,
+			CleaningFrequency:         10,
+		})
+
+		input := []struct {
+			tasksCount       int
+			isTaken          bool
+			relativeExecTime int64
+		}{
+			{110, false, -5},
+			{150, false, 0},
+			{180, false, 1},
+			{220, false, 2},
+			{140, true, -5},
+			{250, true, 0},
+			{120, true, 1},
+			{30, true, 2},
+		}
+
+		now := time.Now().Unix()
+		for _, item := range input {
+			for i := 0; i < item.tasksCount; i++ {
+				errCreate := repository.Create(context.Background(), getTaskInstance(now+item.relativeExecTime), item.isTaken)
+				if errCreate != nil {
+					log.Fatal(errCreate, "Error while create")
+				}
+			}
+		}
+
+		for _, item := range input {
+			assert.Equal(t,
+				item.tasksCount,
+				getCountTasksByParamsInDb(backend, item.isTaken, now+item.relativeExecTime),
+				fmt.Sprintf("Count of tasks is not correct (isTaken:%t, execTime:%d)",
+					item.isTaken, now+item.relativeExecTime),
+			)
+
+			assert.Equal(t,
+				int(math.Ceil(float64(item.tasksCount)/float64(maxCountTasksInCollection))),
+				getCountCollectionsByParamsInDb(backend, item.isTaken, now+item.relativeExecTime),
+				fmt.Sprintf("Count of collections is not correct (isTaken:%t, execTime:%d)",
+					item.isTaken, now+item.relativeExecTime),
+			)
+		}
+	})
+}
+
+func TestCreateWithPayload(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
+
+		now := time.Now().Unix()
+		expectedTasks := map[string]domain.Task{}
+		for _, task := range []domain.Task{
+			{Id: util.NewId(), ExecTime: now},
+			{Id: util.NewId(), ExecTime: now, Payload: []byte(`{"user_id":1}`)},
+			{Id: util.NewId(), ExecTime: now, Headers: map[string]string{"type": "reminder"}},
+			{
+				Id:       util.NewId(),
+				ExecTime: now,
+				Payload:  []byte{0, 1, 2, 255},
+				Headers:  map[string]string{"type": "push", "content-type": "application/octet-stream"},
+			},
+		} {
+			if err := repository.Create(context.Background(), task, false); err != nil {
+				log.Fatal(err, "Error while create")
+			}
+			expectedTasks[task.Id] = task
+		}
+
+		collections, err := repository.FindBySecToExecTime(context.Background(), 5*time.Second, nil)
+		if err != nil {
+			log.Fatal(err, "Error while get tasks")
+		}
+
+		actualTasks := map[string]domain.Task{}
+		for {
+			tasks, errNext := collections.Next(context.Background())
 			if errNext == contracts.RepoErrorNoCollections {
 				break
-			} else {
+			} else if errNext != nil {
 				log.Fatal(errNext, "Getting next part is fail")
 			}
+
+			for _, task := range tasks {
+				actualTasks[task.Id] = task
+			}
 		}
 
-		for _, task := range tasks {
-			if _, exist := allTasks[task.Id]; exist {
-				assert.Fail(t, "Task was founded in previous time")
+		assert.Equal(t, expectedTasks, actualTasks, "payload or headers of the tasks are not correct")
+	})
+}
+
+func TestMoveToDeadLetter(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
+
+		task := domain.Task{
+			Id:       util.NewId(),
+			ExecTime: time.Now().Unix(),
+			Payload:  []byte("payload"),
+			Headers:  map[string]string{"type": "push"},
+			Priority: 5,
+		}
+		assert.NoError(t, repository.Create(context.Background(), task, false))
+
+		task.Attempts = 3
+		assert.NoError(t, repository.UpdateAttempts(context.Background(), task))
+
+		collections, err := repository.FindBySecToExecTime(context.Background(), 5*time.Second, nil)
+		assert.NoError(t, err)
+		tasks, err := collections.Next(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Task{task}, tasks, "count of attempts is not saved")
+
+		assert.NoError(t, repository.MoveToDeadLetter(context.Background(), task, "max attempts exceeded"))
+		assert.False(t, isTaskExistInDb(backend, task.Id), "the task must be deleted")
+
+		var reason string
+		var attempts int
+		var payload []byte
+		err = backend.db.QueryRow(
+			backend.rebind("SELECT reason, attempts, payload FROM dead_letter WHERE uuid = ?"),
+			task.Id,
+		).Scan(&reason, &attempts, &payload)
+		assert.NoError(t, err, "the task must be saved to the dead letter")
+		assert.Equal(t, "max attempts exceeded", reason)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, task.Payload, payload)
+
+		assert.Equal(t, contracts.RepoErrorTaskNotFound, repository.MoveToDeadLetter(context.Background(), task, "reason"))
+	})
+}
+
+func TestDeadLetters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
+
+		now := time.Now().Unix()
+		var tasks []domain.Task
+		for i := 0; i < 3; i++ {
+			task := domain.Task{
+				Id:         util.NewId(),
+				ExecTime:   now,
+				Headers:    map[string]string{"type": "push"},
+				Recurrence: &domain.Recurrence{Interval: 60},
+				Attempts:   i + 1,
+				Priority:   i - 1,
 			}
-			allTasks[task.Id] = task
+			assert.NoError(t, repository.Create(context.Background(), task, false))
+			assert.NoError(t, repository.MoveToDeadLetter(context.Background(), task, "reason"))
+			tasks = append(tasks, task)
 		}
-		actualCollectionCount++
 
-		var isFound bool
+		deadLetters, err := repository.FindDeadLetters(context.Background(), 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, deadLetters, 3)
+		deadLetters, err = repository.FindDeadLetters(context.Background(), 2, 2)
+		assert.NoError(t, err)
+		assert.Len(t, deadLetters, 1, "limit and offset are not applied")
+
+		deadLetter, err := repository.GetDeadLetter(context.Background(), tasks[1].Id)
+		assert.NoError(t, err)
+		assert.Equal(t, tasks[1], deadLetter.Task)
+		assert.Equal(t, "reason", deadLetter.Reason)
+		assert.LessOrEqual(t, now, deadLetter.DeadLetteredAt)
+
+		_, err = repository.GetDeadLetter(context.Background(), util.NewId())
+		assert.Equal(t, contracts.RepoErrorTaskNotFound, err)
+
+		requeued := tasks[1]
+		requeued.ExecTime = now + 60
+		assert.NoError(t, repository.Requeue(context.Background(), requeued, false))
+		assert.True(t, isTaskExistInDb(backend, requeued.Id), "the task must be created again")
+		_, err = repository.GetDeadLetter(context.Background(), requeued.Id)
+		assert.Equal(t, contracts.RepoErrorTaskNotFound, err, "the task must be deleted from the dead letter")
+		assert.Equal(t, contracts.RepoErrorTaskNotFound, repository.Requeue(context.Background(), requeued, false))
+
+		deleted, err := repository.PurgeDeadLetters(context.Background(), now-1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), deleted)
+		deleted, err = repository.PurgeDeadLetters(context.Background(), time.Now().Unix()+1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		deadLetters, err = repository.FindDeadLetters(context.Background(), 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, deadLetters, 0)
+	})
+}
+
+func TestRelease(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
+
+		now := time.Now().Unix()
+		released := domain.Task{Id: util.NewId(), ExecTime: now}
+		notReleased := domain.Task{Id: util.NewId(), ExecTime: now + 1}
+		assert.NoError(t, repository.Create(context.Background(), released, true))
+		assert.NoError(t, repository.Create(context.Background(), notReleased, true))
+
+		assert.NoError(t, repository.Release(context.Background(), []domain.Task{released}))
+		assert.NoError(t, repository.Release(context.Background(), nil))
+
+		collections, err := repository.FindBySecToExecTime(context.Background(), 5*time.Second, nil)
+		assert.NoError(t, err, "the released task must be taken again")
+		tasks, err := collections.Next(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Task{released}, tasks)
+		_, err = collections.Next(context.Background())
+		assert.Equal(t, contracts.RepoErrorNoCollections, err, "the task which is not released must not be taken")
+	})
+}
 
-		expectedCountTaskOnIteration, isFound = find(expectedCountTaskOnIteration, len(tasks))
-		if !isFound {
-			assert.Fail(t, "Founded count of task in for collection is not correct")
+// Several instances work with one database
+func TestInstances(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		eh := &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
 		}
-	}
+		firstInstanceId := util.NewId()
+		first := backend.new(backend.db, firstInstanceId, eh, nil)
+		second := backend.new(backend.db, util.NewId(), eh, nil)
+		third := backend.new(backend.db, util.NewId(), eh, nil)
+
+		now := time.Now().Unix()
+		taskOfFirst := domain.Task{Id: util.NewId(), ExecTime: now}
+		notTakenTask := domain.Task{Id: util.NewId(), ExecTime: now}
+
+		assert.NoError(t, first.Heartbeat(context.Background()))
+		assert.NoError(t, second.Heartbeat(context.Background()))
+		assert.NoError(t, first.Create(context.Background(), taskOfFirst, true))
+		assert.NoError(t, second.Create(context.Background(), notTakenTask, false))
+
+		assert.Equal(t, []domain.Task{notTakenTask}, takeTasks(t, second),
+			"collections of the alive instance must not be taken")
+		assert.Len(t, takeTasks(t, third), 0, "collections of the alive instances must not be taken")
+
+		//	The first instance crashed and stopped sending the heartbeats
+		_, err := backend.db.Exec(
+			backend.rebind("UPDATE instance SET heartbeat_at = ? WHERE id = ?"),
+			now-3600,
+			firstInstanceId,
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Task{taskOfFirst}, takeTasks(t, third),
+			"collections of the dead instance must be taken")
+
+		//	The second instance is stopped
+		assert.NoError(t, second.Unregister(context.Background()))
+		assert.Equal(t, []domain.Task{notTakenTask}, takeTasks(t, third),
+			"collections of the stopped instance must be taken")
+
+		assert.NoError(t, third.Heartbeat(context.Background()))
+		var count int
+		assert.NoError(t, backend.db.QueryRow("SELECT count(*) FROM instance").Scan(&count))
+		assert.Equal(t, 1, count, "the dead and stopped instances must be deleted from the registry")
+	})
+}
 
-	assert.Equal(
-		t,
-		expectedCollectionCount,
-		actualCollectionCount,
-		"Founded count of collection is not correct",
-	)
+func TestMilliseconds(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, &Options{Milliseconds: true, CollectionWidth: 100 * time.Millisecond})
+		assert.NoError(t, repository.Up())
+
+		now := util.ToMs(time.Now())
+		now -= now % 100
+
+		var tasks []domain.Task
+		for _, execTime := range []int64{now + 10, now + 90, now + 150} {
+			task := domain.Task{Id: util.NewId()}
+			task.SetExecTimeMs(execTime)
+			assert.NoError(t, repository.Create(context.Background(), task, false))
+			tasks = append(tasks, task)
+		}
+
+		assert.Equal(t, 1, getCountCollectionsByParamsInDb(backend, false, now),
+			"the tasks must be grouped by the width of the collection")
+		assert.Equal(t, 1, getCountCollectionsByParamsInDb(backend, false, now+100),
+			"the tasks must be grouped by the width of the collection")
+		assert.ElementsMatch(t, tasks, takeTasks(t, repository), "the times in milliseconds must be restored")
 
-	assert.Equal(
-		t,
-		countAllTask,
-		len(allTasks),
-		"Founded count of all task is not correct",
-	)
+		clear(backend)
+	})
 }
 
-func TestCreateRaceCondition(t *testing.T) {
-	clear()
+func TestGetAndList(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
 
-	maxCountTasksInCollection := 100
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{
-		maxCountTasksInCollection,
-		10,
+		testListing(t, repository)
 	})
+}
 
+/*
+	The same scenario for the database and the memory
+*/
+func testListing(t *testing.T, repository contracts.RepositoryInterface) {
 	now := time.Now().Unix()
-	input := []struct {
-		tasksCount       int
-		isTaken          bool
-		relativeExecTime int64
+	taken := domain.Task{Id: util.NewId(), ExecTime: now + 10, Payload: []byte("payload")}
+	notTaken := domain.Task{Id: util.NewId(), ExecTime: now + 20, Headers: map[string]string{"type": "reminder"}}
+	later := domain.Task{Id: util.NewId(), ExecTime: now + 3600}
+	assert.NoError(t, repository.Create(context.Background(), taken, true))
+	assert.NoError(t, repository.Create(context.Background(), notTaken, false))
+	assert.NoError(t, repository.Create(context.Background(), later, false))
+
+	task, err := repository.Get(context.Background(), taken.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, taken, task)
+
+	_, err = repository.Get(context.Background(), util.NewId())
+	assert.Equal(t, contracts.RepoErrorTaskNotFound, err)
+
+	isTaken, isNotTaken := true, false
+	tests := []struct {
+		name     string
+		filter   contracts.TaskFilter
+		expected []domain.Task
 	}{
-		{180, false, -5},
-		{150, false, 0},
-		{150, false, 1},
-		{220, false, 2},
-		{140, true, -5},
-		{250, true, 0},
-		{120, true, 1},
-		{80, true, 2},
-	}
-
-	workersCount := 5
-	workersDone := sync.WaitGroup{}
-	workersDone.Add(workersCount)
-	startWorkers := make(chan bool)
-	for worker := 0; worker < workersCount; worker++ {
-		go func() {
-			defer workersDone.Done()
-			<-startWorkers
-			for _, item := range input {
-				for i := 0; i < item.tasksCount; i++ {
-					errCreate := repository.Create(context.Background(), getTaskInstance(now+item.relativeExecTime), item.isTaken)
-					if errCreate != nil {
-						log.Fatal(errCreate, "Error while create")
-					}
-				}
-			}
-		}()
+		{"all", contracts.TaskFilter{Limit: 10}, []domain.Task{taken, notTaken, later}},
+		{"page", contracts.TaskFilter{Limit: 1, Offset: 1}, []domain.Task{notTaken}},
+		{"after the end", contracts.TaskFilter{Limit: 10, Offset: 3}, []domain.Task{}},
+		{"due", contracts.TaskFilter{
+			ExecTimeFrom: time.Unix(now+20, 0),
+			ExecTimeTo:   time.Unix(now+3600, 0),
+			Limit:        10,
+		}, []domain.Task{notTaken}},
+		{"taken", contracts.TaskFilter{Taken: &isTaken, Limit: 10}, []domain.Task{taken}},
+		{"not taken", contracts.TaskFilter{Taken: &isNotTaken, Limit: 10}, []domain.Task{notTaken, later}},
+		{"taken by the instance", contracts.TaskFilter{TakenBy: appInstanceId, Limit: 10}, []domain.Task{taken}},
+		{"taken by other instance", contracts.TaskFilter{TakenBy: util.NewId(), Limit: 10}, []domain.Task{}},
 	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tasks, err := repository.List(context.Background(), test.filter)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, tasks)
+		})
+	}
+}
 
-	close(startWorkers)
-	workersDone.Wait()
+func TestReschedule(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
 
-	for i, item := range input {
-		assert.Equal(t,
-			item.tasksCount*workersCount,
-			getCountTasksByParamsInDb(item.isTaken, now+item.relativeExecTime),
-			fmt.Sprintf("Count of tasks is not correct (isTaken:%t, execTime:%d, input item: %d)",
-				item.isTaken, now+item.relativeExecTime, i),
-		)
+		testRescheduling(t, repository)
+		assert.Equal(t, 1, getCountTasksByParamsInDb(backend, true, time.Now().Unix()+10),
+			"the task must be moved to the collection taken by the instance")
 
-		/*
-			Due to concurrent access to DB may be created extra collection. It is not big problem.
-			It is assumed that the number of extra collections should not exceed twice the norm.
-		*/
-		maxApproximatelyCountOfCollections :=
-			int(float64(item.tasksCount*workersCount) / float64(maxCountTasksInCollection) * 2)
-
-		assert.LessOrEqual(t,
-			getCountCollectionsByParamsInDb(item.isTaken, now+item.relativeExecTime),
-			maxApproximatelyCountOfCollections,
-			fmt.Sprintf("Count of collections is not correct (isTaken:%t, execTime:%d, input item: %d)",
-				item.isTaken, now+item.relativeExecTime, i),
-		)
+		clear(backend)
+	})
+}
+
+/*
+	The same scenario for the database and the memory
+*/
+func testRescheduling(t *testing.T, repository contracts.RepositoryInterface) {
+	now := time.Now().Unix()
+	task := domain.Task{
+		Id:         util.NewId(),
+		ExecTime:   now + 3600,
+		Payload:    []byte("payload"),
+		Headers:    map[string]string{"type": "reminder"},
+		Recurrence: &domain.Recurrence{Interval: 60},
+		Priority:   10,
 	}
+	assert.NoError(t, repository.Create(context.Background(), task, false))
+	task.Attempts = 2
+	assert.NoError(t, repository.UpdateAttempts(context.Background(), task))
+
+	newTime := domain.Task{Id: task.Id}
+	newTime.SetExecTimeMs((now + 10) * 1000)
+	rescheduled, err := repository.Reschedule(context.Background(), newTime, true)
+	assert.NoError(t, err)
+
+	expected := task
+	expected.ExecTime = now + 10
+	assert.Equal(t, expected, rescheduled, "the fields of the task except the time of execution must be kept")
+
+	actual, err := repository.Get(context.Background(), task.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	tasks, err := repository.List(context.Background(), contracts.TaskFilter{TakenBy: appInstanceId, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Task{expected}, tasks, "the task must be taken by the instance")
+
+	_, err = repository.Reschedule(context.Background(), domain.Task{Id: util.NewId(), ExecTime: now}, false)
+	assert.Equal(t, contracts.RepoErrorTaskNotFound, err)
 }
 
-func TestDeleteBunch(t *testing.T) {
-	clear()
-	loadFixtures("data_3")
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{
-		NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
-			assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
-		},
-	}, &Options{
-		MaxCountTasksInCollection: 1000,
-		CleaningFrequency:         1,
+func TestCreateAndDeleteBatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, &Options{MaxCountTasksInCollection: 2})
+
+		testBatch(t, repository, func(execTime int64) int {
+			return getCountCollectionsByParamsInDb(backend, false, execTime)
+		})
+
+		clear(backend)
 	})
+}
 
-	tasksMustNotBeDeleted := []domain.Task{
-		{Id: "1657bd33-83d0-4a02-ab23-288a8ea33452"},
-		{Id: "19dd8fce-b3f6-4347-b6fa-d9d075a22c71"},
-		{Id: "1b959ecb-6b18-48aa-8804-cc604201675e"},
-		{Id: "05721684-22ec-499b-9267-6498e57d5755"},
-		{Id: "087c988b-1b68-46d8-8a45-efdf916a84b1"},
-		{Id: "01c796df-7e2d-4981-86b0-67eb1a7fc45b"},
-		{Id: "02ab39b5-d3ed-4de4-8f1c-bd4894cbee02"},
-		{Id: "a0d96c2e-46f3-11eb-9f26-5ee87590738f"},
-		{Id: "a128b982-46f3-11eb-9f26-5ee87590738f"},
-		{Id: "131290fe-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "13287d06-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "13763ed8-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "aa1de8ae-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "aa29fc84-46f4-11eb-9f26-5ee87590738f"},
-	}
-
-	tasksMustBeDeleted := []domain.Task{
-		{Id: "0aa6c808-207d-453f-8d8f-a3775f99f05c"},
-		{Id: "13cbc999-c428-4d4f-bad3-3959e747769c"},
-		{Id: "02b9bc69-4fb7-4519-b51f-8e66dc48a26a"},
-		{Id: "059de99b-30a5-4018-8c79-f44767580cd5"},
-		{Id: "070df841-9a71-4a06-bb55-12cf096acbda"},
-		{Id: "0eaadd31-71e8-4dc2-a687-ed981e1d78ad"},
-		{Id: "0f798362-2e12-48df-b1ea-f78736807005"},
-		{Id: "021cba07-8fa0-4111-bf5c-338b40fd856c"},
-		{Id: "03070b08-df29-4ef7-8226-d628548ff0ed"},
-		{Id: "03e4d83d-9c42-461f-b663-205c26950dce"},
-		{Id: "058b3fd3-2a07-46b0-aa95-099aa2abbc78"},
-		{Id: "05db598c-abbb-4026-b0fe-ee19289c25f2"},
-		{Id: "0a62e9d9-a4b4-4053-8563-b24b8ce4bcaa"},
-		{Id: "0aa88a99-9872-4cf9-aca7-ad23a45e8f60"},
-		{Id: "0ba79a57-b5c7-409c-b15a-bf70222b7d13"},
-		{Id: "0c1acbde-aeec-4cee-866b-bb455b98f5d5"},
-		{Id: "0e0cba0f-493b-4294-a11c-e26e917d0ab7"},
-		{Id: "000c1709-b8a6-4674-9e6b-7415bd9308a3"},
-		{Id: "002e256b-30c6-4562-a2af-5d778a6caabd"},
-		{Id: "003f5516-31e3-4f81-b819-9af1c1cc6a4a"},
-		{Id: "00717d01-7d07-48ba-b007-7979a6b14940"},
-		{Id: "008797b1-340f-4440-80f8-b5e76622501a"},
-		{Id: "005b5228-97e2-450c-adb7-c7f2248cab46"},
-		{Id: "00785c9a-9b5e-4b9d-aad1-055ca1543cce"},
-		{Id: "00a0ace5-45e1-4353-a007-e23fd1d1dbff"},
-		{Id: "00a60038-d738-44c7-bfe8-a6741ab0024b"},
-		{Id: "01a17c46-7536-406c-bda6-3b204ddcdba0"},
-		{Id: "03ffca7b-fb47-4db6-8bd9-8a8f729d8801"},
-		{Id: "043158b7-8a22-4ea1-83b0-46b76a34822f"},
-		{Id: "0432ff60-1fef-42f7-9d43-c116a60bfdf3"},
-		{Id: "0012f748-fb2a-49cd-a455-e73c0212466a"},
-		{Id: "00613adf-8104-47bc-b655-7589bef7bdd6"},
-		{Id: "00c8851e-a675-4ed4-81f1-5cd94c0c5d6a"},
-		{Id: "9ebdfa40-46f3-11eb-9f26-5ee87590738f"},
-		{Id: "a0064d94-46f3-11eb-9f26-5ee87590738f"},
-		{Id: "a0876816-46f3-11eb-9f26-5ee87590738f"},
-		{Id: "12dceaa8-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "12f7adb6-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "85dc6970-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "86283026-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "86347c50-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "8642c4e0-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "86501370-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "a9b74ec8-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "aa03602e-46f4-11eb-9f26-5ee87590738f"},
-		{Id: "aa10b454-46f4-11eb-9f26-5ee87590738f"},
-	}
-
-	collectionsMustBeDeleted := []int{25, 67, 107, 129}
-	collectionsMustNotBeDeleted := []int{14, 37, 42, 48, 63, 74, 81, 94, 100, 110, 122, 123, 124, 125, 126, 127, 128}
-
-	_, err := repository.Delete(context.Background(), tasksMustBeDeleted)
-	if err != nil {
-		log.Fatal(err, "Error while delete")
+/*
+	The same scenario for the database and the memory
+*/
+func testBatch(t *testing.T, repository contracts.RepositoryInterface, countCollections func(execTime int64) int) {
+	now := time.Now().Unix()
+	existing := domain.Task{Id: util.NewId(), ExecTime: now + 10}
+	assert.NoError(t, repository.Create(context.Background(), existing, false))
+
+	tasks := []domain.Task{
+		{Id: util.NewId(), ExecTime: now + 10, Payload: []byte("payload")},
+		existing,
+		{Id: util.NewId(), ExecTime: now + 10, Headers: map[string]string{"type": "reminder"}},
+		{Id: util.NewId(), ExecTime: now + 10, Recurrence: &domain.Recurrence{Interval: 60}},
+		{Id: util.NewId(), ExecTime: now + 20, Priority: -1},
 	}
+	tasks = append(tasks, tasks[0])
+
+	results, err := repository.CreateBatch(context.Background(), tasks, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, contracts.RepoErrorTaskExist, nil, nil, nil, contracts.RepoErrorTaskExist}, results,
+		"the existing and the repeated tasks must not be created")
+
+	listed, err := repository.List(context.Background(), contracts.TaskFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []domain.Task{existing, tasks[0], tasks[2], tasks[3], tasks[4]}, listed)
+
+	assert.Equal(t, 2, countCollections(now+10), "the collection which is not filled must be filled first")
+	assert.Equal(t, 1, countCollections(now+20))
+
+	results, err = repository.DeleteBatch(context.Background(), []string{tasks[0].Id, util.NewId(), existing.Id, tasks[0].Id})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, contracts.RepoErrorTaskNotFound, nil, contracts.RepoErrorTaskNotFound}, results)
+
+	count, err := repository.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
 
-	for _, task := range tasksMustNotBeDeleted {
-		assert.True(t, isTaskExistInDb(task.Id), fmt.Sprintf("the task %s not found", task.Id))
+func TestQueues(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
+
+		testQueues(t, repository)
+
+		clear(backend)
+	})
+}
+
+/*
+	The same scenario for the database and the memory
+*/
+func testQueues(t *testing.T, repository contracts.RepositoryInterface) {
+	now := time.Now().Unix()
+	defaultTask := domain.Task{Id: util.NewId(), ExecTime: now}
+	email := domain.Task{Id: util.NewId(), ExecTime: now, Queue: "emails"}
+	sms := domain.Task{Id: util.NewId(), ExecTime: now, Queue: "sms"}
+	for _, task := range []domain.Task{defaultTask, email, sms} {
+		assert.NoError(t, repository.Create(context.Background(), task, false))
 	}
+	otherEmail := domain.Task{Id: util.NewId(), ExecTime: now, Queue: "emails"}
+	results, err := repository.CreateBatch(context.Background(), []domain.Task{otherEmail}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil}, results)
+
+	actual, err := repository.Get(context.Background(), sms.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, sms, actual, "the queue of the task must be kept")
+
+	assert.ElementsMatch(t, []domain.Task{email, otherEmail}, takeTasks(t, repository, "emails"),
+		"only the tasks of the queue must be taken")
+	assert.ElementsMatch(t, []domain.Task{defaultTask, sms}, takeTasks(t, repository),
+		"the tasks of all queues must be taken if the queues are not specified")
+}
 
-	for _, id := range collectionsMustNotBeDeleted {
-		assert.True(t, isCollectionExistInDb(id), fmt.Sprintf("the collection %d not found", id))
+func takeTasks(t *testing.T, repository contracts.RepositoryInterface, queues ...string) []domain.Task {
+	var tasks []domain.Task
+	collections, err := repository.FindBySecToExecTime(context.Background(), time.Second, queues)
+	if err == contracts.RepoErrorNoTasksFound {
+		return tasks
 	}
+	assert.NoError(t, err)
 
-	for _, id := range collectionsMustBeDeleted {
-		assert.False(t, isCollectionExistInDb(id), fmt.Sprintf("the collection %d was found", id))
+	for {
+		tasksOfCollection, err := collections.Next(context.Background())
+		if err == contracts.RepoErrorNoCollections {
+			return tasks
+		}
+		assert.NoError(t, err)
+		tasks = append(tasks, tasksOfCollection...)
 	}
 }
 
-func TestCreate(t *testing.T) {
-	clear()
-	maxCountTasksInCollection := 100
-	repository := New(db, appInstanceId, &error_service.ErrorHandlerMock{}, &Options{maxCountTasksInCollection, 10})
-
-	input := []struct {
-		tasksCount       int
-		isTaken          bool
-		relativeExecTime int64
-	}{
-		{110, false, -5},
-		{150, false, 0},
-		{180, false, 1},
-		{220, false, 2},
-		{140, true, -5},
-		{250, true, 0},
-		{120, true, 1},
-		{30, true, 2},
-	}
+func TestIdempotencyKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		options := &Options{IdempotencyKeyRetention: time.Hour}
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, options)
+
+		testIdempotencyKeys(t, repository, options)
 
+		clear(backend)
+	})
+}
+
+/*
+	The key reserved by the concurrent transaction must not be reported as the existing task,
+	so the id of the task which has reserved the key is found by the key
+*/
+func TestConcurrentIdempotencyKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{}, nil)
+
+		workersCount := 10
+		results := make(chan error, workersCount)
+		now := time.Now().Unix()
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < workersCount; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+
+				task := domain.Task{Id: util.NewId(), ExecTime: now, IdempotencyKey: "concurrent"}
+				for {
+					//	The deadlocks are retried by the task manager
+					err := repository.Create(context.Background(), task, false)
+					if err != contracts.RepoErrorDeadlock && err != contracts.RepoErrorLockWaitTimeout {
+						results <- err
+						return
+					}
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(results)
+
+		created := 0
+		for err := range results {
+			if err == nil {
+				created++
+				continue
+			}
+			assert.Equal(t, contracts.RepoErrorIdempotencyKeyExist, err)
+		}
+		assert.Equal(t, 1, created, "only one task must be created with the key")
+
+		_, err := repository.GetIdByIdempotencyKey(context.Background(), "concurrent")
+		assert.NoError(t, err)
+
+		clear(backend)
+	})
+}
+
+/*
+	The same scenario for the database and the memory. The retention is changed by the options
+*/
+func testIdempotencyKeys(t *testing.T, repository contracts.RepositoryInterface, options *Options) {
+	ctx := context.Background()
 	now := time.Now().Unix()
-	for _, item := range input {
-		for i := 0; i < item.tasksCount; i++ {
-			errCreate := repository.Create(context.Background(), getTaskInstance(now+item.relativeExecTime), item.isTaken)
-			if errCreate != nil {
-				log.Fatal(errCreate, "Error while create")
+	task := domain.Task{Id: util.NewId(), ExecTime: now, IdempotencyKey: "reminder"}
+	assert.NoError(t, repository.Create(ctx, task, false))
+
+	actual, err := repository.Get(ctx, task.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, task, actual, "the idempotency key of the task must be kept")
+
+	duplicate := domain.Task{Id: util.NewId(), ExecTime: now, IdempotencyKey: "reminder"}
+	assert.Equal(t, contracts.RepoErrorIdempotencyKeyExist, repository.Create(ctx, duplicate, false))
+	taskId, err := repository.GetIdByIdempotencyKey(ctx, "reminder")
+	assert.NoError(t, err)
+	assert.Equal(t, task.Id, taskId)
+
+	_, err = repository.GetIdByIdempotencyKey(ctx, "unknown")
+	assert.Equal(t, contracts.RepoErrorTaskNotFound, err)
+
+	other := domain.Task{Id: util.NewId(), ExecTime: now, IdempotencyKey: "other"}
+	results, err := repository.CreateBatch(ctx, []domain.Task{
+		duplicate,
+		other,
+		{Id: util.NewId(), ExecTime: now, IdempotencyKey: "other"},
+		{Id: util.NewId(), ExecTime: now},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{contracts.RepoErrorIdempotencyKeyExist, nil, contracts.RepoErrorIdempotencyKeyExist, nil}, results,
+		"the keys reserved by the existing tasks and the keys repeated in the batch must not be reserved again")
+
+	deleted, err := repository.Delete(ctx, []domain.Task{task})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, contracts.RepoErrorIdempotencyKeyExist, repository.Create(ctx, duplicate, false),
+		"the key of the executed task must be reserved during the retention")
+
+	options.IdempotencyKeyRetention = time.Nanosecond
+	results, err = repository.DeleteBatch(ctx, []string{other.Id})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil}, results)
+
+	//	The time of the expiration is stored in seconds
+	time.Sleep(time.Until(time.Unix(time.Now().Unix()+1, 0)) + 10*time.Millisecond)
+
+	recreated := domain.Task{Id: util.NewId(), ExecTime: now, IdempotencyKey: "other"}
+	assert.NoError(t, repository.Create(ctx, recreated, false), "the key must be released after the retention")
+	taskId, err = repository.GetIdByIdempotencyKey(ctx, "other")
+	assert.NoError(t, err)
+	assert.Equal(t, recreated.Id, taskId)
+}
+
+func TestDeleteAndCreate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend *backend) {
+		clear(backend)
+		repository := backend.new(backend.db, appInstanceId, &error_service.ErrorHandlerMock{
+			NewMock: func(level contracts.Level, eventMessage string, extra map[string]interface{}) {
+				assert.Fail(t, fmt.Sprintf("not expected error: %s", eventMessage))
+			},
+		}, nil)
+
+		now := time.Now().Unix()
+		onceTask := domain.Task{Id: util.NewId(), ExecTime: now}
+		recurrentTask := domain.Task{
+			Id:         util.NewId(),
+			ExecTime:   now,
+			Payload:    []byte("payload"),
+			Recurrence: &domain.Recurrence{Interval: 60},
+		}
+		deletedRecurrentTask := domain.Task{Id: util.NewId(), ExecTime: now, Recurrence: &domain.Recurrence{Interval: 60}}
+
+		for _, task := range []domain.Task{onceTask, recurrentTask} {
+			if err := repository.Create(context.Background(), task, true); err != nil {
+				log.Fatal(err, "Error while create")
 			}
 		}
-	}
 
-	for _, item := range input {
-		assert.Equal(t,
-			item.tasksCount,
-			getCountTasksByParamsInDb(item.isTaken, now+item.relativeExecTime),
-			fmt.Sprintf("Count of tasks is not correct (isTaken:%t, execTime:%d)",
-				item.isTaken, now+item.relativeExecTime),
-		)
+		nextTask := recurrentTask
+		nextTask.ExecTime = now + 60
+		nextTask.Recurrence = &domain.Recurrence{Interval: 60, Occurrence: 1}
+		nextOfDeletedTask := deletedRecurrentTask
+		nextOfDeletedTask.ExecTime = now + 60
 
-		assert.Equal(t,
-			int(math.Ceil(float64(item.tasksCount)/float64(maxCountTasksInCollection))),
-			getCountCollectionsByParamsInDb(item.isTaken, now+item.relativeExecTime),
-			fmt.Sprintf("Count of collections is not correct (isTaken:%t, execTime:%d)",
-				item.isTaken, now+item.relativeExecTime),
+		deleted, created, err := repository.DeleteAndCreate(
+			context.Background(),
+			[]domain.Task{onceTask, recurrentTask, deletedRecurrentTask},
+			[]domain.Task{nextTask, nextOfDeletedTask},
 		)
-	}
+		if err != nil {
+			log.Fatal(err, "Error while delete and create")
+		}
+
+		assert.Equal(t, int64(2), deleted, "count of deleted tasks is not correct")
+		assert.Equal(t, int64(1), created, "count of created tasks is not correct")
+		assert.False(t, isTaskExistInDb(backend, onceTask.Id), "the task must be deleted")
+		assert.False(t, isTaskExistInDb(backend, deletedRecurrentTask.Id), "the deleted task must not be created again")
+		assert.Equal(t, 0, getCountTasksByParamsInDb(backend, true, now), "the tasks must be deleted")
+		assert.Equal(t, 1, getCountTasksByParamsInDb(backend, false, now+60), "the next occurrence must be created")
+
+		collections, err := repository.FindBySecToExecTime(context.Background(), 61*time.Second, nil)
+		if err != nil {
+			log.Fatal(err, "Error while get tasks")
+		}
+
+		tasks, err := collections.Next(context.Background())
+		if err != nil {
+			log.Fatal(err, "Getting next part is fail")
+		}
+		assert.Equal(t, []domain.Task{nextTask}, tasks, "the next occurrence is not correct")
+	})
 }
 
 /*	----------------------------------------------------
@@ -409,7 +1161,7 @@ func getTaskInstance(execTime int64) domain.Task {
 	return domain.Task{Id: util.NewId(), ExecTime: execTime}
 }
 
-func getCountTasksByParamsInDb(isTaken bool, execTime int64) int {
+func getCountTasksByParamsInDb(backend *backend, isTaken bool, execTime int64) int {
 	op := "!="
 	if isTaken {
 		op = "="
@@ -419,7 +1171,7 @@ func getCountTasksByParamsInDb(isTaken bool, execTime int64) int {
 		FROM task
 		INNER JOIN collection c on task.collection_id = c.id
 		WHERE exec_time = ? AND taken_by_instance %s ?`, op)
-	err := db.QueryRow(query, execTime, appInstanceId).Scan(&count)
+	err := backend.db.QueryRow(backend.rebind(query), execTime, appInstanceId).Scan(&count)
 	switch {
 	case err == sql.ErrNoRows:
 		return 0
@@ -430,7 +1182,7 @@ func getCountTasksByParamsInDb(isTaken bool, execTime int64) int {
 	return count
 }
 
-func getCountCollectionsByParamsInDb(isTaken bool, execTime int64) int {
+func getCountCollectionsByParamsInDb(backend *backend, isTaken bool, execTime int64) int {
 	op := "!="
 	if isTaken {
 		op = "="
@@ -439,7 +1191,7 @@ func getCountCollectionsByParamsInDb(isTaken bool, execTime int64) int {
 	query := fmt.Sprintf(`SELECT count(id)
 		FROM collection
 		WHERE exec_time = ? AND taken_by_instance %s ?`, op)
-	err := db.QueryRow(query, execTime, appInstanceId).Scan(&count)
+	err := backend.db.QueryRow(backend.rebind(query), execTime, appInstanceId).Scan(&count)
 	switch {
 	case err == sql.ErrNoRows:
 		return 0
@@ -450,20 +1202,32 @@ func getCountCollectionsByParamsInDb(isTaken bool, execTime int64) int {
 	return count
 }
 
-func clear() {
-	_, errTruncateTask := db.Exec("delete from task")
+func clear(backend *backend) {
+	_, errTruncateInstance := backend.db.Exec("delete from instance")
+	if errTruncateInstance != nil {
+		log.Fatal(errTruncateInstance, "Error clear instance")
+	}
+	_, errTruncateDeadLetter := backend.db.Exec("delete from dead_letter")
+	if errTruncateDeadLetter != nil {
+		log.Fatal(errTruncateDeadLetter, "Error clear dead letter")
+	}
+	_, errTruncateTask := backend.db.Exec("delete from task")
 	if errTruncateTask != nil {
 		log.Fatal(errTruncateTask, "Error clear task")
 	}
-	_, errTruncateCollection := db.Exec("delete from collection")
+	_, errTruncateCollection := backend.db.Exec("delete from collection")
 	if errTruncateCollection != nil {
 		log.Fatal(errTruncateCollection, "Error clear collection")
 	}
+	_, errTruncateIdempotencyKey := backend.db.Exec("delete from idempotency_key")
+	if errTruncateIdempotencyKey != nil {
+		log.Fatal(errTruncateIdempotencyKey, "Error clear idempotency key")
+	}
 }
 
-func isTaskExistInDb(taskId string) bool {
+func isTaskExistInDb(backend *backend, taskId string) bool {
 	var id string
-	err := db.QueryRow("SELECT uuid FROM task WHERE uuid = ?", taskId).Scan(&id)
+	err := backend.db.QueryRow(backend.rebind("SELECT uuid FROM task WHERE uuid = ?"), taskId).Scan(&id)
 
 	switch {
 	case err == sql.ErrNoRows:
@@ -475,9 +1239,9 @@ func isTaskExistInDb(taskId string) bool {
 	return id == taskId
 }
 
-func isCollectionExistInDb(collectionId int) bool {
+func isCollectionExistInDb(backend *backend, collectionId int) bool {
 	var id int
-	err := db.QueryRow("SELECT id FROM collection WHERE id = ?", collectionId).Scan(&id)
+	err := backend.db.QueryRow(backend.rebind("SELECT id FROM collection WHERE id = ?"), collectionId).Scan(&id)
 
 	switch {
 	case err == sql.ErrNoRows:
@@ -489,6 +1253,19 @@ func isCollectionExistInDb(collectionId int) bool {
 	return id == collectionId
 }
 
+/*
+	Collections of fixtures are inserted with explicit ids, so the sequence of ids must be moved forward
+*/
+func resetSequence(backend *backend) {
+	if backend.resetSequence == "" {
+		return
+	}
+
+	if _, err := backend.db.Exec(backend.resetSequence); err != nil {
+		panic(err)
+	}
+}
+
 func find(a []int, x int) ([]int, bool) {
 	for i, n := range a {
 		if x == n {
@@ -498,7 +1275,7 @@ func find(a []int, x int) ([]int, bool) {
 	return a, false
 }
 
-func loadFixtures(testDir string) {
+func loadFixtures(backend *backend, testDir string) {
 	/*
 		collection fixture
 	*/
@@ -533,10 +1310,11 @@ func loadFixtures(testDir string) {
 	}
 	insertCollection = insertCollection[:len(insertCollection)-len(",")]
 
-	_, err = db.Exec(insertCollection, insertCollectionArgs...)
+	_, err = backend.db.Exec(backend.rebind(insertCollection), insertCollectionArgs...)
 	if err != nil {
 		panic(err)
 	}
+	resetSequence(backend)
 
 	/*
 		task fixture
@@ -569,7 +1347,7 @@ func loadFixtures(testDir string) {
 		if len(insertTaskArgs) > 1000 || isEnd {
 			values = values[:len(values)-len(",")]
 
-			_, err = db.Exec(insertTask+values, insertTaskArgs...)
+			_, err = backend.db.Exec(backend.rebind(insertTask+values), insertTaskArgs...)
 			if err != nil {
 				panic(err)
 			}
@@ -595,7 +1373,7 @@ type collection struct {
 	ExecTime        int64
 }
 
-func upFixtures(collections []collection, tasks []task) {
+func upFixtures(backend *backend, collections []collection, tasks []task) {
 	/*
 		collection fixture
 	*/
@@ -609,10 +1387,11 @@ func upFixtures(collections []collection, tasks []task) {
 	}
 	insertCollection = insertCollection[:len(insertCollection)-len(",")]
 
-	_, err := db.Exec(insertCollection, insertCollectionArgs...)
+	_, err := backend.db.Exec(backend.rebind(insertCollection), insertCollectionArgs...)
 	if err != nil {
 		panic(err)
 	}
+	resetSequence(backend)
 
 	/*
 		task fixture
@@ -629,7 +1408,7 @@ func upFixtures(collections []collection, tasks []task) {
 		if len(insertTaskArgs) > 1000 {
 			values = values[:len(values)-len(",")]
 
-			_, err = db.Exec(insertTask+values, insertTaskArgs...)
+			_, err = backend.db.Exec(backend.rebind(insertTask+values), insertTaskArgs...)
 			if err != nil {
 				panic(err)
 			}
@@ -0,0 +1,153 @@
+//go:build !nopostgres
+// +build !nopostgres
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/pvelx/triggerhook/contracts"
+)
+
+func NewPostgres(
+	client *sql.DB,
+	appInstanceId string,
+	eh contracts.EventHandlerInterface,
+	options *Options,
+) contracts.RepositoryInterface {
+
+	return &sqlRepository{
+		client:        client,
+		appInstanceId: appInstanceId,
+		eh:            eh,
+		options:       mergeDefaultOptions(options),
+		dialect:       postgresDialect{},
+	}
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) schema(*Options) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS collection
+		(
+			id BIGSERIAL PRIMARY KEY,
+			exec_time BIGINT NOT NULL,
+			taken_by_instance VARCHAR(36) DEFAULT '' NOT NULL,
+			queue VARCHAR(255) DEFAULT '' NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS collection_exec_time_idx ON collection (exec_time)`,
+		`CREATE INDEX IF NOT EXISTS collection_taken_by_instance_idx ON collection (taken_by_instance, exec_time)`,
+		`CREATE TABLE IF NOT EXISTS task
+		(
+			uuid VARCHAR(36) NOT NULL PRIMARY KEY,
+			collection_id BIGINT NOT NULL,
+			exec_time_ms BIGINT NULL,
+			payload BYTEA NULL,
+			headers TEXT NULL,
+			recurrence TEXT NULL,
+			attempts INTEGER DEFAULT 0 NOT NULL,
+			priority INTEGER DEFAULT 0 NOT NULL,
+			idempotency_key VARCHAR(255) DEFAULT '' NOT NULL,
+			CONSTRAINT task_collection_id_fk FOREIGN KEY (collection_id) REFERENCES collection (id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS task_collection_id_idx ON task (collection_id)`,
+		`ALTER TABLE task ADD COLUMN attempts INTEGER DEFAULT 0 NOT NULL`,
+		`ALTER TABLE task ADD COLUMN exec_time_ms BIGINT NULL`,
+		`CREATE INDEX IF NOT EXISTS task_exec_time_ms_idx ON task (exec_time_ms)`,
+		`CREATE TABLE IF NOT EXISTS dead_letter
+		(
+			uuid VARCHAR(36) NOT NULL PRIMARY KEY,
+			exec_time BIGINT NOT NULL,
+			payload BYTEA NULL,
+			headers TEXT NULL,
+			recurrence TEXT NULL,
+			attempts INTEGER DEFAULT 0 NOT NULL,
+			priority INTEGER DEFAULT 0 NOT NULL,
+			idempotency_key VARCHAR(255) DEFAULT '' NOT NULL,
+			queue VARCHAR(255) DEFAULT '' NOT NULL,
+			reason TEXT NOT NULL,
+			dead_lettered_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS dead_letter_dead_lettered_at_idx ON dead_letter (dead_lettered_at)`,
+		`ALTER TABLE collection ADD COLUMN queue VARCHAR(255) DEFAULT '' NOT NULL`,
+		`ALTER TABLE dead_letter ADD COLUMN queue VARCHAR(255) DEFAULT '' NOT NULL`,
+		`ALTER TABLE task ADD COLUMN priority INTEGER DEFAULT 0 NOT NULL`,
+		`ALTER TABLE dead_letter ADD COLUMN priority INTEGER DEFAULT 0 NOT NULL`,
+		`ALTER TABLE task ADD COLUMN idempotency_key VARCHAR(255) DEFAULT '' NOT NULL`,
+		`ALTER TABLE dead_letter ADD COLUMN idempotency_key VARCHAR(255) DEFAULT '' NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS idempotency_key
+		(
+			idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+			uuid VARCHAR(36) NOT NULL,
+			expires_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idempotency_key_uuid_idx ON idempotency_key (uuid)`,
+		`CREATE INDEX IF NOT EXISTS idempotency_key_expires_at_idx ON idempotency_key (expires_at)`,
+		`CREATE TABLE IF NOT EXISTS instance
+		(
+			id VARCHAR(36) NOT NULL PRIMARY KEY,
+			heartbeat_at BIGINT NOT NULL
+		)`,
+	}
+}
+
+func (postgresDialect) rebind(query string) string {
+	var builder strings.Builder
+	number := 0
+	for _, char := range query {
+		if char == '?' {
+			number++
+			builder.WriteString("$" + strconv.Itoa(number))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+/*
+	NO KEY UPDATE does not conflict with the lock taken by the foreign key of a new task,
+	so creating tasks does not prevent the collection from being taken
+*/
+func (postgresDialect) lockClause(skipLocked bool) string {
+	if skipLocked {
+		return " FOR NO KEY UPDATE SKIP LOCKED"
+	}
+
+	return " FOR UPDATE"
+}
+
+func (postgresDialect) insertId(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	return insertReturningId(ctx, tx, query, args...)
+}
+
+func (postgresDialect) upsertClause(key string, columns ...string) string {
+	return onConflictClause(key, columns...)
+}
+
+func (postgresDialect) isDuplicateColumn(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+
+	return ok && pqErr.Code == "42701" // duplicate_column
+}
+
+func (postgresDialect) convertError(err error, defaultError error) error {
+	if err, ok := err.(*pq.Error); ok {
+		switch err.Code {
+		case "23505": // unique_violation
+			return contracts.RepoErrorTaskExist
+		case "40P01", "40001": // deadlock_detected, serialization_failure
+			return contracts.RepoErrorDeadlock
+		case "55P03": // lock_not_available
+			return contracts.RepoErrorLockWaitTimeout
+		}
+	}
+
+	return defaultError
+}
@@ -0,0 +1,22 @@
+//go:build !nopostgres
+// +build !nopostgres
+
+package repository
+
+var postgresDsn = getEnv("POSTGRES_DSN", "")
+
+func init() {
+	newBackends = append(newBackends, func() *backend {
+		if postgresDsn == "" {
+			return nil
+		}
+
+		return &backend{
+			name:          "postgres",
+			db:            open("postgres", postgresDsn),
+			new:           NewPostgres,
+			rebind:        postgresDialect{}.rebind,
+			resetSequence: "SELECT setval('collection_id_seq', (SELECT COALESCE(max(id), 1) FROM collection))",
+		}
+	})
+}
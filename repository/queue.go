@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Condition of the query selecting the collections of the queues and its arguments.
+	The collections of all queues are selected if the queues are not specified
+*/
+func queuesCondition(queues []string) (string, []interface{}) {
+	if len(queues) == 0 {
+		return "", nil
+	}
+
+	args := make([]interface{}, 0, len(queues))
+	for _, queue := range queues {
+		args = append(args, queue)
+	}
+
+	return fmt.Sprintf(" AND queue IN (?%s)", strings.Repeat(",?", len(queues)-1)), args
+}
+
+func inQueues(queue string, queues []string) bool {
+	if len(queues) == 0 {
+		return true
+	}
+
+	for _, q := range queues {
+		if q == queue {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,1008 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+/*
+	Differences of SQL databases used by the sql repository
+*/
+type dialect interface {
+	/*
+		Statements setting up the schema. Adding of the existing column is skipped,
+		so the schema created by previous versions is upgraded
+	*/
+	schema(options *Options) []string
+
+	isDuplicateColumn(err error) bool
+
+	/*
+		Converts placeholders "?" to the placeholders of the database
+	*/
+	rebind(query string) string
+
+	/*
+		Clause locking the selected rows. Rows locked by other transactions are skipped when skipLocked is true
+	*/
+	lockClause(skipLocked bool) string
+
+	/*
+		Executes the query inserting the row and returns the id of the row
+	*/
+	insertId(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error)
+
+	/*
+		Clause of the inserting query updating the columns of the row which exists with the same key
+	*/
+	upsertClause(key string, columns ...string) string
+
+	/*
+		Converts the error of the database to the error of the repository
+	*/
+	convertError(err error, defaultError error) error
+}
+
+/*
+	The repository of the SQL databases. The differences of the databases are implemented by the dialects
+*/
+type sqlRepository struct {
+	client            *sql.DB
+	appInstanceId     string
+	eh                contracts.EventHandlerInterface
+	cleanRequestCount int32
+	options           *Options
+	dialect           dialect
+}
+
+func (r *sqlRepository) Count() (int, error) {
+	var count int
+	if err := r.client.QueryRow("SELECT count(*) FROM task").Scan(&count); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return 0, contracts.RepoErrorCountingTasks
+	}
+
+	return count, nil
+}
+
+func (r *sqlRepository) Create(ctx context.Context, task domain.Task, isTaken bool) error {
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		r.eh.New(contracts.LevelError, errTx.Error(), map[string]interface{}{"task": task})
+
+		return r.dialect.convertError(errTx, contracts.RepoErrorCreatingTask)
+	}
+
+	reservedKeys, errFinding := findReservedKeys(ctx, tx, r.dialect.rebind, []domain.Task{task})
+	if errFinding != nil {
+		r.rollback(tx, errFinding)
+
+		return r.dialect.convertError(errors.Cause(errFinding), contracts.RepoErrorCreatingTask)
+	}
+
+	if reservedKeys[task.IdempotencyKey] {
+		if err := tx.Rollback(); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+		}
+
+		return contracts.RepoErrorIdempotencyKeyExist
+	}
+
+	if err := r.createTask(ctx, tx, task, isTaken); err != nil {
+		r.rollback(tx, err)
+
+		return r.dialect.convertError(errors.Cause(err), contracts.RepoErrorCreatingTask)
+	}
+
+	if err := r.options.reserveIdempotencyKeys(ctx, tx, r.dialect.rebind, []domain.Task{task}); err != nil {
+		r.rollback(tx, err)
+
+		return reservingError(r.dialect.convertError(errors.Cause(err), contracts.RepoErrorCreatingTask))
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task": task})
+
+		return r.dialect.convertError(err, contracts.RepoErrorCreatingTask)
+	}
+
+	return nil
+}
+
+/*
+	Adds the task to the collection with the same time of execution which is not filled yet.
+	If there is no such collection, a new one is created.
+*/
+func (r *sqlRepository) createTask(ctx context.Context, tx *sql.Tx, task domain.Task, isTaken bool) error {
+	headers, err := encodeHeaders(task.Headers)
+	if err != nil {
+		return err
+	}
+
+	recurrence, err := encodeRecurrence(task.Recurrence)
+	if err != nil {
+		return err
+	}
+
+	takenByInstance := ""
+	if isTaken {
+		takenByInstance = r.appInstanceId
+	}
+
+	findCollectionQuery := `SELECT c.id
+		FROM collection c LEFT JOIN task t ON c.id = t.collection_id
+		WHERE c.exec_time = ? AND c.taken_by_instance = ? AND c.queue = ?
+		GROUP BY c.id HAVING count(t.uuid) < ? LIMIT 1`
+
+	var collectionId int64
+	errFinding := tx.QueryRowContext(
+		ctx,
+		r.dialect.rebind(findCollectionQuery),
+		r.options.collectionExecTime(task),
+		takenByInstance,
+		task.Queue,
+		r.options.MaxCountTasksInCollection,
+	).Scan(&collectionId)
+
+	switch {
+	case errFinding == sql.ErrNoRows:
+		collectionId, err = r.createCollection(ctx, tx, collectionKey{r.options.collectionExecTime(task), task.Queue}, takenByInstance)
+		if err != nil {
+			return errors.Wrap(err, "creating collection error")
+		}
+	case errFinding != nil:
+		return errors.Wrap(errFinding, "finding collection error")
+	}
+
+	createTaskQuery := `INSERT INTO task (uuid, collection_id, exec_time_ms, payload, headers, recurrence, priority, idempotency_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(
+		ctx,
+		r.dialect.rebind(createTaskQuery),
+		task.Id,
+		collectionId,
+		r.options.taskExecTime(task),
+		task.Payload,
+		headers,
+		recurrence,
+		task.Priority,
+		task.IdempotencyKey,
+	); err != nil {
+		return errors.Wrap(err, "creating task error")
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) createCollection(ctx context.Context, tx *sql.Tx, key collectionKey, takenByInstance string) (int64, error) {
+	return r.dialect.insertId(
+		ctx,
+		tx,
+		r.dialect.rebind("INSERT INTO collection (exec_time, taken_by_instance, queue) VALUES (?, ?, ?)"),
+		key.execTime,
+		takenByInstance,
+		key.queue,
+	)
+}
+
+func (r *sqlRepository) Delete(ctx context.Context, tasks []domain.Task) (int64, error) {
+	if len(tasks) == 0 {
+		return 0, nil
+	}
+
+	var args []interface{}
+	for _, task := range tasks {
+		args = append(args, task.Id)
+	}
+
+	//	The reservation is prolonged first, the key of the task which is not deleted is reserved anyway
+	if err := r.options.retainIdempotencyKeys(ctx, r.client, r.dialect.rebind, args); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return 0, r.dialect.convertError(errors.Cause(err), contracts.RepoErrorDeletingTask)
+	}
+
+	deletingTaskQuery := fmt.Sprintf("DELETE FROM task WHERE uuid IN (?%s)",
+		strings.Repeat(",?", len(tasks)-1))
+
+	result, errDeleting := r.client.ExecContext(ctx, r.dialect.rebind(deletingTaskQuery), args...)
+	if errDeleting != nil {
+		r.eh.New(contracts.LevelError, errDeleting.Error(), nil)
+
+		return 0, r.dialect.convertError(errDeleting, contracts.RepoErrorDeletingTask)
+	}
+
+	affected, _ := result.RowsAffected()
+
+	r.deleteEmptyCollectionsSometimes(ctx)
+
+	return affected, nil
+}
+
+func (r *sqlRepository) CreateBatch(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+	if len(tasks) == 0 {
+		return []error{}, nil
+	}
+
+	takenByInstance := ""
+	if isTaken {
+		takenByInstance = r.appInstanceId
+	}
+
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return nil, r.dialect.convertError(errTx, contracts.RepoErrorCreatingTask)
+	}
+
+	results, errCreating := r.options.createBatch(ctx, tx, r.dialect.rebind, tasks, takenByInstance, func(key collectionKey) (int64, error) {
+		return r.createCollection(ctx, tx, key, takenByInstance)
+	})
+	if errCreating != nil {
+		r.rollback(tx, errCreating)
+
+		return nil, r.dialect.convertError(errors.Cause(errCreating), contracts.RepoErrorCreatingTask)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return nil, r.dialect.convertError(err, contracts.RepoErrorCreatingTask)
+	}
+
+	return results, nil
+}
+
+func (r *sqlRepository) DeleteBatch(ctx context.Context, taskIds []string) ([]error, error) {
+	if len(taskIds) == 0 {
+		return []error{}, nil
+	}
+
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return nil, r.dialect.convertError(errTx, contracts.RepoErrorDeletingTask)
+	}
+
+	results, errDeleting := r.options.deleteBatch(ctx, tx, r.dialect.rebind, taskIds, r.dialect.lockClause(false))
+	if errDeleting != nil {
+		r.rollback(tx, errDeleting)
+
+		return nil, r.dialect.convertError(errors.Cause(errDeleting), contracts.RepoErrorDeletingTask)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return nil, r.dialect.convertError(err, contracts.RepoErrorDeletingTask)
+	}
+
+	r.deleteEmptyCollectionsSometimes(ctx)
+
+	return results, nil
+}
+
+func (r *sqlRepository) DeleteAndCreate(ctx context.Context, tasks []domain.Task, nextTasks []domain.Task) (
+	deleted int64, created int64, error error) {
+
+	if len(tasks) == 0 {
+		return
+	}
+
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		error = r.dialect.convertError(errTx, contracts.RepoErrorDeletingTask)
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return
+	}
+
+	/*
+		The next occurrence must not be created if the task was deleted by someone else
+	*/
+	existingTasks := make(map[string]bool, len(nextTasks))
+	if len(nextTasks) > 0 {
+		var args []interface{}
+		for _, task := range nextTasks {
+			args = append(args, task.Id)
+		}
+
+		findTasksQuery := fmt.Sprintf("SELECT uuid FROM task WHERE uuid IN (?%s)%s",
+			strings.Repeat(",?", len(nextTasks)-1), r.dialect.lockClause(false))
+
+		ids, err := r.queryIds(ctx, tx, findTasksQuery, args...)
+		if err != nil {
+			error = r.dialect.convertError(errors.Cause(err), contracts.RepoErrorDeletingTask)
+			r.rollback(tx, err)
+
+			return
+		}
+
+		for _, id := range ids {
+			existingTasks[id] = true
+		}
+	}
+
+	var args []interface{}
+	for _, task := range tasks {
+		args = append(args, task.Id)
+	}
+
+	if err := r.options.retainIdempotencyKeys(ctx, tx, r.dialect.rebind, args); err != nil {
+		error = r.dialect.convertError(errors.Cause(err), contracts.RepoErrorDeletingTask)
+		r.rollback(tx, err)
+
+		return
+	}
+
+	deletingTaskQuery := fmt.Sprintf("DELETE FROM task WHERE uuid IN (?%s)",
+		strings.Repeat(",?", len(tasks)-1))
+
+	result, errDeleting := tx.ExecContext(ctx, r.dialect.rebind(deletingTaskQuery), args...)
+	if errDeleting != nil {
+		error = r.dialect.convertError(errDeleting, contracts.RepoErrorDeletingTask)
+		r.rollback(tx, errDeleting)
+
+		return
+	}
+	deleted, _ = result.RowsAffected()
+
+	for _, task := range nextTasks {
+		if !existingTasks[task.Id] {
+			continue
+		}
+
+		if err := r.createTask(ctx, tx, task, false); err != nil {
+			error = r.dialect.convertError(errors.Cause(err), contracts.RepoErrorCreatingTask)
+			r.rollback(tx, err)
+
+			return 0, 0, error
+		}
+		created++
+	}
+
+	if err := tx.Commit(); err != nil {
+		error = r.dialect.convertError(err, contracts.RepoErrorDeletingTask)
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return 0, 0, error
+	}
+
+	r.deleteEmptyCollectionsSometimes(ctx)
+
+	return
+}
+
+func (r *sqlRepository) queryIds(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (ids []string, err error) {
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	defer func() {
+		if errClosing := rows.Close(); errClosing != nil && err == nil {
+			err = errors.Wrap(errClosing, "closing rows error")
+		}
+	}()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "scan error")
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "scan error")
+	}
+
+	return ids, nil
+}
+
+/*
+	Cleaning empty collections of tasks and released idempotency keys.
+	It is enough do sometimes.
+*/
+func (r *sqlRepository) deleteEmptyCollectionsSometimes(ctx context.Context) {
+	atomic.AddInt32(&r.cleanRequestCount, 1)
+	if r.options.CleaningFrequency > 0 &&
+		atomic.LoadInt32(&r.cleanRequestCount)%int32(r.options.CleaningFrequency) == 0 {
+
+		if err := r.deleteEmptyCollections(ctx); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+		}
+		if _, err := r.client.ExecContext(ctx, r.dialect.rebind(deleteReleasedKeysQuery), time.Now().Unix()); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+		}
+		atomic.StoreInt32(&r.cleanRequestCount, 0)
+	}
+}
+
+func (r *sqlRepository) deleteEmptyCollections(ctx context.Context) error {
+	deleteCollectionsQuery := `DELETE FROM collection
+		WHERE exec_time < ? AND NOT EXISTS(
+			SELECT t.uuid FROM task t WHERE t.collection_id = collection.id
+		)`
+
+	if _, err := r.client.ExecContext(
+		ctx,
+		r.dialect.rebind(deleteCollectionsQuery),
+		r.options.toStored(time.Now().Add(-5*time.Second)),
+	); err != nil {
+		return errors.Wrap(err, "clearing collections was fail")
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) getTasksByCollection(ctx context.Context, collectionId int64) (tasks []domain.Task, error error) {
+	queryFindBySecToExecTime := `SELECT t.uuid, c.exec_time, t.exec_time_ms, t.payload, t.headers, t.recurrence, t.attempts, t.priority, t.idempotency_key, c.queue
+		FROM task t
+		INNER JOIN collection c on t.collection_id = c.id
+		WHERE t.collection_id = ?`
+
+	rows, errFinding := r.client.QueryContext(ctx, r.dialect.rebind(queryFindBySecToExecTime), collectionId)
+	if errFinding != nil {
+		error = contracts.RepoErrorGettingTasks
+		r.eh.New(contracts.LevelError, errFinding.Error(), map[string]interface{}{"collection id": collectionId})
+
+		return
+	}
+
+	defer func() {
+		if err := rows.Close(); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+		}
+	}()
+
+	for rows.Next() {
+		var task domain.Task
+		var collectionExecTime int64
+		var taskExecTime sql.NullInt64
+		var headers, recurrence sql.NullString
+		if err := rows.Scan(
+			&task.Id,
+			&collectionExecTime,
+			&taskExecTime,
+			&task.Payload,
+			&headers,
+			&recurrence,
+			&task.Attempts,
+			&task.Priority,
+			&task.IdempotencyKey,
+			&task.Queue,
+		); err != nil {
+			error = contracts.RepoErrorGettingTasks
+			r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"collection id": collectionId})
+
+			return
+		}
+		r.options.restoreExecTime(&task, collectionExecTime, taskExecTime)
+
+		decodedHeaders, err := decodeHeaders(headers)
+		if err != nil {
+			error = contracts.RepoErrorGettingTasks
+			r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": task.Id})
+
+			return
+		}
+		task.Headers = decodedHeaders
+
+		decodedRecurrence, err := decodeRecurrence(recurrence)
+		if err != nil {
+			error = contracts.RepoErrorGettingTasks
+			r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": task.Id})
+
+			return
+		}
+		task.Recurrence = decodedRecurrence
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		error = contracts.RepoErrorGettingTasks
+		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"collection id": collectionId})
+
+		return
+	}
+
+	return
+}
+
+/*
+	Takes the collections in the transaction. Collections locked by other instances are skipped instead of waiting
+	if the database supports it.
+*/
+func (r *sqlRepository) FindBySecToExecTime(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (
+	collection contracts.CollectionsInterface, error error) {
+
+	toNextExecTime := r.options.toStored(time.Now().Add(preloadingTimeRange))
+
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		error = r.dialect.convertError(errTx, contracts.RepoErrorFindingTasks)
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return
+	}
+
+	queuesCondition, queuesArgs := queuesCondition(queues)
+	queryFindBySecToExecTime := `SELECT id FROM collection
+		WHERE exec_time <= ? AND taken_by_instance != ?
+			AND taken_by_instance NOT IN (SELECT id FROM instance WHERE heartbeat_at >= ?)` + queuesCondition + `
+		ORDER BY exec_time, id` + r.dialect.lockClause(true)
+
+	collectionIds, errFinding := r.queryCollectionIds(
+		ctx,
+		tx,
+		queryFindBySecToExecTime,
+		append([]interface{}{
+			toNextExecTime,
+			r.appInstanceId,
+			aliveSince(r.options.InstanceTimeout),
+		}, queuesArgs...)...,
+	)
+	if errFinding != nil {
+		error = r.dialect.convertError(errors.Cause(errFinding), contracts.RepoErrorFindingTasks)
+		r.rollback(tx, errFinding)
+
+		return
+	}
+
+	if len(collectionIds) == 0 {
+		if err := tx.Commit(); err != nil {
+			error = r.dialect.convertError(err, contracts.RepoErrorFindingTasks)
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+
+			return
+		}
+
+		return nil, contracts.RepoErrorNoTasksFound
+	}
+
+	args := []interface{}{r.appInstanceId}
+	for _, id := range collectionIds {
+		args = append(args, id)
+	}
+
+	queryTakeCollections := fmt.Sprintf("UPDATE collection SET taken_by_instance = ? WHERE id IN (?%s)",
+		strings.Repeat(",?", len(collectionIds)-1))
+
+	if _, err := tx.ExecContext(ctx, r.dialect.rebind(queryTakeCollections), args...); err != nil {
+		error = r.dialect.convertError(err, contracts.RepoErrorFindingTasks)
+		r.rollback(tx, err)
+
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		error = r.dialect.convertError(err, contracts.RepoErrorFindingTasks)
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return
+	}
+
+	collection = &Collections{
+		collections: collectionIds,
+		r:           r,
+	}
+
+	return
+}
+
+func (r *sqlRepository) queryCollectionIds(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (ids []int64, err error) {
+	rows, err := tx.QueryContext(ctx, r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+
+	defer func() {
+		if errClosing := rows.Close(); errClosing != nil && err == nil {
+			err = errors.Wrap(errClosing, "closing rows error")
+		}
+	}()
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "scan error")
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "scan error")
+	}
+
+	return ids, nil
+}
+
+func (r *sqlRepository) UpdateAttempts(ctx context.Context, task domain.Task) error {
+	if _, err := r.client.ExecContext(
+		ctx,
+		r.dialect.rebind("UPDATE task SET attempts = ? WHERE uuid = ?"),
+		task.Attempts,
+		task.Id,
+	); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": task.Id})
+
+		return r.dialect.convertError(err, contracts.RepoErrorUpdatingTask)
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) MoveToDeadLetter(ctx context.Context, task domain.Task, reason string) error {
+	args, errEncoding := deadLetterArgs(task, reason)
+	if errEncoding != nil {
+		r.eh.New(contracts.LevelError, errEncoding.Error(), map[string]interface{}{"task": task})
+
+		return contracts.RepoErrorMovingTask
+	}
+
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return r.dialect.convertError(errTx, contracts.RepoErrorMovingTask)
+	}
+
+	result, errDeleting := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM task WHERE uuid = ?"), task.Id)
+	if errDeleting != nil {
+		r.rollback(tx, errDeleting)
+
+		return r.dialect.convertError(errDeleting, contracts.RepoErrorMovingTask)
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		if err := tx.Rollback(); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+		}
+
+		return contracts.RepoErrorTaskNotFound
+	}
+
+	if err := r.options.retainIdempotencyKeys(ctx, tx, r.dialect.rebind, []interface{}{task.Id}); err != nil {
+		r.rollback(tx, err)
+
+		return r.dialect.convertError(errors.Cause(err), contracts.RepoErrorMovingTask)
+	}
+
+	if _, err := tx.ExecContext(ctx, r.dialect.rebind(deleteDeadLetterQuery), task.Id); err != nil {
+		r.rollback(tx, err)
+
+		return r.dialect.convertError(err, contracts.RepoErrorMovingTask)
+	}
+
+	if _, err := tx.ExecContext(ctx, r.dialect.rebind(insertDeadLetterQuery), args...); err != nil {
+		r.rollback(tx, err)
+
+		return r.dialect.convertError(err, contracts.RepoErrorMovingTask)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return r.dialect.convertError(err, contracts.RepoErrorMovingTask)
+	}
+
+	r.deleteEmptyCollectionsSometimes(ctx)
+
+	return nil
+}
+
+func (r *sqlRepository) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	tasks, err := r.options.queryTasks(ctx, r.client, r.dialect.rebind(getTaskQuery), taskId)
+	if err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": taskId})
+
+		return domain.Task{}, contracts.RepoErrorGettingTasks
+	}
+
+	if len(tasks) == 0 {
+		return domain.Task{}, contracts.RepoErrorTaskNotFound
+	}
+
+	return tasks[0], nil
+}
+
+func (r *sqlRepository) GetIdByIdempotencyKey(ctx context.Context, idempotencyKey string) (string, error) {
+	var taskId string
+	err := r.client.QueryRowContext(ctx, r.dialect.rebind(getIdByIdempotencyKeyQuery), idempotencyKey).Scan(&taskId)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", contracts.RepoErrorTaskNotFound
+	case err != nil:
+		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"idempotency key": idempotencyKey})
+
+		return "", contracts.RepoErrorGettingTasks
+	}
+
+	return taskId, nil
+}
+
+func (r *sqlRepository) List(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+	query, args := r.options.listTasksQuery(filter)
+	tasks, err := r.options.queryTasks(ctx, r.client, r.dialect.rebind(query), args...)
+	if err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"filter": filter})
+
+		return nil, contracts.RepoErrorGettingTasks
+	}
+
+	return tasks, nil
+}
+
+func (r *sqlRepository) Reschedule(ctx context.Context, task domain.Task, isTaken bool) (domain.Task, error) {
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return domain.Task{}, r.dialect.convertError(errTx, contracts.RepoErrorRescheduling)
+	}
+
+	tasks, errFinding := r.options.queryTasks(
+		ctx,
+		tx,
+		r.dialect.rebind(getTaskQuery+r.dialect.lockClause(false)),
+		task.Id,
+	)
+	if errFinding != nil {
+		r.rollback(tx, errFinding)
+
+		return domain.Task{}, r.dialect.convertError(errors.Cause(errFinding), contracts.RepoErrorRescheduling)
+	}
+
+	if len(tasks) == 0 {
+		if err := tx.Rollback(); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+		}
+
+		return domain.Task{}, contracts.RepoErrorTaskNotFound
+	}
+	rescheduled := r.options.rescheduled(tasks[0], task)
+
+	if _, err := tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM task WHERE uuid = ?"), task.Id); err != nil {
+		r.rollback(tx, err)
+
+		return domain.Task{}, r.dialect.convertError(err, contracts.RepoErrorRescheduling)
+	}
+
+	if err := r.createTask(ctx, tx, rescheduled, isTaken); err != nil {
+		r.rollback(tx, err)
+
+		return domain.Task{}, r.dialect.convertError(errors.Cause(err), contracts.RepoErrorRescheduling)
+	}
+
+	//	The task is created without attempts
+	if rescheduled.Attempts > 0 {
+		if _, err := tx.ExecContext(
+			ctx,
+			r.dialect.rebind("UPDATE task SET attempts = ? WHERE uuid = ?"),
+			rescheduled.Attempts,
+			task.Id,
+		); err != nil {
+			r.rollback(tx, err)
+
+			return domain.Task{}, r.dialect.convertError(err, contracts.RepoErrorRescheduling)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return domain.Task{}, r.dialect.convertError(err, contracts.RepoErrorRescheduling)
+	}
+
+	r.deleteEmptyCollectionsSometimes(ctx)
+
+	return rescheduled, nil
+}
+
+func (r *sqlRepository) FindDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error) {
+	deadLetters, err := queryDeadLetters(ctx, r.client, r.dialect.rebind(findDeadLettersQuery), limit, offset)
+	if err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return nil, contracts.RepoErrorGettingDead
+	}
+
+	return deadLetters, nil
+}
+
+func (r *sqlRepository) GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error) {
+	deadLetters, err := queryDeadLetters(ctx, r.client, r.dialect.rebind(getDeadLetterQuery), taskId)
+	if err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": taskId})
+
+		return domain.DeadLetter{}, contracts.RepoErrorGettingDead
+	}
+
+	if len(deadLetters) == 0 {
+		return domain.DeadLetter{}, contracts.RepoErrorTaskNotFound
+	}
+
+	return deadLetters[0], nil
+}
+
+func (r *sqlRepository) Requeue(ctx context.Context, task domain.Task, isTaken bool) error {
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return r.dialect.convertError(errTx, contracts.RepoErrorRequeueTask)
+	}
+
+	result, errDeleting := tx.ExecContext(ctx, r.dialect.rebind(deleteDeadLetterQuery), task.Id)
+	if errDeleting != nil {
+		r.rollback(tx, errDeleting)
+
+		return r.dialect.convertError(errDeleting, contracts.RepoErrorRequeueTask)
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		if err := tx.Rollback(); err != nil {
+			r.eh.New(contracts.LevelError, err.Error(), nil)
+		}
+
+		return contracts.RepoErrorTaskNotFound
+	}
+
+	if err := r.createTask(ctx, tx, task, isTaken); err != nil {
+		r.rollback(tx, err)
+
+		return r.dialect.convertError(errors.Cause(err), contracts.RepoErrorRequeueTask)
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return r.dialect.convertError(err, contracts.RepoErrorRequeueTask)
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) PurgeDeadLetters(ctx context.Context, deadLetteredBefore int64) (int64, error) {
+	result, err := r.client.ExecContext(ctx, r.dialect.rebind(purgeDeadLettersQuery), deadLetteredBefore)
+	if err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return 0, r.dialect.convertError(err, contracts.RepoErrorPurgingDead)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return 0, contracts.RepoErrorPurgingDead
+	}
+
+	return deleted, nil
+}
+
+func (r *sqlRepository) Release(ctx context.Context, tasks []domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	args := []interface{}{r.appInstanceId}
+	for _, task := range tasks {
+		args = append(args, task.Id)
+	}
+
+	releasingQuery := fmt.Sprintf(`UPDATE collection SET taken_by_instance = ''
+		WHERE taken_by_instance = ? AND id IN (SELECT collection_id FROM task WHERE uuid IN (?%s))`,
+		strings.Repeat(",?", len(tasks)-1))
+
+	if _, err := r.client.ExecContext(ctx, r.dialect.rebind(releasingQuery), args...); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return r.dialect.convertError(err, contracts.RepoErrorReleasingTasks)
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) Heartbeat(ctx context.Context) error {
+	if _, err := r.client.ExecContext(
+		ctx,
+		r.dialect.rebind("INSERT INTO instance (id, heartbeat_at) VALUES (?, ?)"+r.dialect.upsertClause("id", "heartbeat_at")),
+		r.appInstanceId,
+		time.Now().Unix(),
+	); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return r.dialect.convertError(err, contracts.RepoErrorHeartbeat)
+	}
+
+	if _, err := r.client.ExecContext(
+		ctx,
+		r.dialect.rebind(deleteDeadInstancesQuery),
+		aliveSince(r.options.InstanceTimeout),
+	); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return r.dialect.convertError(err, contracts.RepoErrorHeartbeat)
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) Unregister(ctx context.Context) error {
+	tx, errTx := r.client.BeginTx(ctx, nil)
+	if errTx != nil {
+		r.eh.New(contracts.LevelError, errTx.Error(), nil)
+
+		return r.dialect.convertError(errTx, contracts.RepoErrorUnregistering)
+	}
+
+	for _, query := range []string{releaseInstanceQuery, deleteInstanceQuery} {
+		if _, err := tx.ExecContext(ctx, r.dialect.rebind(query), r.appInstanceId); err != nil {
+			r.rollback(tx, err)
+
+			return r.dialect.convertError(err, contracts.RepoErrorUnregistering)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return r.dialect.convertError(err, contracts.RepoErrorUnregistering)
+	}
+
+	return nil
+}
+
+func (r *sqlRepository) Up() error {
+	ctx := context.Background()
+	for _, query := range r.dialect.schema(r.options) {
+		if _, err := r.client.ExecContext(ctx, query); err != nil && !r.dialect.isDuplicateColumn(err) {
+			r.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"query": query})
+
+			return contracts.RepoErrorSchemaSetup
+		}
+	}
+
+	return nil
+}
+
+/*
+	Inserts the row by the query returning the id, it is supported by PostgreSQL and SQLite
+*/
+func insertReturningId(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+
+	return id, err
+}
+
+/*
+	The upsert clause of PostgreSQL and SQLite
+*/
+func onConflictClause(key string, columns ...string) string {
+	var updates []string
+	for _, column := range columns {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(updates, ", "))
+}
+
+func (r *sqlRepository) rollback(tx *sql.Tx, err error) {
+	childError := err
+	if errRollback := tx.Rollback(); errRollback != nil {
+		childError = errors.Wrap(childError, errRollback.Error())
+	}
+
+	r.eh.New(contracts.LevelError, childError.Error(), nil)
+}
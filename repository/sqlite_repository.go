@@ -0,0 +1,136 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/pvelx/triggerhook/contracts"
+)
+
+/*
+	The repository for single-node deployments. SQLite does not support locking of rows,
+	the database is locked entirely by the writing transaction instead.
+*/
+func NewSQLite(
+	client *sql.DB,
+	appInstanceId string,
+	eh contracts.EventHandlerInterface,
+	options *Options,
+) contracts.RepositoryInterface {
+
+	return &sqlRepository{
+		client:        client,
+		appInstanceId: appInstanceId,
+		eh:            eh,
+		options:       mergeDefaultOptions(options),
+		dialect:       sqliteDialect{},
+	}
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) schema(*Options) []string {
+	return []string{
+		`CREATE TABLE IF NOT EXISTS collection
+		(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			exec_time INTEGER NOT NULL,
+			taken_by_instance VARCHAR(36) DEFAULT '' NOT NULL,
+			queue VARCHAR(255) DEFAULT '' NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS collection_exec_time_idx ON collection (exec_time)`,
+		`CREATE INDEX IF NOT EXISTS collection_taken_by_instance_idx ON collection (taken_by_instance, exec_time)`,
+		`CREATE TABLE IF NOT EXISTS task
+		(
+			uuid VARCHAR(36) NOT NULL PRIMARY KEY,
+			collection_id INTEGER NOT NULL REFERENCES collection (id),
+			exec_time_ms INTEGER NULL,
+			payload BLOB NULL,
+			headers TEXT NULL,
+			recurrence TEXT NULL,
+			attempts INTEGER DEFAULT 0 NOT NULL,
+			priority INTEGER DEFAULT 0 NOT NULL,
+			idempotency_key VARCHAR(255) DEFAULT '' NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS task_collection_id_idx ON task (collection_id)`,
+		`ALTER TABLE task ADD COLUMN attempts INTEGER DEFAULT 0 NOT NULL`,
+		`ALTER TABLE task ADD COLUMN exec_time_ms INTEGER NULL`,
+		`CREATE INDEX IF NOT EXISTS task_exec_time_ms_idx ON task (exec_time_ms)`,
+		`CREATE TABLE IF NOT EXISTS dead_letter
+		(
+			uuid VARCHAR(36) NOT NULL PRIMARY KEY,
+			exec_time INTEGER NOT NULL,
+			payload BLOB NULL,
+			headers TEXT NULL,
+			recurrence TEXT NULL,
+			attempts INTEGER DEFAULT 0 NOT NULL,
+			priority INTEGER DEFAULT 0 NOT NULL,
+			idempotency_key VARCHAR(255) DEFAULT '' NOT NULL,
+			queue VARCHAR(255) DEFAULT '' NOT NULL,
+			reason TEXT NOT NULL,
+			dead_lettered_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS dead_letter_dead_lettered_at_idx ON dead_letter (dead_lettered_at)`,
+		`ALTER TABLE collection ADD COLUMN queue VARCHAR(255) DEFAULT '' NOT NULL`,
+		`ALTER TABLE dead_letter ADD COLUMN queue VARCHAR(255) DEFAULT '' NOT NULL`,
+		`ALTER TABLE task ADD COLUMN priority INTEGER DEFAULT 0 NOT NULL`,
+		`ALTER TABLE dead_letter ADD COLUMN priority INTEGER DEFAULT 0 NOT NULL`,
+		`ALTER TABLE task ADD COLUMN idempotency_key VARCHAR(255) DEFAULT '' NOT NULL`,
+		`ALTER TABLE dead_letter ADD COLUMN idempotency_key VARCHAR(255) DEFAULT '' NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS idempotency_key
+		(
+			idempotency_key VARCHAR(255) NOT NULL PRIMARY KEY,
+			uuid VARCHAR(36) NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idempotency_key_uuid_idx ON idempotency_key (uuid)`,
+		`CREATE INDEX IF NOT EXISTS idempotency_key_expires_at_idx ON idempotency_key (expires_at)`,
+		`CREATE TABLE IF NOT EXISTS instance
+		(
+			id VARCHAR(36) NOT NULL PRIMARY KEY,
+			heartbeat_at INTEGER NOT NULL
+		)`,
+	}
+}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) lockClause(skipLocked bool) string {
+	return ""
+}
+
+func (sqliteDialect) insertId(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	return insertReturningId(ctx, tx, query, args...)
+}
+
+func (sqliteDialect) upsertClause(key string, columns ...string) string {
+	return onConflictClause(key, columns...)
+}
+
+func (sqliteDialect) isDuplicateColumn(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+
+	return ok && sqliteErr.Code == sqlite3.ErrError && strings.HasPrefix(sqliteErr.Error(), "duplicate column name")
+}
+
+func (sqliteDialect) convertError(err error, defaultError error) error {
+	if err, ok := err.(sqlite3.Error); ok {
+		switch {
+		case err.ExtendedCode == sqlite3.ErrConstraintPrimaryKey || err.ExtendedCode == sqlite3.ErrConstraintUnique:
+			return contracts.RepoErrorTaskExist
+		case err.Code == sqlite3.ErrLocked:
+			return contracts.RepoErrorDeadlock
+		case err.Code == sqlite3.ErrBusy:
+			return contracts.RepoErrorLockWaitTimeout
+		}
+	}
+
+	return defaultError
+}
@@ -0,0 +1,33 @@
+//go:build !nosqlite
+// +build !nosqlite
+
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	newBackends = append(newBackends, func() *backend {
+		sqliteDir, err := ioutil.TempDir("", "triggerhook")
+		if err != nil {
+			panic(err)
+		}
+
+		return &backend{
+			name: "sqlite",
+			db: open("sqlite3", fmt.Sprintf(
+				"file:%s?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=1&_txlock=immediate",
+				filepath.Join(sqliteDir, "task.db"),
+			)),
+			new:    NewSQLite,
+			rebind: sqliteDialect{}.rebind,
+			remove: func() {
+				os.RemoveAll(sqliteDir)
+			},
+		}
+	})
+}
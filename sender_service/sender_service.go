@@ -2,12 +2,14 @@ package sender_service
 
 import (
 	"context"
+	"math"
 	"sync"
 	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/pvelx/triggerhook/contracts"
 	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
 )
 
 type Options struct {
@@ -17,13 +19,40 @@ type Options struct {
 	BatchesCap               int //Deprecated
 	ConfirmationWorkersCount int
 	CtxTimeout               time.Duration
+
+	/*
+		Time for the consumer to confirm or roll back the task.
+		If the task is not confirmed or rolled back in time, it is sent again.
+		0 - the task waits for the confirmation forever
+	*/
+	AckDeadline time.Duration
+
+	/*
+		Count of the attempts of the execution after which the task is moved to the dead letter store.
+		0 - the task is sent again without limit
+	*/
+	MaxAttempts int
+
+	/*
+		Backoff policy of RollbackWithBackoff: the delay is multiplied by BackoffMultiplier
+		after each attempt starting from BackoffInitialDelay and up to BackoffMaxDelay
+	*/
+	BackoffInitialDelay time.Duration
+	BackoffMaxDelay     time.Duration
+	BackoffMultiplier   float64
 }
 
+const ReasonMaxAttemptsExceeded = "max attempts exceeded"
+
 var taskToSendPool sync.Pool
 
+/*
+	The tasks of each queue are received from the channel returned by tasksReadyToSend for the queue
+*/
 func New(
 	taskManager contracts.TaskManagerInterface,
-	tasksReadyToSend <-chan domain.Task,
+	tasksReadyToSend func(queue string) <-chan domain.Task,
+	tasksToDelay chan<- domain.Task,
 	eh contracts.EventHandlerInterface,
 	monitoring contracts.MonitoringInterface,
 	options *Options,
@@ -38,6 +67,9 @@ func New(
 		BatchTimeout:             50 * time.Millisecond,
 		ConfirmationWorkersCount: 5,
 		CtxTimeout:               5 * time.Second,
+		BackoffInitialDelay:      time.Second,
+		BackoffMaxDelay:          5 * time.Minute,
+		BackoffMultiplier:        2,
 	}); err != nil {
 		panic(err)
 	}
@@ -52,38 +84,58 @@ func New(
 	if err := monitoring.Init(contracts.WaitingForConfirmation, contracts.IntegralMetricType); err != nil {
 		panic(err)
 	}
+	if err := monitoring.Init(contracts.LeaseExpirationRate, contracts.VelocityMetricType); err != nil {
+		panic(err)
+	}
+	if err := monitoring.Init(contracts.Lateness, contracts.HistogramMetricType); err != nil {
+		panic(err)
+	}
+	if err := monitoring.Init(contracts.ProcessingTime, contracts.HistogramMetricType); err != nil {
+		panic(err)
+	}
 
 	tasksToConfirm := make(chan domain.Task, options.BatchMaxItems)
-	buffer := NewBuffer()
-
-	taskToSendPool = sync.Pool{
-		New: func() interface{} {
-			return &taskToSend{
-				monitoring: monitoring,
-				eh:         eh,
-				rollback:   buffer.In,
-				confirm:    tasksToConfirm,
-			}
-		},
-	}
 
-	return &senderService{
+	service := &senderService{
 		taskManager:              taskManager,
 		tasksToConfirm:           tasksToConfirm,
 		tasksReadyToSend:         tasksReadyToSend,
+		tasksToDelay:             tasksToDelay,
 		eh:                       eh,
 		monitoring:               monitoring,
 		confirmationWorkersCount: options.ConfirmationWorkersCount,
 		batchTimeout:             options.BatchTimeout,
 		batchMaxItems:            options.BatchMaxItems,
-		taskBuffer:               buffer,
 		ctxTimeout:               options.CtxTimeout,
+		ackDeadline:              options.AckDeadline,
+		maxAttempts:              options.MaxAttempts,
+		backoffInitialDelay:      options.BackoffInitialDelay,
+		backoffMaxDelay:          options.BackoffMaxDelay,
+		backoffMultiplier:        options.BackoffMultiplier,
+		queues:                   make(map[string]*queue),
+		inFlight:                 make(map[*taskToSend]struct{}),
+		stopping:                 make(chan struct{}),
+	}
+
+	taskToSendPool = sync.Pool{
+		New: func() interface{} {
+			return &taskToSend{
+				monitoring: monitoring,
+				eh:         eh,
+				confirm:    tasksToConfirm,
+				sender:     service,
+			}
+		},
 	}
+
+	return service
 }
 
 type senderService struct {
 	contracts.SenderServiceInterface
-	tasksReadyToSend         <-chan domain.Task
+	sync.Mutex
+	tasksReadyToSend         func(queue string) <-chan domain.Task
+	tasksToDelay             chan<- domain.Task
 	tasksToConfirm           chan domain.Task
 	taskManager              contracts.TaskManagerInterface
 	eh                       contracts.EventHandlerInterface
@@ -91,19 +143,83 @@ type senderService struct {
 	batchTimeout             time.Duration
 	confirmationWorkersCount int
 	batchMaxItems            int
-	taskBuffer               *buffer
 	ctxTimeout               time.Duration
+	ackDeadline              time.Duration
+	maxAttempts              int
+	backoffInitialDelay      time.Duration
+	backoffMaxDelay          time.Duration
+	backoffMultiplier        float64
+	queues                   map[string]*queue
+
+	/*
+		Consumed tasks which are not confirmed or rolled back yet
+	*/
+	inFlight map[*taskToSend]struct{}
+
+	/*
+		Confirmations and rollbacks which are being processed
+	*/
+	processing sync.WaitGroup
+
+	/*
+		stopping is closed when the stopping begins, after that the tasks are not sent.
+		When stopped is set the confirmations and rollbacks are ignored
+	*/
+	stopping         chan struct{}
+	stopped          bool
+	confirmationDone sync.WaitGroup
+}
+
+/*
+	The ready tasks of the queue and the tasks of the queue which are sent again
+*/
+type queue struct {
+	tasksReadyToSend <-chan domain.Task
+	taskBuffer       *buffer
+	sendingRate      contracts.Topic
+}
+
+/*
+	The queue is created when it is consumed or its task is sent again for the first time
+*/
+func (s *senderService) queue(name string) *queue {
+	s.Lock()
+	defer s.Unlock()
+
+	if q, ok := s.queues[name]; ok {
+		return q
+	}
+
+	q := &queue{
+		tasksReadyToSend: s.tasksReadyToSend(name),
+		taskBuffer:       NewBuffer(),
+	}
+	s.queues[name] = q
+
+	if name != "" {
+		q.sendingRate = contracts.QueueTopic(contracts.SendingRate, name)
+		if err := s.monitoring.Init(q.sendingRate, contracts.VelocityMetricType); err != nil {
+			s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"queue": name})
+		}
+	}
+
+	return q
 }
 
 func (s *senderService) Run() {
 	batchTasks := s.generateBatch(s.tasksToConfirm)
 
+	s.Lock()
+	s.confirmationDone.Add(s.confirmationWorkersCount)
+	s.Unlock()
+
 	for w := 0; w < s.confirmationWorkersCount; w++ {
 		go s.confirmation(batchTasks)
 	}
 }
 
 func (s *senderService) confirmation(batchTasks chan []domain.Task) {
+	defer s.confirmationDone.Done()
 	for batch := range batchTasks {
 		ctx, stop := context.WithTimeout(context.Background(), s.ctxTimeout)
 		if err := s.taskManager.ConfirmExecution(ctx, batch); err != nil {
@@ -132,13 +248,16 @@ func (s *senderService) generateBatch(tasks <-chan domain.Task) chan []domain.Ta
 	go func() {
 		defer close(updateQueue)
 		for {
+			isClosed := false
 			batch := make([]domain.Task, 0, s.batchMaxItems)
 			expire := time.NewTimer(s.batchTimeout)
 			for {
 				select {
 				case value, ok := <-tasks:
 					if !ok {
-						return
+						expire.Stop()
+						isClosed = true
+						goto done
 					}
 					batch = append(batch, value)
 					if len(batch) == s.batchMaxItems {
@@ -163,6 +282,11 @@ func (s *senderService) generateBatch(tasks <-chan domain.Task) chan []domain.Ta
 					s.eh.New(contracts.LevelError, err.Error(), nil)
 				}
 			}
+
+			//	The last batch is flushed when the sender is stopped
+			if isClosed {
+				return
+			}
 		}
 	}()
 
@@ -186,21 +310,144 @@ func (s *senderService) generateBatch(tasks <-chan domain.Task) chan []domain.Ta
 }
 
 type taskToSend struct {
-	monitoring  contracts.MonitoringInterface
-	eh          contracts.EventHandlerInterface
-	isProcessed bool
-	confirm     chan domain.Task
-	rollback    chan domain.Task
-	task        domain.Task
+	sync.Mutex
+	monitoring   contracts.MonitoringInterface
+	eh           contracts.EventHandlerInterface
+	isProcessed  bool
+	confirm      chan domain.Task
+	redeliver    chan delivery
+	sendingRate  contracts.Topic
+	sender       *senderService
+	task         domain.Task
+	redeliveries int
+
+	/*
+		Number of the lease. The expiration of the previous lease must not affect the current one
+	*/
+	lease      int
+	leaseTimer *time.Timer
+	consumedAt time.Time
 }
 
 func (s *senderService) Consume() contracts.TaskToSendInterface {
+	return s.ConsumeQueue("")
+}
+
+func (s *senderService) ConsumeQueue(queueName string) contracts.TaskToSendInterface {
+	taskToSend, err := s.ConsumeCtx(context.Background(), queueName)
+	if err != nil {
+		return nil
+	}
+
+	return taskToSend
+}
+
+func (s *senderService) ConsumeCtx(ctx context.Context, queueName string) (contracts.TaskToSendInterface, error) {
+	select {
+	case <-s.stopping:
+		return nil, contracts.ErrStopped
+	default:
+	}
+
+	q := s.queue(queueName)
+
+	var d delivery
+	ok := true
+	select {
+	case d.task = <-q.tasksReadyToSend:
+	case d, ok = <-q.taskBuffer.Out:
+	case <-s.stopping:
+		ok = false
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if !ok {
+		return nil, contracts.ErrStopped
+	}
+
+	taskToSend := s.deliver(q, d)
+	if taskToSend == nil {
+		return nil, contracts.ErrStopped
+	}
+
+	return taskToSend, nil
+}
+
+/*
+	Waits for the first task only, the other tasks are taken if they are ready at once
+*/
+func (s *senderService) ConsumeBatch(ctx context.Context, queueName string, max int) ([]contracts.TaskToSendInterface, error) {
+	first, err := s.ConsumeCtx(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	q := s.queue(queueName)
+	tasks := []contracts.TaskToSendInterface{first}
+	for len(tasks) < max {
+		var d delivery
+		ok := true
+		select {
+		case d.task = <-q.tasksReadyToSend:
+		case d, ok = <-q.taskBuffer.Out:
+		default:
+			ok = false
+		}
+		if !ok {
+			break
+		}
+
+		taskToSend := s.deliver(q, d)
+		if taskToSend == nil {
+			break
+		}
+		tasks = append(tasks, taskToSend)
+	}
+
+	return tasks, nil
+}
+
+/*
+	Sends the received task to the consumer. Returns nil if the task is received after stopping
+*/
+func (s *senderService) deliver(q *queue, d delivery) *taskToSend {
 	taskToSend := taskToSendPool.Get().(*taskToSend)
+	taskToSend.Lock()
+	defer taskToSend.Unlock()
+
 	taskToSend.isProcessed = false
+	taskToSend.redeliver = q.taskBuffer.In
+	taskToSend.sendingRate = q.sendingRate
+	taskToSend.task = d.task
+	taskToSend.redeliveries = d.redeliveries
+	taskToSend.lease++
+	taskToSend.leaseTimer = nil
+	taskToSend.consumedAt = time.Now()
+
+	s.Lock()
+	if s.stopped {
+		s.Unlock()
+		taskToSend.isProcessed = true
+		taskToSendPool.Put(taskToSend)
+
+		//	The task was received after stopping, so nobody sends it
+		s.release(context.Background(), []domain.Task{d.task})
+
+		return nil
+	}
+	s.inFlight[taskToSend] = struct{}{}
+	s.Unlock()
 
-	select {
-	case taskToSend.task = <-s.tasksReadyToSend:
-	case taskToSend.task = <-s.taskBuffer.Out:
+	lateness := taskToSend.consumedAt.Sub(util.FromMs(d.task.ExecTimeInMs())).Milliseconds()
+	if err := s.monitoring.Publish(contracts.Lateness, lateness); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	if s.ackDeadline > 0 {
+		lease := taskToSend.lease
+		taskToSend.leaseTimer = time.AfterFunc(s.ackDeadline, func() {
+			taskToSend.expireLease(lease)
+		})
 	}
 
 	return taskToSend
@@ -210,22 +457,325 @@ func (tts *taskToSend) Task() domain.Task {
 	return tts.task
 }
 
+func (tts *taskToSend) Redeliveries() int {
+	tts.Lock()
+	defer tts.Unlock()
+
+	return tts.redeliveries
+}
+
 func (tts *taskToSend) Confirm() {
-	if !tts.isProcessed {
+	tts.Lock()
+	defer tts.Unlock()
+
+	if !tts.isProcessed && tts.sender.process(tts) {
+		defer tts.sender.processing.Done()
+
 		if err := tts.monitoring.Publish(contracts.SendingRate, 1); err != nil {
 			tts.eh.New(contracts.LevelError, err.Error(), nil)
 		}
+		if tts.sendingRate != "" {
+			if err := tts.monitoring.Publish(tts.sendingRate, 1); err != nil {
+				tts.eh.New(contracts.LevelError, err.Error(), nil)
+			}
+		}
+		if err := tts.monitoring.Publish(contracts.ProcessingTime, time.Since(tts.consumedAt).Milliseconds()); err != nil {
+			tts.eh.New(contracts.LevelError, err.Error(), nil)
+		}
 
 		tts.isProcessed = true
+		tts.stopLease()
 		tts.confirm <- tts.task
 		taskToSendPool.Put(tts)
 	}
 }
 
 func (tts *taskToSend) Rollback() {
-	if !tts.isProcessed {
+	tts.rollback(nil)
+}
+
+func (tts *taskToSend) RollbackWithDelay(delay time.Duration) {
+	tts.rollback(func(attempts int) time.Duration {
+		return delay
+	})
+}
+
+func (tts *taskToSend) RollbackWithBackoff() {
+	tts.rollback(tts.sender.backoff)
+}
+
+func (tts *taskToSend) Postpone(delay time.Duration) {
+	tts.Lock()
+	defer tts.Unlock()
+
+	if !tts.isProcessed && tts.sender.process(tts) {
+		defer tts.sender.processing.Done()
+
+		tts.isProcessed = true
+		tts.stopLease()
+
+		tts.sender.sendAgain(delivery{task: tts.task, redeliveries: tts.redeliveries}, delay)
+		taskToSendPool.Put(tts)
+	}
+}
+
+func (tts *taskToSend) Reject(reason string) {
+	tts.Lock()
+	defer tts.Unlock()
+
+	if !tts.isProcessed && tts.sender.process(tts) {
+		defer tts.sender.processing.Done()
+
+		tts.isProcessed = true
+		tts.stopLease()
+		tts.task.Attempts++
+
+		tts.sender.reject(delivery{task: tts.task, redeliveries: tts.redeliveries}, reason)
+		taskToSendPool.Put(tts)
+	}
+}
+
+func (tts *taskToSend) rollback(delay func(attempts int) time.Duration) {
+	tts.Lock()
+	defer tts.Unlock()
+
+	if !tts.isProcessed && tts.sender.process(tts) {
+		defer tts.sender.processing.Done()
+
 		tts.isProcessed = true
-		tts.rollback <- tts.task
+		tts.stopLease()
+		tts.task.Attempts++
+
+		var d time.Duration
+		if delay != nil {
+			d = delay(tts.task.Attempts)
+		}
+
+		tts.sender.rollback(delivery{task: tts.task, redeliveries: tts.redeliveries}, d)
 		taskToSendPool.Put(tts)
 	}
 }
+
+func (tts *taskToSend) stopLease() {
+	if tts.leaseTimer != nil {
+		tts.leaseTimer.Stop()
+	}
+}
+
+/*
+	The task was not confirmed or rolled back in time, so it is sent again.
+	It is not returned to the pool because the consumer may still use it.
+*/
+func (tts *taskToSend) expireLease(lease int) {
+	tts.Lock()
+	defer tts.Unlock()
+
+	if tts.isProcessed || tts.lease != lease || !tts.sender.process(tts) {
+		return
+	}
+	defer tts.sender.processing.Done()
+	tts.isProcessed = true
+
+	if err := tts.monitoring.Publish(contracts.LeaseExpirationRate, 1); err != nil {
+		tts.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	tts.eh.New(contracts.LevelDebug, "lease of the task expired", map[string]interface{}{
+		"task id":      tts.task.Id,
+		"redeliveries": tts.redeliveries,
+	})
+
+	tts.redeliver <- delivery{task: tts.task, redeliveries: tts.redeliveries + 1}
+}
+
+/*
+	Takes the task out of the tasks in flight before the confirmation or the rollback.
+	Returns false after stopping, then the task is released by Stop
+*/
+func (s *senderService) process(tts *taskToSend) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stopped {
+		return false
+	}
+
+	delete(s.inFlight, tts)
+	s.processing.Add(1)
+
+	return true
+}
+
+/*
+	The failed attempt is saved. When the attempts are over the task is moved to the dead letter store,
+	otherwise it is sent again immediately or after the delay
+*/
+func (s *senderService) rollback(d delivery, delay time.Duration) {
+	ctx, stop := context.WithTimeout(context.Background(), s.ctxTimeout)
+	defer stop()
+
+	if s.maxAttempts > 0 && d.task.Attempts >= s.maxAttempts {
+		if s.moveToDeadLetter(ctx, d.task, ReasonMaxAttemptsExceeded) {
+			return
+		}
+	} else if err := s.taskManager.RollbackExecution(ctx, d.task); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": d.task.Id})
+	}
+
+	s.sendAgain(d, delay)
+}
+
+/*
+	The task is sent again immediately or after the delay
+*/
+func (s *senderService) sendAgain(d delivery, delay time.Duration) {
+	if delay <= 0 {
+		s.queue(d.task.Queue).taskBuffer.In <- d
+
+		return
+	}
+
+	d.task.Delay(util.ToMs(time.Now().Add(delay)))
+	s.tasksToDelay <- d.task
+}
+
+/*
+	The task is moved to the dead letter store. If it fails the task is sent again immediately
+*/
+func (s *senderService) reject(d delivery, reason string) {
+	ctx, stop := context.WithTimeout(context.Background(), s.ctxTimeout)
+	defer stop()
+
+	if !s.moveToDeadLetter(ctx, d.task, reason) {
+		s.queue(d.task.Queue).taskBuffer.In <- d
+	}
+}
+
+/*
+	Returns false if the task is not moved, then it must be sent again, so it is not lost
+*/
+func (s *senderService) moveToDeadLetter(ctx context.Context, task domain.Task, reason string) bool {
+	err := s.taskManager.MoveToDeadLetter(ctx, task, reason)
+	if err == nil || err == contracts.TmErrorTaskNotFound {
+		return true
+	}
+
+	s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": task.Id})
+
+	return false
+}
+
+func (s *senderService) backoff(attempts int) time.Duration {
+	delay := float64(s.backoffInitialDelay) * math.Pow(s.backoffMultiplier, float64(attempts-1))
+	if delay > float64(s.backoffMaxDelay) {
+		return s.backoffMaxDelay
+	}
+
+	return time.Duration(delay)
+}
+
+func (s *senderService) release(ctx context.Context, tasks []domain.Task) {
+	if err := s.taskManager.Release(ctx, tasks); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"count of task": len(tasks),
+		})
+	}
+}
+
+/*
+	Waits for the tasks in flight to be confirmed or rolled back, flushes the confirmations
+	and releases the tasks which are not confirmed, so they can be taken by other instances.
+	When the context is done the tasks in flight are not waited for any more, they are released
+	with the rest of the tasks and the error of the context is returned.
+	Consume returns nil after stopping. Must be called after Run
+*/
+func (s *senderService) Stop(ctx context.Context) error {
+	s.Lock()
+	if s.stopped {
+		s.Unlock()
+
+		return nil
+	}
+	select {
+	case <-s.stopping:
+	default:
+		close(s.stopping)
+	}
+	s.Unlock()
+
+	s.waitForInFlight(ctx)
+
+	s.Lock()
+	s.stopped = true
+	abandoned := make([]*taskToSend, 0, len(s.inFlight))
+	for tts := range s.inFlight {
+		abandoned = append(abandoned, tts)
+	}
+	s.Unlock()
+
+	//	The confirmations and the rollbacks which are being processed and the flushing of the confirmations
+	//	are limited by CtxTimeout, so they are waited for even when the context is done
+	s.processing.Wait()
+	close(s.tasksToConfirm)
+	s.confirmationDone.Wait()
+
+	s.Lock()
+	buffers := make([]*buffer, 0, len(s.queues))
+	for _, q := range s.queues {
+		buffers = append(buffers, q.taskBuffer)
+	}
+	s.Unlock()
+
+	var tasks []domain.Task
+	for _, b := range buffers {
+		close(b.In)
+		for d := range b.Out {
+			tasks = append(tasks, d.task)
+		}
+	}
+
+	for _, tts := range abandoned {
+		tts.Lock()
+		tts.stopLease()
+		tasks = append(tasks, tts.task)
+		tts.Unlock()
+	}
+
+	//	The tasks must be released even when the context is done, otherwise they stay taken by the instance
+	errCtx := ctx.Err()
+	if errCtx != nil {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(context.Background(), s.ctxTimeout)
+		defer stop()
+	}
+
+	if err := s.taskManager.Release(ctx, tasks); err != nil {
+		return err
+	}
+
+	return errCtx
+}
+
+/*
+	Waits until all consumed tasks are confirmed or rolled back or until the context is done
+*/
+func (s *senderService) waitForInFlight(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		s.Lock()
+		count := len(s.inFlight)
+		s.Unlock()
+
+		if count == 0 {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
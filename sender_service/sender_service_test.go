@@ -1,11 +1,14 @@
 package sender_service
 
 import (
+	"container/list"
 	"context"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pvelx/triggerhook/contracts"
 	"github.com/pvelx/triggerhook/domain"
 	"github.com/pvelx/triggerhook/error_service"
 	"github.com/pvelx/triggerhook/monitoring_service"
@@ -19,6 +22,18 @@ func createTasks(taskReadyToSend chan domain.Task, count int) {
 	}
 }
 
+/*
+	The tasks of the default queue are received from the channel, the other queues have no tasks
+*/
+func queues(taskReadyToSend chan domain.Task) func(queue string) <-chan domain.Task {
+	return func(queue string) <-chan domain.Task {
+		if queue == "" {
+			return taskReadyToSend
+		}
+		return make(chan domain.Task)
+	}
+}
+
 func sum(s []int) (r int) {
 	for _, v := range s {
 		r += v
@@ -76,7 +91,7 @@ func TestConfirmation(t *testing.T) {
 		return nil
 	}}
 
-	senderService := New(taskManagerMock, taskReadyToSend, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, &Options{
+	senderService := New(taskManagerMock, queues(taskReadyToSend), make(chan domain.Task), &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, &Options{
 		BatchMaxItems: 1000,
 		BatchTimeout:  50 * time.Millisecond,
 	})
@@ -124,7 +139,8 @@ func TestConsuming(t *testing.T) {
 
 	senderService := New(
 		taskManagerMock,
-		taskReadyToSend,
+		queues(taskReadyToSend),
+		make(chan domain.Task),
 		&error_service.ErrorHandlerMock{},
 		&monitoring_service.MonitoringMock{},
 		nil,
@@ -158,3 +174,429 @@ func TestConsuming(t *testing.T) {
 
 	assert.Equal(t, expTries, actualTries, "tries count is not correct")
 }
+
+func TestAckDeadline(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 1)
+	confirmedTasks := make(chan []domain.Task, 1)
+	var expiredLeases int32
+
+	taskManagerMock := &task_manager.TaskManagerMock{ConfirmExecutionMock: func(ctx context.Context, tasks []domain.Task) error {
+		confirmedTasks <- tasks
+		return nil
+	}}
+	monitoringMock := &monitoring_service.MonitoringMock{PublishMock: func(topic contracts.Topic, measurement int64) error {
+		if topic == contracts.LeaseExpirationRate {
+			atomic.AddInt32(&expiredLeases, int32(measurement))
+		}
+		return nil
+	}}
+
+	senderService := New(
+		taskManagerMock,
+		queues(taskReadyToSend),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		monitoringMock,
+		&Options{AckDeadline: 50 * time.Millisecond},
+	)
+	go senderService.Run()
+
+	task := domain.Task{Id: "task", ExecTime: time.Now().Unix()}
+	taskReadyToSend <- task
+
+	forgotten := senderService.Consume()
+	assert.Equal(t, task, forgotten.Task())
+	assert.Equal(t, 0, forgotten.Redeliveries())
+
+	start := time.Now()
+	redelivered := senderService.Consume()
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(40*time.Millisecond), "the task is redelivered too early")
+	assert.Equal(t, task, redelivered.Task(), "the task which is not confirmed in time must be sent again")
+	assert.Equal(t, 1, redelivered.Redeliveries())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&expiredLeases))
+
+	forgotten.Confirm()
+	redelivered.Confirm()
+
+	assert.Equal(t, []domain.Task{task}, <-confirmedTasks, "the task must be confirmed once")
+
+	select {
+	case tasks := <-confirmedTasks:
+		t.Fatalf("the expired task must not be confirmed: %v", tasks)
+	case <-time.After(200 * time.Millisecond):
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&expiredLeases), "the confirmed task must not be expired")
+}
+
+func TestRollbackWithDelay(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 1)
+	tasksToDelay := make(chan domain.Task, 1)
+	savedAttempts := make(chan int, 1)
+
+	taskManagerMock := &task_manager.TaskManagerMock{
+		RollbackExecutionMock: func(ctx context.Context, task domain.Task) error {
+			savedAttempts <- task.Attempts
+			return nil
+		},
+	}
+
+	senderService := New(
+		taskManagerMock,
+		queues(taskReadyToSend),
+		tasksToDelay,
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{MaxAttempts: 5},
+	)
+
+	taskReadyToSend <- domain.Task{Id: "task", ExecTime: time.Now().Unix(), Attempts: 1}
+
+	now := time.Now()
+	senderService.Consume().RollbackWithDelay(3 * time.Second)
+
+	assert.Equal(t, 2, <-savedAttempts, "count of attempts is not saved")
+
+	delayedTask := <-tasksToDelay
+	assert.Equal(t, 2, delayedTask.Attempts)
+	assert.GreaterOrEqual(t, delayedTask.ExecTime, now.Add(3*time.Second).Unix(), "the task is delayed not enough")
+	assert.LessOrEqual(t, delayedTask.ExecTime, now.Add(4*time.Second).Unix(), "the task is delayed too much")
+}
+
+func TestRollbackAfterMaxAttempts(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 2)
+	deadLetters := make(chan domain.Task, 2)
+
+	taskManagerMock := &task_manager.TaskManagerMock{
+		RollbackExecutionMock: func(ctx context.Context, task domain.Task) error {
+			assert.Fail(t, "the task must be moved to the dead letter")
+			return nil
+		},
+		MoveToDeadLetterMock: func(ctx context.Context, task domain.Task, reason string) error {
+			assert.Equal(t, ReasonMaxAttemptsExceeded, reason)
+			deadLetters <- task
+			return nil
+		},
+	}
+
+	senderService := New(
+		taskManagerMock,
+		queues(taskReadyToSend),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{MaxAttempts: 3},
+	)
+
+	taskReadyToSend <- domain.Task{Id: "first", Attempts: 2}
+	taskReadyToSend <- domain.Task{Id: "second", Attempts: 2}
+
+	senderService.Consume().Rollback()
+	senderService.Consume().RollbackWithBackoff()
+
+	assert.Equal(t, domain.Task{Id: "first", Attempts: 3}, <-deadLetters)
+	assert.Equal(t, domain.Task{Id: "second", Attempts: 3}, <-deadLetters)
+}
+
+func TestPostpone(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 1)
+	tasksToDelay := make(chan domain.Task, 1)
+
+	taskManagerMock := &task_manager.TaskManagerMock{
+		RollbackExecutionMock: func(ctx context.Context, task domain.Task) error {
+			assert.Fail(t, "the postponed task is not an attempt")
+			return nil
+		},
+		MoveToDeadLetterMock: func(ctx context.Context, task domain.Task, reason string) error {
+			assert.Fail(t, "the postponed task must not be moved to the dead letter")
+			return nil
+		},
+	}
+
+	senderService := New(
+		taskManagerMock,
+		queues(taskReadyToSend),
+		tasksToDelay,
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{MaxAttempts: 1},
+	)
+
+	taskReadyToSend <- domain.Task{Id: "task", ExecTime: time.Now().Unix(), Attempts: 1}
+
+	now := time.Now()
+	senderService.Consume().Postpone(3 * time.Second)
+
+	delayedTask := <-tasksToDelay
+	assert.Equal(t, 1, delayedTask.Attempts, "the attempt must not be counted")
+	assert.GreaterOrEqual(t, delayedTask.ExecTime, now.Add(3*time.Second).Unix(), "the task is delayed not enough")
+	assert.LessOrEqual(t, delayedTask.ExecTime, now.Add(4*time.Second).Unix(), "the task is delayed too much")
+}
+
+func TestReject(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 2)
+	deadLetters := make(chan domain.Task, 1)
+	isMoved := true
+
+	taskManagerMock := &task_manager.TaskManagerMock{
+		MoveToDeadLetterMock: func(ctx context.Context, task domain.Task, reason string) error {
+			assert.Equal(t, "bad request", reason)
+			if !isMoved {
+				return contracts.TmErrorMovingToDeadLetter
+			}
+			deadLetters <- task
+			return nil
+		},
+	}
+
+	senderService := New(
+		taskManagerMock,
+		queues(taskReadyToSend),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{MaxAttempts: 3},
+	)
+
+	taskReadyToSend <- domain.Task{Id: "task"}
+	senderService.Consume().Reject("bad request")
+	assert.Equal(t, domain.Task{Id: "task", Attempts: 1}, <-deadLetters, "the task must be moved at once")
+
+	isMoved = false
+	taskReadyToSend <- domain.Task{Id: "task"}
+	senderService.Consume().Reject("bad request")
+	assert.Equal(t, "task", senderService.Consume().Task().Id, "the task which is not moved must be sent again")
+}
+
+func TestStop(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 2)
+	confirmedTasks := make(chan []domain.Task, 1)
+	releasedTasks := make(chan []domain.Task, 1)
+
+	taskManagerMock := &task_manager.TaskManagerMock{
+		ConfirmExecutionMock: func(ctx context.Context, tasks []domain.Task) error {
+			confirmedTasks <- tasks
+			return nil
+		},
+		ReleaseMock: func(ctx context.Context, tasks []domain.Task) error {
+			releasedTasks <- tasks
+			return nil
+		},
+	}
+
+	senderService := New(
+		taskManagerMock,
+		queues(taskReadyToSend),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{BatchTimeout: time.Hour},
+	)
+	go senderService.Run()
+
+	confirmedTask := domain.Task{Id: "confirmed", ExecTime: time.Now().Unix()}
+	rolledBackTask := domain.Task{Id: "rolled back", ExecTime: time.Now().Unix()}
+	taskReadyToSend <- confirmedTask
+	taskReadyToSend <- rolledBackTask
+
+	inFlight := senderService.Consume()
+	senderService.Consume().Rollback()
+
+	stopped := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- senderService.Stop(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, senderService.Consume(), "the task must not be sent after stopping")
+	select {
+	case <-stopped:
+		t.Fatal("the stopping must wait for the task in flight")
+	default:
+	}
+
+	inFlight.Confirm()
+
+	assert.NoError(t, <-stopped)
+	assert.Equal(t, []domain.Task{confirmedTask}, <-confirmedTasks, "the confirmation must be flushed")
+
+	rolledBackTask.Attempts = 1
+	assert.Equal(t, []domain.Task{rolledBackTask}, <-releasedTasks, "the rolled back task must be released")
+}
+
+/*
+	The tasks in flight are not waited for after the deadline of stopping,
+	but the confirmations are flushed and the tasks are released anyway
+*/
+func TestStopAfterDeadline(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 2)
+	confirmedTasks := make(chan []domain.Task, 2)
+	releasedTasks := make(chan []domain.Task, 1)
+
+	taskManagerMock := &task_manager.TaskManagerMock{
+		ConfirmExecutionMock: func(ctx context.Context, tasks []domain.Task) error {
+			confirmedTasks <- tasks
+			return nil
+		},
+		ReleaseMock: func(ctx context.Context, tasks []domain.Task) error {
+			assert.NoError(t, ctx.Err(), "the tasks must be released with the context which is not done")
+			releasedTasks <- tasks
+			return nil
+		},
+	}
+
+	senderService := New(
+		taskManagerMock,
+		queues(taskReadyToSend),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{BatchTimeout: time.Hour},
+	)
+	go senderService.Run()
+
+	confirmedTask := domain.Task{Id: "confirmed", ExecTime: time.Now().Unix()}
+	inFlightTask := domain.Task{Id: "in flight", ExecTime: time.Now().Unix()}
+	taskReadyToSend <- confirmedTask
+	taskReadyToSend <- inFlightTask
+
+	senderService.Consume().Confirm()
+	inFlight := senderService.Consume()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, senderService.Stop(ctx))
+
+	assert.Equal(t, []domain.Task{confirmedTask}, <-confirmedTasks, "the confirmation must be flushed")
+	assert.Equal(t, []domain.Task{inFlightTask}, <-releasedTasks, "the task in flight must be released")
+
+	inFlight.Confirm()
+	assert.Len(t, confirmedTasks, 0, "the released task must not be confirmed")
+}
+
+func TestConsumeCtxAndBatch(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 3)
+
+	senderService := New(
+		&task_manager.TaskManagerMock{
+			ConfirmExecutionMock: func(ctx context.Context, tasks []domain.Task) error {
+				return nil
+			},
+		},
+		queues(taskReadyToSend),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{BatchTimeout: time.Hour},
+	)
+	go senderService.Run()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	taskToSend, err := senderService.ConsumeCtx(ctx, "")
+	assert.Nil(t, taskToSend)
+	assert.Equal(t, context.DeadlineExceeded, err, "the error of the context must be returned")
+
+	for _, id := range []string{"first", "second", "third"} {
+		taskReadyToSend <- domain.Task{Id: id, ExecTime: time.Now().Unix()}
+	}
+
+	batch, err := senderService.ConsumeBatch(context.Background(), "", 2)
+	assert.NoError(t, err)
+	assert.Len(t, batch, 2, "the batch must not be larger than max")
+	assert.Equal(t, "first", batch[0].Task().Id)
+	assert.Equal(t, "second", batch[1].Task().Id)
+
+	rest, err := senderService.ConsumeBatch(context.Background(), "", 10)
+	assert.NoError(t, err)
+	assert.Len(t, rest, 1, "only the ready tasks must be returned")
+	assert.Equal(t, "third", rest[0].Task().Id)
+
+	for _, taskToSend := range append(batch, rest...) {
+		taskToSend.Confirm()
+	}
+
+	assert.NoError(t, senderService.Stop(context.Background()))
+
+	taskToSend, err = senderService.ConsumeCtx(context.Background(), "")
+	assert.Nil(t, taskToSend)
+	assert.Equal(t, contracts.ErrStopped, err)
+
+	batch, err = senderService.ConsumeBatch(context.Background(), "", 10)
+	assert.Nil(t, batch)
+	assert.Equal(t, contracts.ErrStopped, err)
+}
+
+func TestBackoff(t *testing.T) {
+	senderService := New(
+		&task_manager.TaskManagerMock{},
+		queues(make(chan domain.Task)),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		&monitoring_service.MonitoringMock{},
+		&Options{BackoffInitialDelay: time.Second, BackoffMaxDelay: 10 * time.Second, BackoffMultiplier: 3},
+	).(*senderService)
+
+	expectedDelays := []time.Duration{time.Second, 3 * time.Second, 9 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, expectedDelay := range expectedDelays {
+		assert.Equal(t, expectedDelay, senderService.backoff(i+1), "delay of the attempt %d is not correct", i+1)
+	}
+}
+
+func TestLatenessAndProcessingTime(t *testing.T) {
+	taskReadyToSend := make(chan domain.Task, 1)
+	measurements := make(chan int64, 2)
+
+	monitoringMock := &monitoring_service.MonitoringMock{
+		PublishMock: func(topic contracts.Topic, measurement int64) error {
+			if topic == contracts.Lateness || topic == contracts.ProcessingTime {
+				measurements <- measurement
+			}
+			return nil
+		},
+	}
+
+	senderService := New(
+		&task_manager.TaskManagerMock{},
+		queues(taskReadyToSend),
+		make(chan domain.Task),
+		&error_service.ErrorHandlerMock{},
+		monitoringMock,
+		nil,
+	)
+
+	execTime := time.Now().Add(-2 * time.Second)
+	taskReadyToSend <- domain.Task{Id: "task", ExecTime: execTime.Unix()}
+
+	taskToSend := senderService.Consume()
+	lateness := <-measurements
+	assert.GreaterOrEqual(t, lateness, int64(2000), "the lateness is not correct")
+	assert.Less(t, lateness, int64(3100), "the lateness is not correct")
+
+	time.Sleep(50 * time.Millisecond)
+	taskToSend.Confirm()
+	processingTime := <-measurements
+	assert.GreaterOrEqual(t, processingTime, int64(50), "the processing time is not correct")
+	assert.Less(t, processingTime, int64(1000), "the processing time is not correct")
+}
+
+func TestBufferPriority(t *testing.T) {
+	b := &buffer{list: list.New()}
+	for _, task := range []domain.Task{
+		{Id: "marketing-1", Priority: -1},
+		{Id: "reminder-1"},
+		{Id: "payment", Priority: 10},
+		{Id: "marketing-2", Priority: -1},
+		{Id: "reminder-2"},
+	} {
+		b.push(delivery{task: task})
+	}
+
+	var actual []string
+	for e := b.list.Front(); e != nil; e = e.Next() {
+		actual = append(actual, e.Value.(delivery).task.Id)
+	}
+	assert.Equal(t, []string{"payment", "reminder-1", "reminder-2", "marketing-1", "marketing-2"}, actual,
+		"the tasks must be sent in the order of the priority, the tasks with the same priority in the order of adding")
+}
@@ -33,16 +33,24 @@ func (t *batchTaskQueue) Pop() ([]domain.Task, error) {
 	return nil, QueueIsEmpty
 }
 
+/*
+	The task that is sent to the consumer again
+*/
+type delivery struct {
+	task         domain.Task
+	redeliveries int
+}
+
 type buffer struct {
-	In   chan domain.Task
-	Out  chan domain.Task
+	In   chan delivery
+	Out  chan delivery
 	list *list.List
 }
 
 func NewBuffer() *buffer {
 	b := &buffer{
-		In:   make(chan domain.Task, 1),
-		Out:  make(chan domain.Task, 1),
+		In:   make(chan delivery, 1),
+		Out:  make(chan delivery, 1),
 		list: list.New(),
 	}
 	go b.run()
@@ -62,14 +70,14 @@ func (b *buffer) run() {
 				close(b.Out)
 				return
 			}
-			b.list.PushBack(value)
+			b.push(value)
 		} else {
 			select {
-			case b.Out <- front.Value.(domain.Task):
+			case b.Out <- front.Value.(delivery):
 				b.list.Remove(front)
 			case value, ok := <-b.In:
 				if ok {
-					b.list.PushBack(value)
+					b.push(value)
 				} else {
 					b.In = nil
 				}
@@ -77,3 +85,18 @@ func (b *buffer) run() {
 		}
 	}
 }
+
+/*
+	The tasks in the buffer are ready at the same time, so the task with the higher priority is sent first.
+	The tasks with the same priority are sent in the order of adding
+*/
+func (b *buffer) push(value delivery) {
+	for e := b.list.Back(); e != nil; e = e.Prev() {
+		if e.Value.(delivery).task.Priority >= value.task.Priority {
+			b.list.InsertAfter(value, e)
+
+			return
+		}
+	}
+	b.list.PushFront(value)
+}
@@ -2,17 +2,44 @@ package task_manager
 
 import (
 	"context"
+	"math"
 	"time"
 
 	"github.com/imdario/mergo"
 	"github.com/pvelx/triggerhook/contracts"
 	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/recurrence"
 	"github.com/pvelx/triggerhook/util"
 )
 
+/*
+	Count of the listed tasks if the limit is not specified
+*/
+const defaultListLimit = 100
+
+/*
+	Max length of the name of the queue stored in the database
+*/
+const maxQueueLength = 255
+
+/*
+	Max length of the idempotency key stored in the database
+*/
+const maxIdempotencyKeyLength = 255
+
 type Options struct {
 	MaxRetry            int
 	TimeGapBetweenRetry time.Duration
+
+	/*
+		Maximum size of the payload of the task in bytes
+	*/
+	MaxPayloadSize int
+
+	/*
+		Maximum size of the headers of the task in bytes (sum of lengths of all keys and values)
+	*/
+	MaxHeadersSize int
 }
 
 func New(
@@ -33,6 +60,8 @@ func New(
 	if err := mergo.Merge(options, Options{
 		MaxRetry:            3,
 		TimeGapBetweenRetry: 10 * time.Millisecond,
+		MaxPayloadSize:      64 * 1024,
+		MaxHeadersSize:      4 * 1024,
 	}); err != nil {
 		panic(err)
 	}
@@ -48,6 +77,9 @@ func New(
 	if err := monitoring.Publish(contracts.All, int64(count)); err != nil {
 		panic(err)
 	}
+	if err := monitoring.Init(contracts.DeadLettered, contracts.VelocityMetricType); err != nil {
+		panic(err)
+	}
 
 	return &taskManager{
 		repository:          repository,
@@ -55,6 +87,8 @@ func New(
 		maxRetry:            options.MaxRetry,
 		timeGapBetweenRetry: options.TimeGapBetweenRetry,
 		monitoring:          monitoring,
+		maxPayloadSize:      options.MaxPayloadSize,
+		maxHeadersSize:      options.MaxHeadersSize,
 	}
 }
 
@@ -65,18 +99,13 @@ type taskManager struct {
 	maxRetry            int
 	timeGapBetweenRetry time.Duration
 	monitoring          contracts.MonitoringInterface
+	maxPayloadSize      int
+	maxHeadersSize      int
 }
 
 func (s *taskManager) Create(ctx context.Context, task *domain.Task, isTaken bool) error {
-	if now := time.Now().Unix(); task.ExecTime < now {
-		task.ExecTime = now
-	}
-
-	if task.Id == "" {
-		task.Id = util.NewId()
-
-	} else if !util.IsIdValid(task.Id) {
-		return contracts.TmErrorUuidIsNotCorrect
+	if err := s.prepare(task); err != nil {
+		return err
 	}
 
 	err := s.retry(func() error {
@@ -89,6 +118,8 @@ func (s *taskManager) Create(ctx context.Context, task *domain.Task, isTaken boo
 		})
 
 		return contracts.TmErrorTaskExist
+	} else if err == contracts.RepoErrorIdempotencyKeyExist {
+		return s.existingTask(ctx, task)
 	} else if err != nil {
 		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
 			"task": task,
@@ -104,6 +135,175 @@ func (s *taskManager) Create(ctx context.Context, task *domain.Task, isTaken boo
 	return nil
 }
 
+func (s *taskManager) CreateBatch(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+	results := make([]error, len(tasks))
+
+	var validTasks []domain.Task
+	var indexes []int
+	for i := range tasks {
+		if err := s.prepare(&tasks[i]); err != nil {
+			results[i] = err
+			continue
+		}
+
+		validTasks = append(validTasks, tasks[i])
+		indexes = append(indexes, i)
+	}
+
+	if len(validTasks) == 0 {
+		return results, nil
+	}
+
+	//	The task created by someone else after checking of the existing tasks fails the whole batch,
+	//	the task is found by the next try
+	var repositoryResults []error
+	err := s.retry(func() (err error) {
+		repositoryResults, err = s.repository.CreateBatch(ctx, validTasks, isTaken)
+		return
+	}, contracts.RepoErrorDeadlock, contracts.RepoErrorTaskExist)
+
+	if err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"count of task": len(validTasks),
+		})
+
+		return nil, contracts.TmErrorCreatingTasks
+	}
+
+	var created int64
+	for i, err := range repositoryResults {
+		switch {
+		case err == contracts.RepoErrorTaskExist:
+			results[indexes[i]] = contracts.TmErrorTaskExist
+		case err == contracts.RepoErrorIdempotencyKeyExist:
+			results[indexes[i]] = s.existingTask(ctx, &tasks[indexes[i]])
+		case err != nil:
+			results[indexes[i]] = contracts.TmErrorCreatingTasks
+		default:
+			created++
+		}
+	}
+
+	if err := s.monitoring.Publish(contracts.All, created); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return results, nil
+}
+
+/*
+	Fills the id of the task which has reserved the idempotency key of the given task
+*/
+func (s *taskManager) existingTask(ctx context.Context, task *domain.Task) error {
+	var taskId string
+	err := s.retry(func() (err error) {
+		taskId, err = s.repository.GetIdByIdempotencyKey(ctx, task.IdempotencyKey)
+		return
+	}, contracts.RepoErrorDeadlock)
+
+	if err != nil {
+		//	The key may be released by the cleaning right after the checking
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"task": task,
+		})
+
+		return contracts.TmErrorCreatingTasks
+	}
+
+	s.eh.New(contracts.LevelDebug, contracts.RepoErrorIdempotencyKeyExist.Error(), map[string]interface{}{
+		"task":             task,
+		"existing task id": taskId,
+	})
+	task.Id = taskId
+
+	return contracts.TmErrorIdempotencyKeyExist
+}
+
+/*
+	Validates the task and fills the time of execution and the id
+*/
+func (s *taskManager) prepare(task *domain.Task) error {
+	if task.Recurrence != nil {
+		if err := recurrence.Validate(*task.Recurrence); err != nil {
+			s.eh.New(contracts.LevelDebug, err.Error(), map[string]interface{}{
+				"task": task,
+			})
+
+			return contracts.TmErrorRecurrenceIsNotCorrect
+		}
+
+		if task.ExecTimeInMs() == 0 {
+			first, err := recurrence.First(*task.Recurrence, time.Now())
+			if err != nil {
+				s.eh.New(contracts.LevelDebug, err.Error(), map[string]interface{}{
+					"task": task,
+				})
+
+				return contracts.TmErrorRecurrenceIsNotCorrect
+			}
+			task.ExecTime = first
+		}
+	}
+
+	fillExecTime(task)
+
+	if task.Id == "" {
+		task.Id = util.NewId()
+
+	} else if !util.IsIdValid(task.Id) {
+		return contracts.TmErrorUuidIsNotCorrect
+	}
+
+	if len(task.Payload) > s.maxPayloadSize {
+		return contracts.TmErrorPayloadTooLarge
+	}
+
+	headersSize := 0
+	for key, value := range task.Headers {
+		headersSize += len(key) + len(value)
+	}
+	if headersSize > s.maxHeadersSize {
+		return contracts.TmErrorHeadersTooLarge
+	}
+
+	if len(task.Queue) > maxQueueLength {
+		return contracts.TmErrorQueueIsNotCorrect
+	}
+
+	if len(task.IdempotencyKey) > maxIdempotencyKeyLength {
+		return contracts.TmErrorIdempotencyKeyIsNotCorrect
+	}
+
+	//	The priority is stored in the database as a 32-bit integer
+	if task.Priority < math.MinInt32 || task.Priority > math.MaxInt32 {
+		return contracts.TmErrorPriorityIsNotCorrect
+	}
+
+	return nil
+}
+
+/*
+	The time in the past is replaced by the current time with the precision which is specified.
+	The time in milliseconds is kept only if it is specified, so the time of the task given in seconds
+	is changed by ExecTime alone
+*/
+func fillExecTime(task *domain.Task) {
+	execTimeMs := task.ExecTimeInMs()
+	if execTimeMs != task.ExecTime*1000 {
+		if now := util.ToMs(time.Now()); execTimeMs < now {
+			execTimeMs = now
+		}
+		task.SetExecTimeMs(execTimeMs)
+
+		return
+	}
+
+	if now := time.Now().Unix(); task.ExecTime < now {
+		task.ExecTime = now
+	}
+	task.ExecTimeMs = 0
+}
+
 func (s *taskManager) Delete(ctx context.Context, taskId string) error {
 	var affected int64
 	errDeleting := s.retry(func() (err error) {
@@ -130,10 +330,47 @@ func (s *taskManager) Delete(ctx context.Context, taskId string) error {
 	return nil
 }
 
-func (s *taskManager) GetTasksToComplete(ctx context.Context, preloadingTimeRange time.Duration) (contracts.CollectionsInterface, error) {
+func (s *taskManager) DeleteBatch(ctx context.Context, taskIds []string) ([]error, error) {
+	var repositoryResults []error
+	errDeleting := s.retry(func() (err error) {
+		repositoryResults, err = s.repository.DeleteBatch(ctx, taskIds)
+		return
+	}, contracts.RepoErrorDeadlock)
+
+	if errDeleting != nil {
+		s.eh.New(contracts.LevelError, errDeleting.Error(), map[string]interface{}{
+			"count of task": len(taskIds),
+		})
+
+		return nil, contracts.TmErrorDeletingTask
+	}
+
+	results := make([]error, len(taskIds))
+	var deleted int64
+	for i, err := range repositoryResults {
+		switch {
+		case err == contracts.RepoErrorTaskNotFound:
+			results[i] = contracts.TmErrorTaskNotFound
+		case err != nil:
+			results[i] = contracts.TmErrorDeletingTask
+		default:
+			deleted++
+		}
+	}
+
+	if err := s.monitoring.Publish(contracts.All, -deleted); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return results, nil
+}
+
+func (s *taskManager) GetTasksToComplete(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (
+	contracts.CollectionsInterface, error) {
+
 	var collections contracts.CollectionsInterface
 	errFinding := s.retry(func() (err error) {
-		collections, err = s.repository.FindBySecToExecTime(ctx, preloadingTimeRange)
+		collections, err = s.repository.FindBySecToExecTime(ctx, preloadingTimeRange, queues)
 		return
 	}, contracts.RepoErrorDeadlock, contracts.RepoErrorLockWaitTimeout)
 
@@ -149,9 +386,29 @@ func (s *taskManager) GetTasksToComplete(ctx context.Context, preloadingTimeRang
 }
 
 func (s *taskManager) ConfirmExecution(ctx context.Context, tasks []domain.Task) error {
-	var affected int64
+	now := time.Now()
+	var nextTasks []domain.Task
+	for _, task := range tasks {
+		next, ok, err := recurrence.Next(task, now)
+		if err != nil {
+			s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+				"task": task,
+			})
+			continue
+		}
+
+		if ok {
+			nextTasks = append(nextTasks, next)
+		}
+	}
+
+	var deleted, created int64
 	errConfirm := s.retry(func() (err error) {
-		affected, err = s.repository.Delete(ctx, tasks)
+		if len(nextTasks) == 0 {
+			deleted, err = s.repository.Delete(ctx, tasks)
+		} else {
+			deleted, created, err = s.repository.DeleteAndCreate(ctx, tasks, nextTasks)
+		}
 		return
 	}, contracts.RepoErrorDeadlock)
 
@@ -162,8 +419,244 @@ func (s *taskManager) ConfirmExecution(ctx context.Context, tasks []domain.Task)
 		return contracts.TmErrorConfirmationTasks
 	}
 
-	if err := s.monitoring.Publish(contracts.All, -affected); err != nil {
+	if err := s.monitoring.Publish(contracts.All, created-deleted); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return nil
+}
+
+func (s *taskManager) RollbackExecution(ctx context.Context, task domain.Task) error {
+	errUpdating := s.retry(func() error {
+		return s.repository.UpdateAttempts(ctx, task)
+	}, contracts.RepoErrorDeadlock)
+
+	if errUpdating != nil {
+		s.eh.New(contracts.LevelError, errUpdating.Error(), map[string]interface{}{
+			"task": task,
+		})
+
+		return contracts.TmErrorRollbackTask
+	}
+
+	return nil
+}
+
+func (s *taskManager) MoveToDeadLetter(ctx context.Context, task domain.Task, reason string) error {
+	errMoving := s.retry(func() error {
+		return s.repository.MoveToDeadLetter(ctx, task, reason)
+	}, contracts.RepoErrorDeadlock)
+
+	switch {
+	case errMoving == contracts.RepoErrorTaskNotFound:
+		return contracts.TmErrorTaskNotFound
+	case errMoving != nil:
+		s.eh.New(contracts.LevelError, errMoving.Error(), map[string]interface{}{
+			"task":   task,
+			"reason": reason,
+		})
+
+		return contracts.TmErrorMovingToDeadLetter
+	}
+
+	if err := s.monitoring.Publish(contracts.All, -1); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+	if err := s.monitoring.Publish(contracts.DeadLettered, 1); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return nil
+}
+
+func (s *taskManager) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	task, err := s.repository.Get(ctx, taskId)
+
+	switch {
+	case err == contracts.RepoErrorTaskNotFound:
+		return task, contracts.TmErrorTaskNotFound
+	case err != nil:
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"taskId": taskId,
+		})
+
+		return task, contracts.TmErrorFindingTasks
+	}
+
+	return task, nil
+}
+
+func (s *taskManager) List(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	tasks, err := s.repository.List(ctx, filter)
+	if err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return nil, contracts.TmErrorFindingTasks
+	}
+
+	return tasks, nil
+}
+
+func (s *taskManager) Reschedule(ctx context.Context, task *domain.Task, isTaken bool) error {
+	fillExecTime(task)
+
+	var rescheduled domain.Task
+	errRescheduling := s.retry(func() (err error) {
+		rescheduled, err = s.repository.Reschedule(ctx, *task, isTaken)
+		return
+	}, contracts.RepoErrorDeadlock)
+
+	switch {
+	case errRescheduling == contracts.RepoErrorTaskNotFound:
+		return contracts.TmErrorTaskNotFound
+	case errRescheduling != nil:
+		s.eh.New(contracts.LevelError, errRescheduling.Error(), map[string]interface{}{
+			"task": task,
+		})
+
+		return contracts.TmErrorReschedulingTask
+	}
+
+	*task = rescheduled
+
+	return nil
+}
+
+func (s *taskManager) GetDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error) {
+	deadLetters, err := s.repository.FindDeadLetters(ctx, limit, offset)
+	if err != nil {
 		s.eh.New(contracts.LevelError, err.Error(), nil)
+
+		return nil, contracts.TmErrorGettingDeadLetters
+	}
+
+	return deadLetters, nil
+}
+
+func (s *taskManager) GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error) {
+	deadLetter, err := s.repository.GetDeadLetter(ctx, taskId)
+
+	switch {
+	case err == contracts.RepoErrorTaskNotFound:
+		return deadLetter, contracts.TmErrorTaskNotFound
+	case err != nil:
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"taskId": taskId,
+		})
+
+		return deadLetter, contracts.TmErrorGettingDeadLetters
+	}
+
+	return deadLetter, nil
+}
+
+func (s *taskManager) RequeueDeadLetter(ctx context.Context, task *domain.Task, isTaken bool) error {
+	task.Attempts = 0
+	if err := s.prepare(task); err != nil {
+		return err
+	}
+
+	err := s.retry(func() error {
+		return s.repository.Requeue(ctx, *task, isTaken)
+	}, contracts.RepoErrorDeadlock)
+
+	switch {
+	case err == contracts.RepoErrorTaskNotFound:
+		return contracts.TmErrorTaskNotFound
+	case err == contracts.RepoErrorTaskExist:
+		return contracts.TmErrorTaskExist
+	case err == contracts.RepoErrorIdempotencyKeyExist:
+		return s.existingTask(ctx, task)
+	case err != nil:
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{
+			"task": task,
+		})
+
+		return contracts.TmErrorRequeueTask
+	}
+
+	if err := s.monitoring.Publish(contracts.All, 1); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return nil
+}
+
+func (s *taskManager) PurgeDeadLetters(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	errPurging := s.retry(func() (err error) {
+		deleted, err = s.repository.PurgeDeadLetters(ctx, before.Unix())
+		return
+	}, contracts.RepoErrorDeadlock)
+
+	if errPurging != nil {
+		s.eh.New(contracts.LevelError, errPurging.Error(), nil)
+
+		return 0, contracts.TmErrorPurgingDeadLetters
+	}
+
+	return deleted, nil
+}
+
+/*
+	Count of the tasks released by one query
+*/
+const releaseBatchSize = 1000
+
+func (s *taskManager) Release(ctx context.Context, tasks []domain.Task) error {
+	for len(tasks) > 0 {
+		batch := tasks
+		if len(batch) > releaseBatchSize {
+			batch = batch[:releaseBatchSize]
+		}
+		tasks = tasks[len(batch):]
+
+		errReleasing := s.retry(func() error {
+			return s.repository.Release(ctx, batch)
+		}, contracts.RepoErrorDeadlock, contracts.RepoErrorLockWaitTimeout)
+
+		if errReleasing != nil {
+			s.eh.New(contracts.LevelError, errReleasing.Error(), map[string]interface{}{
+				"count of task": len(batch),
+			})
+
+			return contracts.TmErrorReleasingTasks
+		}
+	}
+
+	return nil
+}
+
+func (s *taskManager) Heartbeat(ctx context.Context) error {
+	errHeartbeat := s.retry(func() error {
+		return s.repository.Heartbeat(ctx)
+	}, contracts.RepoErrorDeadlock, contracts.RepoErrorLockWaitTimeout)
+
+	if errHeartbeat != nil {
+		s.eh.New(contracts.LevelError, errHeartbeat.Error(), nil)
+
+		return contracts.TmErrorHeartbeat
+	}
+
+	return nil
+}
+
+func (s *taskManager) Unregister(ctx context.Context) error {
+	errUnregistering := s.retry(func() error {
+		return s.repository.Unregister(ctx)
+	}, contracts.RepoErrorDeadlock, contracts.RepoErrorLockWaitTimeout)
+
+	if errUnregistering != nil {
+		s.eh.New(contracts.LevelError, errUnregistering.Error(), nil)
+
+		return contracts.TmErrorUnregistering
 	}
 
 	return nil
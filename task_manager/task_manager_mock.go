@@ -17,7 +17,21 @@ type TaskManagerMock struct {
 	ConfirmExecutionMock   func(ctx context.Context, tasks []domain.Task) error
 	CreateMock             func(ctx context.Context, task *domain.Task, isTaken bool) error
 	DeleteMock             func(ctx context.Context, taskId string) error
-	GetTasksToCompleteMock func(ctx context.Context, preloadingTimeRange time.Duration) (contracts.CollectionsInterface, error)
+	GetMock                func(ctx context.Context, taskId string) (domain.Task, error)
+	ListMock               func(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error)
+	CreateBatchMock        func(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error)
+	DeleteBatchMock        func(ctx context.Context, taskIds []string) ([]error, error)
+	RescheduleMock         func(ctx context.Context, task *domain.Task, isTaken bool) error
+	GetTasksToCompleteMock func(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (contracts.CollectionsInterface, error)
+	RollbackExecutionMock  func(ctx context.Context, task domain.Task) error
+	MoveToDeadLetterMock   func(ctx context.Context, task domain.Task, reason string) error
+	GetDeadLettersMock     func(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error)
+	GetDeadLetterMock      func(ctx context.Context, taskId string) (domain.DeadLetter, error)
+	RequeueDeadLetterMock  func(ctx context.Context, task *domain.Task, isTaken bool) error
+	PurgeDeadLettersMock   func(ctx context.Context, before time.Time) (int64, error)
+	ReleaseMock            func(ctx context.Context, tasks []domain.Task) error
+	HeartbeatMock          func(ctx context.Context) error
+	UnregisterMock         func(ctx context.Context) error
 }
 
 func (tm *TaskManagerMock) ConfirmExecution(ctx context.Context, tasks []domain.Task) error {
@@ -28,10 +42,80 @@ func (tm *TaskManagerMock) Create(ctx context.Context, task *domain.Task, isTake
 	return tm.CreateMock(ctx, task, isTaken)
 }
 
-func (tm *TaskManagerMock) GetTasksToComplete(ctx context.Context, preloadingTimeRange time.Duration) (contracts.CollectionsInterface, error) {
-	return tm.GetTasksToCompleteMock(ctx, preloadingTimeRange)
+func (tm *TaskManagerMock) GetTasksToComplete(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (
+	contracts.CollectionsInterface, error) {
+
+	return tm.GetTasksToCompleteMock(ctx, preloadingTimeRange, queues)
 }
 
 func (tm *TaskManagerMock) Delete(ctx context.Context, taskId string) error {
 	return tm.DeleteMock(ctx, taskId)
 }
+
+func (tm *TaskManagerMock) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	return tm.GetMock(ctx, taskId)
+}
+
+func (tm *TaskManagerMock) List(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+	return tm.ListMock(ctx, filter)
+}
+
+func (tm *TaskManagerMock) CreateBatch(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+	return tm.CreateBatchMock(ctx, tasks, isTaken)
+}
+
+func (tm *TaskManagerMock) DeleteBatch(ctx context.Context, taskIds []string) ([]error, error) {
+	return tm.DeleteBatchMock(ctx, taskIds)
+}
+
+func (tm *TaskManagerMock) Reschedule(ctx context.Context, task *domain.Task, isTaken bool) error {
+	return tm.RescheduleMock(ctx, task, isTaken)
+}
+
+func (tm *TaskManagerMock) RollbackExecution(ctx context.Context, task domain.Task) error {
+	if tm.RollbackExecutionMock == nil {
+		return nil
+	}
+	return tm.RollbackExecutionMock(ctx, task)
+}
+
+func (tm *TaskManagerMock) MoveToDeadLetter(ctx context.Context, task domain.Task, reason string) error {
+	return tm.MoveToDeadLetterMock(ctx, task, reason)
+}
+
+func (tm *TaskManagerMock) GetDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error) {
+	return tm.GetDeadLettersMock(ctx, limit, offset)
+}
+
+func (tm *TaskManagerMock) GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error) {
+	return tm.GetDeadLetterMock(ctx, taskId)
+}
+
+func (tm *TaskManagerMock) RequeueDeadLetter(ctx context.Context, task *domain.Task, isTaken bool) error {
+	return tm.RequeueDeadLetterMock(ctx, task, isTaken)
+}
+
+func (tm *TaskManagerMock) PurgeDeadLetters(ctx context.Context, before time.Time) (int64, error) {
+	return tm.PurgeDeadLettersMock(ctx, before)
+}
+
+func (tm *TaskManagerMock) Release(ctx context.Context, tasks []domain.Task) error {
+	if tm.ReleaseMock == nil {
+		return nil
+	}
+	return tm.ReleaseMock(ctx, tasks)
+}
+
+func (tm *TaskManagerMock) Heartbeat(ctx context.Context) error {
+	if tm.HeartbeatMock == nil {
+		return nil
+	}
+	return tm.HeartbeatMock(ctx)
+}
+
+func (tm *TaskManagerMock) Unregister(ctx context.Context) error {
+	if tm.UnregisterMock == nil {
+		return nil
+	}
+	return tm.UnregisterMock(ctx)
+}
@@ -2,6 +2,9 @@ package task_manager
 
 import (
 	"context"
+	"errors"
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -154,6 +157,63 @@ func TestTaskManager_Create(t *testing.T) {
 	}
 }
 
+func TestTaskManager_CreateTooLargeTask(t *testing.T) {
+	tests := []struct {
+		name          string
+		inputTask     domain.Task
+		expectedError error
+	}{
+		{
+			name:          "payload within the limit",
+			inputTask:     domain.Task{Payload: make([]byte, 16)},
+			expectedError: nil,
+		},
+		{
+			name:          "too large payload",
+			inputTask:     domain.Task{Payload: make([]byte, 17)},
+			expectedError: contracts.TmErrorPayloadTooLarge,
+		},
+		{
+			name:          "headers within the limit",
+			inputTask:     domain.Task{Headers: map[string]string{"type": "push", "lang": "en"}},
+			expectedError: nil,
+		},
+		{
+			name:          "too large headers",
+			inputTask:     domain.Task{Headers: map[string]string{"type": "reminder", "lang": "en"}},
+			expectedError: contracts.TmErrorHeadersTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			countCallMethodOfRepository := 0
+			r := &repository.RepositoryMock{CreateMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+				countCallMethodOfRepository++
+
+				return nil
+			}}
+
+			tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, &Options{
+				MaxPayloadSize: 16,
+				MaxHeadersSize: 14,
+			})
+
+			result := tm.Create(context.Background(), &test.inputTask, true)
+
+			assert.Equal(t, test.expectedError, result, "error from task manager is not correct")
+
+			expectedCountCall := 1
+			if test.expectedError != nil {
+				expectedCountCall = 0
+			}
+			assert.Equal(t, expectedCountCall, countCallMethodOfRepository,
+				"is not correct call method create of repository")
+		})
+	}
+}
+
 func TestTaskManager_ConfirmExecution(t *testing.T) {
 	tests := []struct {
 		name                        string
@@ -217,6 +277,496 @@ func TestTaskManager_ConfirmExecution(t *testing.T) {
 	}
 }
 
+func TestTaskManager_ConfirmExecutionOfRecurrentTasks(t *testing.T) {
+	now := time.Now().Unix()
+	onceTask := domain.Task{Id: util.NewId(), ExecTime: now}
+	recurrentTask := domain.Task{Id: util.NewId(), ExecTime: now, Recurrence: &domain.Recurrence{Interval: 60}}
+	finishedTask := domain.Task{Id: util.NewId(), ExecTime: now, Recurrence: &domain.Recurrence{
+		Interval:       60,
+		MaxOccurrences: 2,
+		Occurrence:     1,
+	}}
+
+	var actualTasks, actualNextTasks []domain.Task
+	r := &repository.RepositoryMock{
+		DeleteMock: func(ctx context.Context, tasks []domain.Task) (int64, error) {
+			assert.Fail(t, "the tasks must be deleted and the next occurrences must be created in one transaction")
+			return 0, nil
+		},
+		DeleteAndCreateMock: func(ctx context.Context, tasks []domain.Task, nextTasks []domain.Task) (int64, int64, error) {
+			actualTasks, actualNextTasks = tasks, nextTasks
+			return int64(len(tasks)), int64(len(nextTasks)), nil
+		},
+	}
+
+	var published int64
+	monitoring := &monitoring_service.MonitoringMock{PublishMock: func(topic contracts.Topic, measurement int64) error {
+		if topic == contracts.All {
+			published += measurement
+		}
+		return nil
+	}}
+
+	tm := New(r, &error_service.ErrorHandlerMock{}, monitoring, nil)
+	published = 0
+
+	err := tm.ConfirmExecution(context.Background(), []domain.Task{onceTask, recurrentTask, finishedTask})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Task{onceTask, recurrentTask, finishedTask}, actualTasks, "all tasks must be deleted")
+	assert.Len(t, actualNextTasks, 1, "only one task must be repeated")
+	assert.Equal(t, recurrentTask.Id, actualNextTasks[0].Id, "the next occurrence must have the same id")
+	assert.Equal(t, now+60, actualNextTasks[0].ExecTime, "time of the next occurrence is not correct")
+	assert.Equal(t, 1, actualNextTasks[0].Recurrence.Occurrence, "number of the occurrence is not correct")
+	assert.Equal(t, int64(-2), published, "count of all tasks is changed not correctly")
+}
+
+func TestTaskManager_CreateRecurrentTask(t *testing.T) {
+	r := &repository.RepositoryMock{CreateMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+		return nil
+	}}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	task := &domain.Task{Recurrence: &domain.Recurrence{Cron: "0 3 * * *", Interval: 60}}
+	assert.Equal(t, contracts.TmErrorRecurrenceIsNotCorrect, tm.Create(context.Background(), task, false))
+
+	task = &domain.Task{Recurrence: &domain.Recurrence{Interval: 3600}}
+	before := time.Now().Unix()
+	assert.NoError(t, tm.Create(context.Background(), task, false))
+	assert.GreaterOrEqual(t, task.ExecTime, before+3600, "time of the first occurrence is not correct")
+	assert.LessOrEqual(t, task.ExecTime, time.Now().Unix()+3600, "time of the first occurrence is not correct")
+}
+
+func TestTaskManager_CreateTaskOfQueue(t *testing.T) {
+	var created domain.Task
+	r := &repository.RepositoryMock{CreateMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+		created = task
+		return nil
+	}}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	task := &domain.Task{ExecTime: time.Now().Unix(), Queue: strings.Repeat("q", maxQueueLength+1)}
+	assert.Equal(t, contracts.TmErrorQueueIsNotCorrect, tm.Create(context.Background(), task, false))
+
+	task = &domain.Task{ExecTime: time.Now().Unix(), Queue: "emails"}
+	assert.NoError(t, tm.Create(context.Background(), task, false))
+	assert.Equal(t, "emails", created.Queue, "the queue of the task must be kept")
+}
+
+func TestTaskManager_CreateTaskWithPriority(t *testing.T) {
+	var created domain.Task
+	r := &repository.RepositoryMock{CreateMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+		created = task
+		return nil
+	}}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	task := &domain.Task{ExecTime: time.Now().Unix(), Priority: math.MaxInt32 + 1}
+	assert.Equal(t, contracts.TmErrorPriorityIsNotCorrect, tm.Create(context.Background(), task, false))
+
+	task = &domain.Task{ExecTime: time.Now().Unix(), Priority: math.MinInt32}
+	assert.NoError(t, tm.Create(context.Background(), task, false))
+	assert.Equal(t, math.MinInt32, created.Priority, "the priority of the task must be kept")
+}
+
+func TestTaskManager_CreateWithIdempotencyKey(t *testing.T) {
+	existingId := util.NewId()
+	r := &repository.RepositoryMock{
+		CreateMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+			return contracts.RepoErrorIdempotencyKeyExist
+		},
+		CreateBatchMock: func(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+			return []error{nil, contracts.RepoErrorIdempotencyKeyExist}, nil
+		},
+		RequeueMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+			return contracts.RepoErrorIdempotencyKeyExist
+		},
+		GetIdByIdempotencyKeyMock: func(ctx context.Context, key string) (string, error) {
+			assert.Equal(t, "order-1", key)
+			return existingId, nil
+		},
+	}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	task := &domain.Task{ExecTime: time.Now().Unix(), IdempotencyKey: strings.Repeat("k", 256)}
+	assert.Equal(t, contracts.TmErrorIdempotencyKeyIsNotCorrect, tm.Create(context.Background(), task, false))
+
+	task = &domain.Task{ExecTime: time.Now().Unix(), IdempotencyKey: "order-1"}
+	assert.Equal(t, contracts.TmErrorIdempotencyKeyExist, tm.Create(context.Background(), task, false))
+	assert.Equal(t, existingId, task.Id, "the id of the existing task must be returned")
+
+	tasks := []domain.Task{
+		{ExecTime: time.Now().Unix(), IdempotencyKey: "order-2"},
+		{ExecTime: time.Now().Unix(), IdempotencyKey: "order-1"},
+	}
+	results, err := tm.CreateBatch(context.Background(), tasks, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, contracts.TmErrorIdempotencyKeyExist}, results)
+	assert.Equal(t, existingId, tasks[1].Id, "the id of the existing task must be returned")
+	assert.NotEqual(t, existingId, tasks[0].Id)
+
+	task = &domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix(), IdempotencyKey: "order-1"}
+	assert.Equal(t, contracts.TmErrorIdempotencyKeyExist, tm.RequeueDeadLetter(context.Background(), task, false))
+	assert.Equal(t, existingId, task.Id, "the id of the existing task must be returned")
+}
+
+func TestTaskManager_CreateMilliseconds(t *testing.T) {
+	r := &repository.RepositoryMock{CreateMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+		return nil
+	}}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	execTimeMs := util.ToMs(time.Now()) + 60250
+	task := &domain.Task{ExecTimeMs: execTimeMs}
+	assert.NoError(t, tm.Create(context.Background(), task, false))
+	assert.Equal(t, execTimeMs, task.ExecTimeMs)
+	assert.Equal(t, execTimeMs/1000, task.ExecTime, "time in seconds must be the second of the time in milliseconds")
+
+	execTime := time.Now().Unix() + 60
+	task = &domain.Task{ExecTime: execTime}
+	assert.NoError(t, tm.Create(context.Background(), task, false))
+	assert.Equal(t, execTime, task.ExecTime)
+	assert.Equal(t, int64(0), task.ExecTimeMs, "time in milliseconds must not be filled")
+	assert.Equal(t, execTime*1000, task.ExecTimeInMs())
+}
+
+func TestTaskManager_ChangeExecTimeOnly(t *testing.T) {
+	var actualTask domain.Task
+	r := &repository.RepositoryMock{CreateMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+		actualTask = task
+		return nil
+	}}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	task := &domain.Task{}
+	task.SetExecTimeMs(util.ToMs(time.Now()) + 60250)
+	assert.NoError(t, tm.Create(context.Background(), task, false))
+
+	//	The caller who uses the seconds changes the time of the task which has the time in milliseconds
+	execTime := time.Now().Unix() + 3600
+	next := *task
+	next.Id = ""
+	next.ExecTime = execTime
+	assert.Equal(t, execTime*1000, next.ExecTimeInMs(), "the time in milliseconds of the previous second must be ignored")
+
+	assert.NoError(t, tm.Create(context.Background(), &next, false))
+	assert.Equal(t, execTime, actualTask.ExecTime)
+	assert.Equal(t, execTime*1000, actualTask.ExecTimeInMs(), "the task must be created at the time in seconds")
+	assert.Equal(t, int64(0), actualTask.ExecTimeMs, "the previous time in milliseconds must be cleared")
+}
+
+func TestTaskManager_GetAndList(t *testing.T) {
+	task := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix()}
+	var actualFilter contracts.TaskFilter
+	r := &repository.RepositoryMock{
+		GetMock: func(ctx context.Context, taskId string) (domain.Task, error) {
+			switch taskId {
+			case task.Id:
+				return task, nil
+			case "failed":
+				return domain.Task{}, contracts.RepoErrorGettingTasks
+			}
+			return domain.Task{}, contracts.RepoErrorTaskNotFound
+		},
+		ListMock: func(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+			actualFilter = filter
+			if filter.Offset > 0 {
+				return nil, contracts.RepoErrorGettingTasks
+			}
+			return []domain.Task{task}, nil
+		},
+	}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	actualTask, err := tm.Get(context.Background(), task.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, task, actualTask)
+
+	_, err = tm.Get(context.Background(), util.NewId())
+	assert.Equal(t, contracts.TmErrorTaskNotFound, err)
+
+	_, err = tm.Get(context.Background(), "failed")
+	assert.Equal(t, contracts.TmErrorFindingTasks, err)
+
+	tasks, err := tm.List(context.Background(), contracts.TaskFilter{TakenBy: "instance"})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Task{task}, tasks)
+	assert.Equal(t, contracts.TaskFilter{TakenBy: "instance", Limit: 100}, actualFilter, "the limit must be set by default")
+
+	_, err = tm.List(context.Background(), contracts.TaskFilter{Limit: 10, Offset: 10})
+	assert.Equal(t, contracts.TmErrorFindingTasks, err)
+}
+
+func TestTaskManager_Reschedule(t *testing.T) {
+	stored := domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix() + 3600, Payload: []byte("payload")}
+	var actualTask domain.Task
+	var actualIsTaken bool
+	repoErrors := []error{contracts.RepoErrorDeadlock, nil}
+	r := &repository.RepositoryMock{
+		RescheduleMock: func(ctx context.Context, task domain.Task, isTaken bool) (domain.Task, error) {
+			actualTask, actualIsTaken = task, isTaken
+			err := repoErrors[0]
+			repoErrors = repoErrors[1:]
+			if err != nil {
+				return domain.Task{}, err
+			}
+
+			rescheduled := stored
+			rescheduled.ExecTime, rescheduled.ExecTimeMs = task.ExecTime, task.ExecTimeMs
+			return rescheduled, nil
+		},
+	}
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{}, nil)
+
+	now := time.Now().Unix()
+	task := domain.Task{Id: stored.Id, ExecTime: now - 10}
+	assert.NoError(t, tm.Reschedule(context.Background(), &task, true), "the deadlock must be retried")
+	assert.GreaterOrEqual(t, actualTask.ExecTime, now, "the time in the past must be replaced by the current time")
+	assert.True(t, actualIsTaken)
+	assert.Equal(t, []byte("payload"), task.Payload, "the task must be filled from the stored task")
+	assert.Equal(t, actualTask.ExecTime*1000, task.ExecTimeInMs())
+
+	repoErrors = []error{contracts.RepoErrorTaskNotFound}
+	assert.Equal(t, contracts.TmErrorTaskNotFound, tm.Reschedule(context.Background(), &domain.Task{Id: util.NewId()}, false))
+
+	repoErrors = []error{contracts.RepoErrorRescheduling}
+	assert.Equal(t, contracts.TmErrorReschedulingTask, tm.Reschedule(context.Background(), &task, false))
+}
+
+func TestTaskManager_CreateBatch(t *testing.T) {
+	var actualTasks []domain.Task
+	repoErrors := []error{contracts.RepoErrorDeadlock, nil}
+	r := &repository.RepositoryMock{
+		CreateBatchMock: func(ctx context.Context, tasks []domain.Task, isTaken bool) ([]error, error) {
+			actualTasks = tasks
+			err := repoErrors[0]
+			repoErrors = repoErrors[1:]
+			if err != nil {
+				return nil, err
+			}
+
+			results := make([]error, len(tasks))
+			results[0] = contracts.RepoErrorTaskExist
+			return results, nil
+		},
+	}
+
+	var published int64
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{
+		PublishMock: func(topic contracts.Topic, measurement int64) error {
+			published += measurement
+			return nil
+		},
+	}, &Options{MaxPayloadSize: 16})
+
+	existingId := util.NewId()
+	tasks := []domain.Task{
+		{Id: existingId},
+		{Id: "invalid"},
+		{Payload: make([]byte, 17)},
+		{ExecTime: time.Now().Unix() + 60},
+	}
+	results, err := tm.CreateBatch(context.Background(), tasks, false)
+	assert.NoError(t, err, "the deadlock must be retried")
+	assert.Equal(t, []error{
+		contracts.TmErrorTaskExist,
+		contracts.TmErrorUuidIsNotCorrect,
+		contracts.TmErrorPayloadTooLarge,
+		nil,
+	}, results)
+	assert.Len(t, actualTasks, 2, "the invalid tasks must not be sent to the repository")
+	assert.Equal(t, existingId, actualTasks[0].Id)
+	assert.NotEmpty(t, tasks[3].Id, "the id must be filled")
+	assert.Equal(t, tasks[3], actualTasks[1])
+	assert.Equal(t, int64(1), published)
+
+	repoErrors = []error{errors.New("some error")}
+	results, err = tm.CreateBatch(context.Background(), []domain.Task{{}}, false)
+	assert.Nil(t, results)
+	assert.Equal(t, contracts.TmErrorCreatingTasks, err)
+}
+
+func TestTaskManager_DeleteBatch(t *testing.T) {
+	repoErrors := []error{contracts.RepoErrorDeadlock, nil}
+	r := &repository.RepositoryMock{
+		DeleteBatchMock: func(ctx context.Context, taskIds []string) ([]error, error) {
+			err := repoErrors[0]
+			repoErrors = repoErrors[1:]
+			if err != nil {
+				return nil, err
+			}
+
+			return []error{nil, contracts.RepoErrorTaskNotFound, nil}, nil
+		},
+	}
+
+	var published int64
+	tm := New(r, &error_service.ErrorHandlerMock{}, &monitoring_service.MonitoringMock{
+		PublishMock: func(topic contracts.Topic, measurement int64) error {
+			published += measurement
+			return nil
+		},
+	}, nil)
+
+	results, err := tm.DeleteBatch(context.Background(), []string{util.NewId(), util.NewId(), util.NewId()})
+	assert.NoError(t, err, "the deadlock must be retried")
+	assert.Equal(t, []error{nil, contracts.TmErrorTaskNotFound, nil}, results)
+	assert.Equal(t, int64(-2), published)
+
+	repoErrors = []error{errors.New("some error")}
+	results, err = tm.DeleteBatch(context.Background(), []string{util.NewId()})
+	assert.Nil(t, results)
+	assert.Equal(t, contracts.TmErrorDeletingTask, err)
+}
+
+func TestTaskManager_MoveToDeadLetter(t *testing.T) {
+	tests := []struct {
+		name                        string
+		inputErrorRepository        []error
+		expectedError               error
+		expectedAll                 int64
+		countCallMethodOfRepository int
+	}{
+		{
+			name:                        "main flow - without error",
+			inputErrorRepository:        []error{nil},
+			expectedError:               nil,
+			expectedAll:                 -1,
+			countCallMethodOfRepository: 1,
+		},
+		{
+			name:                        "retryable error",
+			inputErrorRepository:        []error{contracts.RepoErrorDeadlock, nil},
+			expectedError:               nil,
+			expectedAll:                 -1,
+			countCallMethodOfRepository: 2,
+		},
+		{
+			name:                        "the task was deleted",
+			inputErrorRepository:        []error{contracts.RepoErrorTaskNotFound},
+			expectedError:               contracts.TmErrorTaskNotFound,
+			countCallMethodOfRepository: 1,
+		},
+		{
+			name:                        "not retryable error",
+			inputErrorRepository:        []error{contracts.RepoErrorMovingTask},
+			expectedError:               contracts.TmErrorMovingToDeadLetter,
+			countCallMethodOfRepository: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputTask := domain.Task{Id: util.NewId(), Attempts: 3}
+
+			countCallMethodOfRepository := 0
+			r := &repository.RepositoryMock{MoveToDeadLetterMock: func(ctx context.Context, task domain.Task, reason string) error {
+				assert.Equal(t, inputTask, task)
+				assert.Equal(t, "reason", reason)
+				err := test.inputErrorRepository[countCallMethodOfRepository]
+				countCallMethodOfRepository++
+
+				return err
+			}}
+
+			var actualAll int64
+			m := &monitoring_service.MonitoringMock{PublishMock: func(topic contracts.Topic, measurement int64) error {
+				if topic == contracts.All {
+					actualAll += measurement
+				}
+				return nil
+			}}
+
+			tm := New(r, &error_service.ErrorHandlerMock{}, m, nil)
+
+			err := tm.MoveToDeadLetter(context.Background(), inputTask, "reason")
+
+			assert.Equal(t, test.expectedError, err, "error from task manager is not correct")
+			assert.Equal(t, test.countCallMethodOfRepository, countCallMethodOfRepository,
+				"is not correct call method of repository")
+			assert.Equal(t, test.expectedAll, actualAll, "count of all tasks is not correct")
+		})
+	}
+}
+
+func TestTaskManager_RequeueDeadLetter(t *testing.T) {
+	tests := []struct {
+		name                        string
+		inputErrorRepository        []error
+		expectedError               error
+		expectedAll                 int64
+		countCallMethodOfRepository int
+	}{
+		{
+			name:                        "main flow - without error",
+			inputErrorRepository:        []error{nil},
+			expectedError:               nil,
+			expectedAll:                 1,
+			countCallMethodOfRepository: 1,
+		},
+		{
+			name:                        "retryable error",
+			inputErrorRepository:        []error{contracts.RepoErrorDeadlock, nil},
+			expectedError:               nil,
+			expectedAll:                 1,
+			countCallMethodOfRepository: 2,
+		},
+		{
+			name:                        "the task is not dead lettered",
+			inputErrorRepository:        []error{contracts.RepoErrorTaskNotFound},
+			expectedError:               contracts.TmErrorTaskNotFound,
+			countCallMethodOfRepository: 1,
+		},
+		{
+			name:                        "the task already exists",
+			inputErrorRepository:        []error{contracts.RepoErrorTaskExist},
+			expectedError:               contracts.TmErrorTaskExist,
+			countCallMethodOfRepository: 1,
+		},
+		{
+			name:                        "not retryable error",
+			inputErrorRepository:        []error{contracts.RepoErrorRequeueTask},
+			expectedError:               contracts.TmErrorRequeueTask,
+			countCallMethodOfRepository: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputTask := &domain.Task{Id: util.NewId(), ExecTime: 1, Attempts: 3}
+
+			countCallMethodOfRepository := 0
+			r := &repository.RepositoryMock{RequeueMock: func(ctx context.Context, task domain.Task, isTaken bool) error {
+				assert.Equal(t, inputTask.Id, task.Id)
+				assert.Equal(t, 0, task.Attempts, "count of attempts must be reset")
+				assert.LessOrEqual(t, time.Now().Unix()-1, task.ExecTime, "time of execution in the past must be corrected")
+				assert.True(t, isTaken)
+				err := test.inputErrorRepository[countCallMethodOfRepository]
+				countCallMethodOfRepository++
+
+				return err
+			}}
+
+			var actualAll int64
+			m := &monitoring_service.MonitoringMock{PublishMock: func(topic contracts.Topic, measurement int64) error {
+				if topic == contracts.All {
+					actualAll += measurement
+				}
+				return nil
+			}}
+
+			tm := New(r, &error_service.ErrorHandlerMock{}, m, nil)
+
+			err := tm.RequeueDeadLetter(context.Background(), inputTask, true)
+
+			assert.Equal(t, test.expectedError, err, "error from task manager is not correct")
+			assert.Equal(t, test.countCallMethodOfRepository, countCallMethodOfRepository,
+				"is not correct call method of repository")
+			assert.Equal(t, test.expectedAll, actualAll, "count of all tasks is not correct")
+		})
+	}
+}
+
 func TestTaskManagerMock_GetTasksToComplete(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -270,7 +820,7 @@ func TestTaskManagerMock_GetTasksToComplete(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 
 			countCallMethodOfRepository := 0
-			r := &repository.RepositoryMock{FindBySecToExecTimeMock: func(ctx context.Context, preloadingTimeRange time.Duration) (
+			r := &repository.RepositoryMock{FindBySecToExecTimeMock: func(ctx context.Context, preloadingTimeRange time.Duration, queues []string) (
 				collection contracts.CollectionsInterface,
 				err error,
 			) {
@@ -290,7 +840,7 @@ func TestTaskManagerMock_GetTasksToComplete(t *testing.T) {
 
 			tm := New(r, eh, &monitoring_service.MonitoringMock{}, nil)
 
-			result, err := tm.GetTasksToComplete(context.Background(), time.Second)
+			result, err := tm.GetTasksToComplete(context.Background(), time.Second, nil)
 
 			assert.Equal(t, test.expectedResult, result, "result from task manager is not correct")
 			assert.Equal(t, test.expectedError, err, "error from task manager is not correct")
@@ -2,33 +2,65 @@ package triggerhook
 
 import (
 	"context"
+	"sync"
+	"time"
+
 	"github.com/pvelx/triggerhook/contracts"
 	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
 )
 
+/*
+	The time of releasing the tasks and the collections when the context of stopping is done
+*/
+const releaseTimeout = 5 * time.Second
+
 func New(
 	eventHandler contracts.EventHandlerInterface,
 	waitingService contracts.WaitingServiceInterface,
 	preloadingService contracts.PreloadingServiceInterface,
 	senderService contracts.SenderServiceInterface,
 	monitoringService contracts.MonitoringInterface,
+	taskManager contracts.TaskManagerInterface,
 ) contracts.TriggerHookInterface {
 
+	handlersCtx, stopHandlers := context.WithCancel(context.Background())
+
 	return &triggerHook{
 		eventHandler:      eventHandler,
 		waitingService:    waitingService,
 		preloadingService: preloadingService,
 		senderService:     senderService,
 		monitoringService: monitoringService,
+		taskManager:       taskManager,
+		handlersCtx:       handlersCtx,
+		stopHandlers:      stopHandlers,
+		stopped:           make(chan struct{}),
 	}
 }
 
 type triggerHook struct {
+	sync.Mutex
 	waitingService    contracts.WaitingServiceInterface
 	preloadingService contracts.PreloadingServiceInterface
 	senderService     contracts.SenderServiceInterface
 	eventHandler      contracts.EventHandlerInterface
 	monitoringService contracts.MonitoringInterface
+	taskManager       contracts.TaskManagerInterface
+	isRunning         bool
+	isStopped         bool
+
+	/*
+		Workers of the handlers. The workers do not consume the tasks after handlersCtx is canceled
+	*/
+	handlers     sync.WaitGroup
+	handlersCtx  context.Context
+	stopHandlers context.CancelFunc
+
+	/*
+		Closed when the stopping is finished
+	*/
+	stopped chan struct{}
 }
 
 // Deprecated
@@ -49,15 +81,209 @@ func (s *triggerHook) CreateCtx(ctx context.Context, task *domain.Task) error {
 	return s.preloadingService.AddNewTask(ctx, task)
 }
 
+func (s *triggerHook) CreateBatch(ctx context.Context, tasks []domain.Task) ([]error, error) {
+	return s.preloadingService.AddNewTasks(ctx, tasks)
+}
+
+func (s *triggerHook) DeleteBatch(ctx context.Context, taskIds []string) ([]error, error) {
+	return s.waitingService.CancelBatch(ctx, taskIds)
+}
+
 func (s *triggerHook) Consume() contracts.TaskToSendInterface {
-	return s.senderService.Consume()
+	return s.ConsumeQueue("")
+}
+
+func (s *triggerHook) ConsumeQueue(queue string) contracts.TaskToSendInterface {
+	if s.isFinished() {
+		return nil
+	}
+
+	return s.senderService.ConsumeQueue(queue)
+}
+
+func (s *triggerHook) ConsumeCtx(ctx context.Context) (contracts.TaskToSendInterface, error) {
+	return s.ConsumeQueueCtx(ctx, "")
+}
+
+func (s *triggerHook) ConsumeQueueCtx(ctx context.Context, queue string) (contracts.TaskToSendInterface, error) {
+	if s.isFinished() {
+		return nil, contracts.ErrStopped
+	}
+
+	return s.senderService.ConsumeCtx(ctx, queue)
+}
+
+func (s *triggerHook) ConsumeBatch(ctx context.Context, max int) ([]contracts.TaskToSendInterface, error) {
+	return s.ConsumeQueueBatch(ctx, "", max)
+}
+
+func (s *triggerHook) ConsumeQueueBatch(ctx context.Context, queue string, max int) ([]contracts.TaskToSendInterface, error) {
+	if s.isFinished() {
+		return nil, contracts.ErrStopped
+	}
+
+	return s.senderService.ConsumeBatch(ctx, queue, max)
+}
+
+func (s *triggerHook) isFinished() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.isStopped && !s.isRunning
+}
+
+func (s *triggerHook) Subscribe(topic contracts.Topic, callback func(event contracts.MeasurementEvent)) func() {
+	return s.monitoringService.Subscribe(topic, callback)
+}
+
+func (s *triggerHook) Get(ctx context.Context, taskId string) (domain.Task, error) {
+	return s.taskManager.Get(ctx, taskId)
+}
+
+func (s *triggerHook) List(ctx context.Context, filter contracts.TaskFilter) ([]domain.Task, error) {
+	return s.taskManager.List(ctx, filter)
+}
+
+func (s *triggerHook) Reschedule(ctx context.Context, taskId string, execTime time.Time) error {
+	task := domain.Task{Id: taskId}
+	task.SetExecTimeMs(util.ToMs(execTime))
+
+	isTaken, err := s.preloadingService.Reschedule(ctx, &task)
+	if err != nil {
+		return err
+	}
+
+	s.waitingService.Reschedule(task, isTaken)
+
+	return nil
+}
+
+func (s *triggerHook) GetDeadLetters(ctx context.Context, limit int, offset int) ([]domain.DeadLetter, error) {
+	return s.taskManager.GetDeadLetters(ctx, limit, offset)
+}
+
+func (s *triggerHook) GetDeadLetter(ctx context.Context, taskId string) (domain.DeadLetter, error) {
+	return s.taskManager.GetDeadLetter(ctx, taskId)
+}
+
+func (s *triggerHook) RequeueDeadLetter(ctx context.Context, taskId string, execTime time.Time) error {
+	deadLetter, err := s.taskManager.GetDeadLetter(ctx, taskId)
+	if err != nil {
+		return err
+	}
+
+	task := deadLetter.Task
+	task.SetExecTimeMs(util.ToMs(execTime))
+
+	//	The new time of execution is the time of the occurrence
+	if task.Recurrence != nil {
+		recurrence := *task.Recurrence
+		recurrence.ScheduledTimeMs = 0
+		task.Recurrence = &recurrence
+	}
+
+	return s.preloadingService.RequeueDeadLetter(ctx, &task)
+}
+
+func (s *triggerHook) PurgeDeadLetters(ctx context.Context, before time.Time) (int64, error) {
+	return s.taskManager.PurgeDeadLetters(ctx, before)
 }
 
 func (s *triggerHook) Run() error {
+	s.Lock()
+	if s.isStopped {
+		s.Unlock()
+
+		return contracts.ErrStopped
+	}
+	s.isRunning = true
+	s.Unlock()
+
 	go s.preloadingService.Run()
 	go s.waitingService.Run()
 	go s.senderService.Run()
 	go s.monitoringService.Run()
 
-	return s.eventHandler.Run()
+	eventHandlerErr := make(chan error, 1)
+	go func() {
+		eventHandlerErr <- s.eventHandler.Run()
+	}()
+
+	select {
+	case err := <-eventHandlerErr:
+		return err
+	case <-s.stopped:
+		return nil
+	}
+}
+
+/*
+	The services are stopped in the order of the movement of the tasks,
+	so the tasks are not sent to the stopped service
+*/
+func (s *triggerHook) Stop(ctx context.Context) error {
+	s.Lock()
+	if s.isStopped {
+		s.Unlock()
+
+		return contracts.ErrStopped
+	}
+	s.isStopped = true
+	isRunning := s.isRunning
+	s.Unlock()
+
+	defer close(s.stopped)
+	defer s.monitoringService.Stop()
+
+	s.stopHandlers()
+
+	if !isRunning {
+		return nil
+	}
+
+	errStopping := s.preloadingService.Stop(ctx)
+
+	//	The tasks being executed by the handlers are finished before the stopping of the sender,
+	//	so they are confirmed or rolled back while the sender is working
+	if err := s.waitHandlers(ctx); err != nil && errStopping == nil {
+		errStopping = err
+	}
+
+	if err := s.senderService.Stop(ctx); err != nil && errStopping == nil {
+		errStopping = err
+	}
+
+	//	The tasks and the collections must be released even when the context is done,
+	//	otherwise they stay taken by the instance until the heartbeat expires
+	if ctx.Err() != nil {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(context.Background(), releaseTimeout)
+		defer stop()
+	}
+
+	if err := s.waitingService.Stop(ctx); err != nil && errStopping == nil {
+		errStopping = err
+	}
+
+	//	The collections which are left taken, for example the empty ones, are released too
+	if err := s.taskManager.Unregister(ctx); err != nil && errStopping == nil {
+		errStopping = err
+	}
+
+	return errStopping
+}
+
+func (s *triggerHook) waitHandlers(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -2,20 +2,487 @@ package triggerhook
 
 import (
 	"context"
+	"errors"
 	"log"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/pvelx/triggerhook/connection"
+	"github.com/pvelx/triggerhook/contracts"
 	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/error_service"
+	"github.com/pvelx/triggerhook/sender_service"
+	"github.com/pvelx/triggerhook/waiting_service"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestExample(t *testing.T) {
 	clear()
 
+	testExample(t, Config{
+		/*
+			If you want to use the access parameters that are not default:
+
+			Connection: connection.Options{
+				User:     "user",
+				Password: "secret",
+				Host:     "127.0.0.1:3306",
+				DbName:   "task",
+			},
+		*/
+	})
+}
+
+// The whole pipeline without a database
+func TestExampleInMemory(t *testing.T) {
+	testExample(t, Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+}
+
+func TestRescheduleInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	task := domain.Task{ExecTime: time.Now().Add(time.Second).Unix()}
+	assert.NoError(t, triggerHook.CreateCtx(context.Background(), &task))
+
+	//	The task waiting in the memory is removed, then it is added again with the new time
+	assert.NoError(t, triggerHook.Reschedule(context.Background(), task.Id, time.Now().Add(time.Hour)))
+	execTime := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	assert.NoError(t, triggerHook.Reschedule(context.Background(), task.Id, execTime))
+	assert.Equal(t, contracts.TmErrorTaskNotFound,
+		triggerHook.Reschedule(context.Background(), "6ba7b810-9dad-11d1-80b4-00c04fd430c8", execTime))
+
+	result := triggerHook.Consume()
+	assert.Equal(t, task.Id, result.Task().Id)
+	assert.Equal(t, execTime.Unix(), result.Task().ExecTime, "the task must be sent at the new time")
+	assert.False(t, time.Now().Before(execTime), "the task must not be sent at the previous time")
+	result.Confirm()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+}
+
+func TestBatchInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	now := time.Now().Unix()
+	tasks := []domain.Task{
+		{ExecTime: now},
+		{ExecTime: now + 2},
+		{ExecTime: now + 3600},
+	}
+	results, err := triggerHook.CreateBatch(context.Background(), tasks)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, nil, nil}, results)
+
+	results, err = triggerHook.CreateBatch(context.Background(), []domain.Task{tasks[2], {Id: "invalid"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{contracts.TmErrorTaskExist, contracts.TmErrorUuidIsNotCorrect}, results)
+
+	//	The task waiting in the memory and the task in the repository are deleted
+	results, err = triggerHook.DeleteBatch(context.Background(), []string{tasks[1].Id, tasks[2].Id, tasks[1].Id})
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, nil, contracts.TmErrorTaskNotFound}, results)
+
+	result := triggerHook.Consume()
+	assert.Equal(t, tasks[0].Id, result.Task().Id)
+	result.Confirm()
+
+	time.Sleep(3 * time.Second)
+	listed, err := triggerHook.List(context.Background(), contracts.TaskFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, listed, "the deleted and the confirmed tasks must be removed from the repository")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+}
+
+func TestQueuesInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	now := time.Now().Unix()
+	email := &domain.Task{ExecTime: now, Queue: "emails"}
+	sms := &domain.Task{ExecTime: now + 1, Queue: "sms"}
+	assert.NoError(t, triggerHook.Create(email))
+	assert.NoError(t, triggerHook.Create(sms))
+
+	//	The task of the emails queue is not consumed and does not block the task of the sms queue
+	result := triggerHook.ConsumeQueue("sms")
+	assert.Equal(t, sms.Id, result.Task().Id)
+	assert.Equal(t, "sms", result.Task().Queue)
+	result.Confirm()
+
+	result = triggerHook.ConsumeQueue("emails")
+	assert.Equal(t, email.Id, result.Task().Id)
+	result.Confirm()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+}
+
+func TestPriorityInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	execTime := time.Now().Unix() + 2
+	marketing := &domain.Task{ExecTime: execTime, Priority: -1}
+	reminder := &domain.Task{ExecTime: execTime}
+	payment := &domain.Task{ExecTime: execTime, Priority: 10}
+	for _, task := range []*domain.Task{marketing, reminder, payment} {
+		assert.NoError(t, triggerHook.Create(task))
+	}
+
+	for _, expected := range []*domain.Task{payment, reminder, marketing} {
+		result := triggerHook.Consume()
+		assert.Equal(t, expected.Id, result.Task().Id, "the tasks must be sent in the order of the priority")
+		result.Confirm()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+}
+
+func TestHandleInMemory(t *testing.T) {
+	panics := make(chan contracts.EventError, 1)
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+		SenderServiceOptions: sender_service.Options{BackoffInitialDelay: 100 * time.Millisecond},
+		ErrorServiceOptions: error_service.Options{EventHandlers: map[contracts.Level]func(event contracts.EventError){
+			contracts.LevelError: func(event contracts.EventError) {
+				panics <- event
+			},
+		}},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	now := time.Now().Unix()
+	succeeded := &domain.Task{ExecTime: now}
+	failed := &domain.Task{ExecTime: now}
+	panicked := &domain.Task{ExecTime: now}
+	for _, task := range []*domain.Task{succeeded, failed, panicked} {
+		assert.NoError(t, triggerHook.Create(task))
+	}
+
+	mu := sync.Mutex{}
+	calls := make(map[string]int)
+	confirmed := make(chan string, 3)
+	assert.NoError(t, triggerHook.Handle(func(ctx context.Context, task domain.Task) error {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok, "the context must have the deadline")
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 100*time.Millisecond)
+
+		mu.Lock()
+		calls[task.Id]++
+		isFirstCall := calls[task.Id] == 1
+		mu.Unlock()
+
+		switch {
+		case task.Id == failed.Id && isFirstCall:
+			return errors.New("failed")
+		case task.Id == panicked.Id && isFirstCall:
+			panic("panicked")
+		}
+		confirmed <- task.Id
+
+		return nil
+	}, &contracts.HandlerOptions{WorkersCount: 2, Timeout: time.Second}))
+
+	var actual []string
+	for i := 0; i < 3; i++ {
+		select {
+		case taskId := <-confirmed:
+			actual = append(actual, taskId)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "the tasks are not handled")
+		}
+	}
+	assert.ElementsMatch(t, []string{succeeded.Id, failed.Id, panicked.Id}, actual,
+		"the failed tasks must be rolled back and handled again")
+
+	event := <-panics
+	assert.Equal(t, "panic in the handler: panicked", event.EventMessage)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+	assert.Equal(t, contracts.ErrStopped, triggerHook.Handle(func(ctx context.Context, task domain.Task) error {
+		return nil
+	}, nil))
+}
+
+func TestHandleStopInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	started := make(chan struct{})
+	var isFinished int32
+	assert.NoError(t, triggerHook.Handle(func(ctx context.Context, task domain.Task) error {
+		close(started)
+		time.Sleep(500 * time.Millisecond)
+		atomic.StoreInt32(&isFinished, 1)
+
+		return nil
+	}, nil))
+
+	task := &domain.Task{ExecTime: time.Now().Unix()}
+	assert.NoError(t, triggerHook.Create(task))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&isFinished), "the task being executed must be finished before stopping")
+
+	listed, err := triggerHook.List(context.Background(), contracts.TaskFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, listed, "the finished task must be confirmed")
+}
+
+func TestConfirmDuringStopInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	task := &domain.Task{ExecTime: time.Now().Unix()}
+	assert.NoError(t, triggerHook.Create(task))
+	taskToSend := triggerHook.Consume()
+	assert.Equal(t, task.Id, taskToSend.Task().Id)
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- triggerHook.Stop(ctx)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("the sent task must be waited for")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	taskToSend.Confirm()
+	assert.NoError(t, <-stopped)
+	assert.Nil(t, triggerHook.Consume(), "the task must not be consumed after stopping")
+
+	listed, err := triggerHook.List(context.Background(), contracts.TaskFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, listed, "the task confirmed during stopping must be deleted")
+}
+
+func TestRecurrentTaskAfterBackoffInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+		SenderServiceOptions: sender_service.Options{BackoffInitialDelay: time.Second},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	task := &domain.Task{ExecTime: time.Now().Unix(), Recurrence: &domain.Recurrence{Interval: 60}}
+	assert.NoError(t, triggerHook.Create(task))
+
+	triggerHook.Consume().RollbackWithBackoff()
+	retried := triggerHook.Consume()
+	assert.Greater(t, retried.Task().ExecTime, task.ExecTime, "the task must be delayed")
+	retried.Confirm()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+
+	listed, err := triggerHook.List(context.Background(), contracts.TaskFilter{Limit: 10})
+	assert.NoError(t, err)
+	if assert.Len(t, listed, 1) {
+		assert.Equal(t, task.ExecTime+60, listed[0].ExecTime, "the next occurrence must not be moved by the delay")
+	}
+}
+
+func TestConsumeBatchInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+		WaitingServiceOptions: waiting_service.Options{ReadyToSendBuffer: 10},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := triggerHook.ConsumeCtx(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err, "the consuming must be canceled by the context")
+
+	tasks := make([]domain.Task, 25)
+	for i := range tasks {
+		tasks[i].ExecTime = time.Now().Unix()
+	}
+	_, err = triggerHook.CreateBatch(context.Background(), tasks)
+	assert.NoError(t, err)
+
+	consumed := 0
+	for consumed < len(tasks) {
+		batch, err := triggerHook.ConsumeBatch(context.Background(), 10)
+		assert.NoError(t, err)
+		assert.True(t, len(batch) >= 1 && len(batch) <= 10, "the batch must contain from 1 to max tasks")
+		for _, taskToSend := range batch {
+			taskToSend.Confirm()
+		}
+		consumed += len(batch)
+	}
+	assert.Equal(t, len(tasks), consumed)
+
+	emails := make([]domain.Task, 3)
+	for i := range emails {
+		emails[i].ExecTime = time.Now().Unix()
+		emails[i].Queue = "emails"
+	}
+	_, err = triggerHook.CreateBatch(context.Background(), emails)
+	assert.NoError(t, err)
+
+	taskToSend, err := triggerHook.ConsumeQueueCtx(context.Background(), "emails")
+	assert.NoError(t, err)
+	assert.Equal(t, "emails", taskToSend.Task().Queue)
+	taskToSend.Confirm()
+
+	consumed = 0
+	for consumed < len(emails)-1 {
+		batch, err := triggerHook.ConsumeQueueBatch(context.Background(), "emails", 10)
+		assert.NoError(t, err)
+		for _, taskToSend := range batch {
+			assert.Equal(t, "emails", taskToSend.Task().Queue)
+			taskToSend.Confirm()
+		}
+		consumed += len(batch)
+	}
+	assert.Equal(t, len(emails)-1, consumed)
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stopCancel()
+	assert.NoError(t, triggerHook.Stop(stopCtx))
+
+	_, err = triggerHook.ConsumeBatch(context.Background(), 10)
+	assert.Equal(t, contracts.ErrStopped, err)
+}
+
+func TestIdempotencyKeyInMemory(t *testing.T) {
+	triggerHook := Build(Config{
+		Connection: connection.Options{
+			Driver: connection.Memory,
+		},
+	})
+
+	go func() {
+		if err := triggerHook.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	task := &domain.Task{ExecTime: time.Now().Unix(), IdempotencyKey: "order-1"}
+	assert.NoError(t, triggerHook.CreateCtx(context.Background(), task))
+
+	duplicate := &domain.Task{ExecTime: time.Now().Unix() + 60, IdempotencyKey: "order-1"}
+	assert.Equal(t, contracts.TmErrorIdempotencyKeyExist, triggerHook.CreateCtx(context.Background(), duplicate))
+	assert.Equal(t, task.Id, duplicate.Id, "the id of the existing task must be returned")
+
+	taskToSend := triggerHook.Consume()
+	assert.Equal(t, task.Id, taskToSend.Task().Id)
+	assert.Equal(t, "order-1", taskToSend.Task().IdempotencyKey)
+	taskToSend.Confirm()
+
+	duplicate = &domain.Task{ExecTime: time.Now().Unix(), IdempotencyKey: "order-1"}
+	assert.Equal(t, contracts.TmErrorIdempotencyKeyExist, triggerHook.CreateCtx(context.Background(), duplicate),
+		"the key must be retained after the execution")
+	assert.Equal(t, task.Id, duplicate.Id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx))
+}
+
+func testExample(t *testing.T, config Config) {
 	inputData := []struct {
 		tasksCount       int32
 		relativeExecTime time.Duration
@@ -39,22 +506,14 @@ func TestExample(t *testing.T) {
 	}
 
 	var actualAllTasksCount int32
-	triggerHook := Build(Config{
-		/*
-			If you want to use the access parameters that are not default:
-
-			Connection: connection.Options{
-				User:     "user",
-				Password: "secret",
-				Host:     "127.0.0.1:3306",
-				DbName:   "task",
-			},
-		*/
-	})
+	triggerHook := Build(config)
 
 	go func() {
 		for {
 			result := triggerHook.Consume()
+			if result == nil {
+				return
+			}
 			now := time.Now().Unix()
 			atomic.AddInt32(&actualAllTasksCount, 1)
 			assert.Equal(t, now, result.Task().ExecTime, "time exec of the task is not current time")
@@ -78,6 +537,12 @@ func TestExample(t *testing.T) {
 	time.Sleep(maxExecTime) // it takes time to process the most deferred tasks
 
 	assert.Equal(t, expectedAllTasksCount, atomic.LoadInt32(&actualAllTasksCount), "count tasks is not correct")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, triggerHook.Stop(ctx), "the trigger hook is not stopped gracefully")
+	assert.Equal(t, contracts.ErrStopped, triggerHook.Stop(ctx))
+	assert.Nil(t, triggerHook.Consume(), "the tasks must not be sent after stopping")
 }
 
 func clear() {
@@ -1,6 +1,10 @@
 package util
 
-import "github.com/satori/go.uuid"
+import (
+	"time"
+
+	"github.com/satori/go.uuid"
+)
 
 func NewId() string {
 	return uuid.NewV4().String()
@@ -22,3 +26,14 @@ func Contains(s []error, e error) bool {
 	}
 	return false
 }
+
+/*
+	Unix time in milliseconds
+*/
+func ToMs(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func FromMs(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
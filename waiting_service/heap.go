@@ -1,11 +1,18 @@
 package waiting_service
 
+import "github.com/pvelx/triggerhook/domain"
+
 type items []*item
 
 type item struct {
-	task     interface{}
+	task     domain.Task
 	priority int64
-	index    int
+
+	/*
+		Compared if the priorities are equal, the item with the lower tiebreaker goes first
+	*/
+	tiebreaker int64
+	index      int
 }
 
 func (items items) Len() int {
@@ -13,7 +20,11 @@ func (items items) Len() int {
 }
 
 func (items items) Less(i, j int) bool {
-	return items[i].priority < items[j].priority
+	if items[i].priority != items[j].priority {
+		return items[i].priority < items[j].priority
+	}
+
+	return items[i].tiebreaker < items[j].tiebreaker
 }
 
 func (items items) Swap(i, j int) {
@@ -0,0 +1,249 @@
+package waiting_service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/util"
+)
+
+/*
+	The tasks of the queue wait in the separate list and are sent to the separate channel,
+	so the queue which is not consumed does not delay the tasks of other queues
+*/
+type queue struct {
+	tasksWaitingList      prioritizedTaskListInterface
+	tasksReadyToSend      chan domain.Task
+	greedyProcessingLimit int
+
+	/*
+		The new tasks and the changes of the tasks wait for the queue, so the waiting service is never blocked
+		by the queue. The queue is woken up by the changed channel
+	*/
+	sync.Mutex
+	newTasks [][]domain.Task
+	changes  []change
+	changed  chan struct{}
+}
+
+/*
+	The canceled or the rescheduled task
+*/
+type change struct {
+	canceledTaskId string
+	rescheduled    *rescheduledTask
+}
+
+func newQueue(greedyProcessingLimit int, readyToSendBuffer int) *queue {
+	return &queue{
+		tasksWaitingList:      NewPrioritizedTask([]domain.Task{}),
+		tasksReadyToSend:      make(chan domain.Task, readyToSendBuffer),
+		greedyProcessingLimit: greedyProcessingLimit,
+		changed:               make(chan struct{}, 1),
+	}
+}
+
+/*
+	The tasks are added by the batches of the greedy processing, the batch must not be changed after adding
+*/
+func (q *queue) add(tasks []domain.Task) {
+	q.Lock()
+	q.newTasks = append(q.newTasks, tasks)
+	q.Unlock()
+
+	q.wakeUp()
+}
+
+func (q *queue) cancel(taskId string) {
+	q.change(change{canceledTaskId: taskId})
+}
+
+func (q *queue) reschedule(rescheduled rescheduledTask) {
+	q.change(change{rescheduled: &rescheduled})
+}
+
+func (q *queue) change(c change) {
+	q.Lock()
+	q.changes = append(q.changes, c)
+	q.Unlock()
+
+	q.wakeUp()
+}
+
+func (q *queue) wakeUp() {
+	select {
+	case q.changed <- struct{}{}:
+	default:
+	}
+}
+
+/*
+	The changes are applied to the waiting list and to the new tasks. The task taken by the queue
+	must be returned to the waiting list before. The ready tasks which are not consumed yet are returned
+	to the waiting list too, so the canceled or the rescheduled task is not sent at the previous time
+*/
+func (q *queue) applyChanges() {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.changes) == 0 {
+		return
+	}
+
+	q.returnReadyTasks()
+
+	for _, c := range q.changes {
+		taskId := c.canceledTaskId
+		if c.rescheduled != nil {
+			taskId = c.rescheduled.task.Id
+		}
+
+		q.tasksWaitingList.DeleteIfExist(taskId)
+		for i, tasks := range q.newTasks {
+			for j, task := range tasks {
+				if task.Id == taskId {
+					q.newTasks[i] = append(tasks[:j:j], tasks[j+1:]...)
+
+					break
+				}
+			}
+		}
+
+		if c.rescheduled != nil && c.rescheduled.isTaken {
+			q.tasksWaitingList.Add(c.rescheduled.task)
+		}
+	}
+	q.changes = nil
+}
+
+func (q *queue) returnReadyTasks() {
+	for {
+		select {
+		case task := <-q.tasksReadyToSend:
+			q.tasksWaitingList.Add(task)
+		default:
+			return
+		}
+	}
+}
+
+/*
+	The new tasks are added to the waiting list by the batches of the greedy processing
+*/
+func (q *queue) addNewTasks() bool {
+	q.Lock()
+	defer q.Unlock()
+
+	if len(q.newTasks) == 0 {
+		return false
+	}
+
+	for _, task := range q.newTasks[0] {
+		q.tasksWaitingList.Add(task)
+	}
+	q.newTasks[0] = nil
+	q.newTasks = q.newTasks[1:]
+
+	return true
+}
+
+/*
+	The new tasks are added while the taken task is not ready, so the waiting list stays small while the consumer
+	is late and the late tasks are sent in the order of receiving by the batches of the greedy processing
+*/
+func (q *queue) run(stopping <-chan struct{}) {
+	var task *domain.Task
+	for {
+		if task == nil {
+			task = q.tasksWaitingList.Take()
+		}
+
+		var sleep time.Duration
+		if task != nil {
+			sleep = time.Until(util.FromMs(task.ExecTimeInMs()))
+		}
+
+		if (task == nil || sleep > 0) && q.addNewTasks() {
+			//	Any of the new tasks may be executed earlier than the taken one
+			if task != nil {
+				q.tasksWaitingList.Add(*task)
+				task = nil
+			}
+
+			continue
+		}
+
+		var timer *time.Timer
+		var wakeUp <-chan time.Time
+		var readyToSend chan domain.Task
+		var readyTask domain.Task
+		if task != nil {
+			readyTask = *task
+			if sleep > 0 {
+				timer = time.NewTimer(sleep)
+				wakeUp = timer.C
+			} else {
+				readyToSend = q.tasksReadyToSend
+			}
+		}
+
+		//	The ready task is sent at once if the consumer is waiting, even if the queue is woken up
+		if readyToSend != nil {
+			select {
+			case readyToSend <- readyTask:
+				task = nil
+
+				continue
+			default:
+			}
+		}
+
+		select {
+		case readyToSend <- readyTask:
+			task = nil
+
+			continue
+		case <-wakeUp:
+
+			continue
+		case <-q.changed:
+		case <-stopping:
+		}
+
+		//	The new task may be executed earlier than the taken one or may have the higher priority,
+		//	so the taken task is returned to the waiting list before any change of the list
+		if timer != nil {
+			timer.Stop()
+		}
+		if task != nil {
+			q.tasksWaitingList.Add(*task)
+			task = nil
+		}
+		q.applyChanges()
+
+		select {
+		case <-stopping:
+			return
+		default:
+		}
+	}
+}
+
+/*
+	Takes all tasks of the queue including the ready tasks which are not consumed. Must be called after run returns
+	or when the queue is not launched
+*/
+func (q *queue) takeAll() []domain.Task {
+	q.applyChanges()
+	for q.addNewTasks() {
+	}
+	q.returnReadyTasks()
+
+	var tasks []domain.Task
+	for task := q.tasksWaitingList.Take(); task != nil; task = q.tasksWaitingList.Take() {
+		tasks = append(tasks, *task)
+	}
+
+	return tasks
+}
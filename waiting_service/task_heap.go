@@ -12,11 +12,8 @@ func NewPrioritizedTask(tasks []domain.Task) prioritizedTaskListInterface {
 	var index = make(map[string]*int)
 	i := 0
 	for _, task := range tasks {
-		item := &item{
-			task:     task,
-			priority: task.ExecTime,
-			index:    i,
-		}
+		item := newItem(task)
+		item.index = i
 		pq = append(pq, item)
 		index[task.Id] = &item.index
 		i++
@@ -26,6 +23,17 @@ func NewPrioritizedTask(tasks []domain.Task) prioritizedTaskListInterface {
 	return &heapPrioritizedTaskList{pq: pq, index: index}
 }
 
+/*
+	The tasks are ordered by the time of execution, the tasks with the same time are ordered by the priority of the task
+*/
+func newItem(task domain.Task) *item {
+	return &item{
+		task:       task,
+		priority:   task.ExecTimeInMs(),
+		tiebreaker: -int64(task.Priority),
+	}
+}
+
 type heapPrioritizedTaskList struct {
 	prioritizedTaskListInterface
 	pq    items
@@ -36,10 +44,18 @@ type heapPrioritizedTaskList struct {
 func (h *heapPrioritizedTaskList) Add(task domain.Task) {
 	h.Lock()
 	defer h.Unlock()
-	item := &item{
-		task:     task,
-		priority: task.ExecTime,
+	item := newItem(task)
+
+	//	The task with the same id is replaced and moved to the place of the new time
+	if index, ok := h.index[task.Id]; ok {
+		item.index = *index
+		h.pq[item.index] = item
+		h.index[task.Id] = &item.index
+		heap.Fix(&h.pq, item.index)
+
+		return
 	}
+
 	heap.Push(&h.pq, item)
 	h.index[task.Id] = &item.index
 }
@@ -60,10 +76,10 @@ func (h *heapPrioritizedTaskList) Take() *domain.Task {
 	h.Lock()
 	defer h.Unlock()
 	if h.pq.Len() > 0 {
-		task := heap.Pop(&h.pq).(*item).task.(domain.Task)
-		delete(h.index, task.Id)
+		item := heap.Pop(&h.pq).(*item)
+		delete(h.index, item.task.Id)
 
-		return &task
+		return &item.task
 	}
 	return nil
 }
@@ -59,3 +59,36 @@ func TestDeleteTaskFromHeap(t *testing.T) {
 	task = taskHeap.Take()
 	assert.Nil(t, task)
 }
+
+func TestPriorityOfTask(t *testing.T) {
+	marketing := domain.Task{Id: util.NewId(), ExecTime: 2, Priority: -1}
+	reminder := domain.Task{Id: util.NewId(), ExecTime: 2}
+	payment := domain.Task{Id: util.NewId(), ExecTime: 2, Priority: 10}
+	early := domain.Task{Id: util.NewId(), ExecTime: 1, Priority: -10}
+
+	taskHeap := NewPrioritizedTask([]domain.Task{marketing, reminder})
+	taskHeap.Add(payment)
+	taskHeap.Add(early)
+
+	for _, expected := range []domain.Task{early, payment, reminder, marketing} {
+		assert.Equal(t, expected, *taskHeap.Take(), "the tasks with the same time must be taken in the order of the priority")
+	}
+}
+
+func TestReplaceTaskInHeap(t *testing.T) {
+	task1 := domain.Task{Id: util.NewId(), ExecTime: 1}
+	task2 := domain.Task{Id: util.NewId(), ExecTime: 2}
+	task3 := domain.Task{Id: util.NewId(), ExecTime: 3}
+
+	taskHeap := NewPrioritizedTask([]domain.Task{task1, task2, task3})
+
+	rescheduled := task1
+	rescheduled.ExecTime = 4
+	taskHeap.Add(rescheduled)
+
+	assert.Equal(t, 3, taskHeap.Len(), "the task with the same id must be replaced")
+	for _, expected := range []domain.Task{task2, task3, rescheduled} {
+		assert.Equal(t, expected, *taskHeap.Take(), "the replaced task must be taken at the new time")
+	}
+	assert.Nil(t, taskHeap.Take())
+}
@@ -2,8 +2,8 @@ package waiting_service
 
 import (
 	"context"
-	"math"
-	"time"
+	"sort"
+	"sync"
 
 	"github.com/imdario/mergo"
 	"github.com/pvelx/triggerhook/contracts"
@@ -21,7 +21,7 @@ type prioritizedTaskListInterface interface {
 	Len() int
 
 	/*
-		Add a task to the list based on priority
+		Add a task to the list based on priority. The task with the same id is replaced
 	*/
 	Add(task domain.Task)
 
@@ -40,6 +40,13 @@ type Options struct {
 	TasksReadyToSendCap   int //Deprecated
 	CanceledTasksCap      int //Deprecated
 	GreedyProcessingLimit int
+
+	/*
+		Count of the ready tasks of each queue waiting for the consumer. The larger buffer allows ConsumeBatch
+		to take more tasks at once, but the tasks with the higher priority do not go ahead of the tasks
+		in the buffer. 1 by default
+	*/
+	ReadyToSendBuffer int
 }
 
 func New(
@@ -56,55 +63,152 @@ func New(
 
 	if err := mergo.Merge(options, Options{
 		GreedyProcessingLimit: 10,
+		ReadyToSendBuffer:     1,
 	}); err != nil {
 		panic(err)
 	}
 
-	tasksWaitingList := NewPrioritizedTask([]domain.Task{})
-
-	if err := monitoring.Listen(contracts.Preloaded, func() int64 {
-		return int64(tasksWaitingList.Len())
-	}); err != nil {
-		panic(err)
-	}
-	if err := monitoring.Init(contracts.DeletingRate, contracts.VelocityMetricType); err != nil {
-		panic(err)
-	}
-
 	service := &waitingService{
-		tasksWaitingList:      tasksWaitingList,
+		queues:                make(map[string]*queue),
 		preloadedTasks:        preloadedTasks,
 		canceledTasks:         make(chan string, 1),
-		tasksReadyToSend:      make(chan domain.Task, 1),
+		rescheduledTasks:      make(chan rescheduledTask, 1),
+		delayedTasks:          make(chan domain.Task, 1),
 		greedyProcessingLimit: options.GreedyProcessingLimit,
+		readyToSendBuffer:     options.ReadyToSendBuffer,
 		monitoring:            monitoring,
 		taskManager:           taskManager,
 		eh:                    eventHandler,
+		stopping:              make(chan struct{}),
+		done:                  make(chan struct{}),
+	}
+
+	if err := monitoring.Listen(contracts.Preloaded, service.countWaitingTasks); err != nil {
+		panic(err)
+	}
+	if err := monitoring.Init(contracts.DeletingRate, contracts.VelocityMetricType); err != nil {
+		panic(err)
 	}
 
 	return service
 }
 
+type rescheduledTask struct {
+	task    domain.Task
+	isTaken bool
+}
+
+/*
+	The service receives the tasks and sends them to the queues of the tasks, each queue waits for its tasks separately
+*/
 type waitingService struct {
-	tasksWaitingList      prioritizedTaskListInterface
+	sync.Mutex
+	queues                map[string]*queue
+	isRunning             bool
+	queuesDone            sync.WaitGroup
 	preloadedTasks        <-chan domain.Task
 	canceledTasks         chan string
-	tasksReadyToSend      chan domain.Task
+	rescheduledTasks      chan rescheduledTask
+	delayedTasks          chan domain.Task
 	greedyProcessingLimit int
+	readyToSendBuffer     int
 	monitoring            contracts.MonitoringInterface
 	taskManager           contracts.TaskManagerInterface
 	eh                    contracts.EventHandlerInterface
+
+	/*
+		stopping is closed when the service is stopped, done is closed when Run returns
+	*/
+	stopOnce sync.Once
+	stopping chan struct{}
+	done     chan struct{}
+}
+
+/*
+	The queue is created when it is used for the first time. The queue created after launching is launched at once
+*/
+func (s *waitingService) queue(name string) *queue {
+	s.Lock()
+	defer s.Unlock()
+
+	if q, ok := s.queues[name]; ok {
+		return q
+	}
+
+	q := newQueue(s.greedyProcessingLimit, s.readyToSendBuffer)
+	s.queues[name] = q
+
+	if name != "" {
+		if err := s.monitoring.Listen(contracts.QueueTopic(contracts.Preloaded, name), func() int64 {
+			return int64(q.tasksWaitingList.Len())
+		}); err != nil {
+			s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"queue": name})
+		}
+	}
+
+	if s.isRunning {
+		s.launch(q)
+	}
+
+	return q
+}
+
+func (s *waitingService) launch(q *queue) {
+	s.queuesDone.Add(1)
+	go func() {
+		defer s.queuesDone.Done()
+		q.run(s.stopping)
+	}()
+}
+
+/*
+	Queues in the order of the names
+*/
+func (s *waitingService) allQueues() []*queue {
+	s.Lock()
+	defer s.Unlock()
+
+	names := make([]string, 0, len(s.queues))
+	for name := range s.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queues := make([]*queue, 0, len(names))
+	for _, name := range names {
+		queues = append(queues, s.queues[name])
+	}
+
+	return queues
+}
+
+func (s *waitingService) countWaitingTasks() int64 {
+	var count int64
+	for _, q := range s.allQueues() {
+		count += int64(q.tasksWaitingList.Len())
+	}
+
+	return count
+}
+
+func (s *waitingService) GetReadyToSendChan(queue string) <-chan domain.Task {
+	return s.queue(queue).tasksReadyToSend
 }
 
-func (s *waitingService) GetReadyToSendChan() chan domain.Task {
-	return s.tasksReadyToSend
+func (s *waitingService) GetDelayedChan() chan<- domain.Task {
+	return s.delayedTasks
 }
 
 func (s *waitingService) CancelIfExist(ctx context.Context, taskId string) error {
 	if err := s.taskManager.Delete(ctx, taskId); err != nil {
 		return err
 	}
-	s.canceledTasks <- taskId
+
+	//	The task is not waited for after stopping, so it is enough to delete it from the database
+	select {
+	case s.canceledTasks <- taskId:
+	case <-s.done:
+	}
 
 	if err := s.monitoring.Publish(contracts.DeletingRate, 1); err != nil {
 		s.eh.New(contracts.LevelError, err.Error(), nil)
@@ -113,64 +217,144 @@ func (s *waitingService) CancelIfExist(ctx context.Context, taskId string) error
 	return nil
 }
 
+func (s *waitingService) CancelBatch(ctx context.Context, taskIds []string) ([]error, error) {
+	results, err := s.taskManager.DeleteBatch(ctx, taskIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted int64
+	for i, taskId := range taskIds {
+		if results[i] != nil {
+			continue
+		}
+		deleted++
+
+		select {
+		case s.canceledTasks <- taskId:
+		case <-s.done:
+		}
+	}
+
+	if err := s.monitoring.Publish(contracts.DeletingRate, deleted); err != nil {
+		s.eh.New(contracts.LevelError, err.Error(), nil)
+	}
+
+	return results, nil
+}
+
+/*
+	The rescheduled tasks are sent through one channel, so they are replaced in the order of rescheduling
+*/
+func (s *waitingService) Reschedule(task domain.Task, isTaken bool) {
+	select {
+	case s.rescheduledTasks <- rescheduledTask{task: task, isTaken: isTaken}:
+	case <-s.done:
+	}
+}
+
 func (s *waitingService) Run() {
-	var sleep time.Duration
-	var task *domain.Task
+	defer close(s.done)
+
+	s.Lock()
+	s.isRunning = true
+	for _, q := range s.queues {
+		s.launch(q)
+	}
+	s.Unlock()
+
 	for {
-		sleep = time.Duration(math.MaxInt64)
-		task = s.tasksWaitingList.Take()
+		select {
+		case task := <-s.preloadedTasks:
+			tasks := make([]domain.Task, 1, s.greedyProcessingLimit+1)
+			tasks[0] = task
+
+			//	The use of greedy processing allows you to reduce the number of workings of the external cycle,
+			//	reduce the number of operations
+			for i, empty := 0, false; i < s.greedyProcessingLimit && !empty; i++ {
+				select {
+				case task := <-s.preloadedTasks:
+					tasks = append(tasks, task)
+				default:
+					empty = true
+				}
+			}
+			s.addPreloaded(tasks)
+		case task := <-s.delayedTasks:
+			s.queue(task.Queue).add([]domain.Task{task})
+		case taskId := <-s.canceledTasks:
+			//	The queue of the canceled task is not known
+			for _, q := range s.allQueues() {
+				q.cancel(taskId)
+			}
+		case rescheduled := <-s.rescheduledTasks:
+			//	The tasks preloaded before the rescheduling are added to the queues first, otherwise the previous version
+			//	of the rescheduled task could be added after replacing
+			var tasks []domain.Task
+			for i := len(s.preloadedTasks); i > 0; i-- {
+				tasks = append(tasks, <-s.preloadedTasks)
+			}
+			s.addPreloaded(tasks)
 
-		if task != nil {
-			sleep = time.Duration(task.ExecTime-time.Now().Unix()) * time.Second
+			s.queue(rescheduled.task.Queue).reschedule(rescheduled)
+		case <-s.stopping:
+			s.Lock()
+			s.isRunning = false
+			s.Unlock()
+			s.queuesDone.Wait()
+
+			return
 		}
+	}
+}
 
-		if sleep > 0 {
-			t := time.NewTimer(sleep)
-			select {
-			case <-t.C:
-			case newTask := <-s.preloadedTasks:
-				t.Stop()
-				if task != nil {
-					s.tasksWaitingList.Add(*task)
-				}
-				s.tasksWaitingList.Add(newTask)
-
-				//	Adding tasks to the waiting list in this block will reduce the chance of blocking
-				//	sending tasks to tasksReadyToSend because we do this during hibernation
-				//
-				// 	The use of greedy processing allows you to reduce the number of workings of the external cycle,
-				//	reduce the number of operations
-				for i, empty := 0, false; i < s.greedyProcessingLimit && !empty; i++ {
-					select {
-					case task := <-s.preloadedTasks:
-						s.tasksWaitingList.Add(task)
-					default:
-						empty = true
-					}
-				}
+/*
+	The successive tasks of one queue are added at once
+*/
+func (s *waitingService) addPreloaded(tasks []domain.Task) {
+	for len(tasks) > 0 {
+		count := 1
+		for count < len(tasks) && tasks[count].Queue == tasks[0].Queue {
+			count++
+		}
+		s.queue(tasks[0].Queue).add(tasks[:count:count])
+		tasks = tasks[count:]
+	}
+}
 
-				continue
-			case taskId := <-s.canceledTasks:
-				t.Stop()
-				if task != nil && taskId != task.Id {
-					s.tasksWaitingList.Add(*task)
-				}
-				s.tasksWaitingList.DeleteIfExist(taskId)
-
-				//	Same as for the preloadedTasks block
-				for i, empty := 0, false; i < s.greedyProcessingLimit && !empty; i++ {
-					select {
-					case taskId := <-s.canceledTasks:
-						s.tasksWaitingList.DeleteIfExist(taskId)
-					default:
-						empty = true
-					}
-				}
+/*
+	Must be called after Run and after the stopping of the preloading and sender services,
+	otherwise new tasks can be received after releasing
+*/
+func (s *waitingService) Stop(ctx context.Context) error {
+	s.stopOnce.Do(func() {
+		close(s.stopping)
+	})
 
-				continue
-			}
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	//	Tasks which are left in the channels
+	for empty := false; !empty; {
+		select {
+		case task := <-s.preloadedTasks:
+			s.queue(task.Queue).add([]domain.Task{task})
+		case task := <-s.delayedTasks:
+			s.queue(task.Queue).add([]domain.Task{task})
+		case rescheduled := <-s.rescheduledTasks:
+			s.queue(rescheduled.task.Queue).reschedule(rescheduled)
+		default:
+			empty = true
 		}
+	}
 
-		s.tasksReadyToSend <- *task
+	var tasks []domain.Task
+	for _, q := range s.allQueues() {
+		tasks = append(tasks, q.takeAll()...)
 	}
+
+	return s.taskManager.Release(ctx, tasks)
 }
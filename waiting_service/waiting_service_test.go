@@ -44,7 +44,7 @@ func TestAddNormalTask(t *testing.T) {
 
 	var actualCountOfTasks int32
 	go func() {
-		for task := range waitingService.GetReadyToSendChan() {
+		for task := range waitingService.GetReadyToSendChan("") {
 			assert.Equal(t, time.Now().Unix(), task.ExecTime, "time execution in not equal to current time")
 			atomic.AddInt32(&actualCountOfTasks, 1)
 		}
@@ -86,7 +86,104 @@ func TestDeleteTask(t *testing.T) {
 
 	time.Sleep(time.Duration(offset+dispersion+1) * time.Second)
 
-	assert.Equal(t, 0, len(waitingService.GetReadyToSendChan()), "tasks count is not correct")
+	assert.Equal(t, 0, len(waitingService.GetReadyToSendChan("")), "tasks count is not correct")
+}
+
+func TestRescheduleTask(t *testing.T) {
+	inputCountOfTasks := 1000
+	preloadedTask := make(chan domain.Task, 1)
+
+	waitingService := instanceOfWaitingService(preloadedTask)
+
+	go waitingService.Run()
+
+	now := util.ToMs(time.Now())
+	expected := make(map[string]int64)
+	for i := 0; i < inputCountOfTasks; i++ {
+		task := domain.Task{Id: util.NewId()}
+		task.SetExecTimeMs(now + 100)
+		preloadedTask <- task
+
+		//	The task is released, then it is taken again with the new time
+		postponed := task
+		postponed.SetExecTimeMs(now + 3600*1000)
+		waitingService.Reschedule(postponed, false)
+
+		if i%2 == 0 {
+			rescheduled := task
+			rescheduled.SetExecTimeMs(now + 300)
+			waitingService.Reschedule(rescheduled, true)
+			expected[task.Id] = rescheduled.ExecTimeMs
+		}
+	}
+
+	actual := make(map[string]int64)
+	timeout := time.After(time.Second)
+	for len(actual) < len(expected) {
+		select {
+		case task := <-waitingService.GetReadyToSendChan(""):
+			assert.GreaterOrEqual(t, util.ToMs(time.Now()), task.ExecTimeMs, "the task must not be sent earlier")
+			actual[task.Id] = task.ExecTimeMs
+		case <-timeout:
+			assert.Fail(t, "the rescheduled tasks are not sent")
+			return
+		}
+	}
+
+	assert.Equal(t, expected, actual, "only the tasks taken again must be sent at the new time")
+	assert.Len(t, waitingService.GetReadyToSendChan(""), 0, "tasks count is not correct")
+}
+
+func TestReschedulePreloadedTask(t *testing.T) {
+	tests := []struct {
+		name     string
+		execTime time.Duration
+	}{
+		{"waiting task", time.Hour},
+		{"ready task", -time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			preloadedTask := make(chan domain.Task, 1)
+			waitingService := New(
+				preloadedTask,
+				&monitoring_service.MonitoringMock{},
+				&task_manager.TaskManagerMock{},
+				nil,
+				&Options{ReadyToSendBuffer: 5},
+			)
+			go waitingService.Run()
+
+			task := domain.Task{Id: util.NewId()}
+			task.SetExecTimeMs(util.ToMs(time.Now().Add(test.execTime)))
+			preloadedTask <- task
+
+			//	The task is in the waiting list or in the buffer of the ready tasks
+			time.Sleep(100 * time.Millisecond)
+
+			rescheduled := task
+			rescheduled.SetExecTimeMs(util.ToMs(time.Now().Add(300 * time.Millisecond)))
+			waitingService.Reschedule(rescheduled, true)
+
+			//	The task is replaced asynchronously
+			time.Sleep(100 * time.Millisecond)
+
+			select {
+			case actual := <-waitingService.GetReadyToSendChan(""):
+				assert.Equal(t, rescheduled, actual, "the task must be sent at the new time")
+				assert.GreaterOrEqual(t, util.ToMs(time.Now()), actual.ExecTimeMs, "the task must not be sent earlier")
+			case <-time.After(time.Second):
+				assert.Fail(t, "the rescheduled task is not sent")
+			}
+
+			select {
+			case actual := <-waitingService.GetReadyToSendChan(""):
+				assert.Fail(t, "the task must be sent once", "the task %v is sent again", actual)
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	}
 }
 
 func TestAddLateTask(t *testing.T) {
@@ -103,7 +200,7 @@ func TestAddLateTask(t *testing.T) {
 	//receive task after waiting
 	var actualCountOfTasks int32
 	go func() {
-		for task := range waitingService.GetReadyToSendChan() {
+		for task := range waitingService.GetReadyToSendChan("") {
 			now := time.Now().Unix()
 			assert.Less(t, task.ExecTime, now+int64(offset+dispersion), "the task goes beyond the time")
 			assert.GreaterOrEqual(t, task.ExecTime, now+int64(offset), "the task goes beyond the time")
@@ -120,6 +217,149 @@ func TestAddLateTask(t *testing.T) {
 	assert.Equal(t, inputCountOfTasks, atomic.LoadInt32(&actualCountOfTasks), "tasks count is not correct")
 }
 
+func TestDelayTask(t *testing.T) {
+	preloadedTask := make(chan domain.Task)
+	waitingService := instanceOfWaitingService(preloadedTask)
+
+	go waitingService.Run()
+
+	now := time.Now().Unix()
+	delayedTask := domain.Task{Id: util.NewId(), ExecTime: now + 2, Attempts: 1}
+	readyTask := domain.Task{Id: util.NewId(), ExecTime: now}
+
+	// the consumer delays the task while the waiting service is sending the ready task
+	preloadedTask <- readyTask
+	time.Sleep(10 * time.Millisecond)
+	waitingService.GetDelayedChan() <- delayedTask
+
+	assert.Equal(t, readyTask, <-waitingService.GetReadyToSendChan(""))
+	assert.Equal(t, delayedTask, <-waitingService.GetReadyToSendChan(""))
+	assert.Equal(t, now+2, time.Now().Unix(), "the delayed task is sent not at its time")
+}
+
+func TestStop(t *testing.T) {
+	preloadedTask := make(chan domain.Task, 1)
+	releasedTasks := make(chan []domain.Task, 1)
+	waitingService := New(
+		preloadedTask,
+		&monitoring_service.MonitoringMock{},
+		&task_manager.TaskManagerMock{ReleaseMock: func(ctx context.Context, tasks []domain.Task) error {
+			releasedTasks <- tasks
+			return nil
+		}},
+		nil,
+		nil,
+	)
+
+	go waitingService.Run()
+
+	now := time.Now().Unix()
+	tasks := []domain.Task{
+		{Id: util.NewId(), ExecTime: now},
+		{Id: util.NewId(), ExecTime: now + 100},
+		{Id: util.NewId(), ExecTime: now + 200},
+	}
+	for _, task := range tasks {
+		preloadedTask <- task
+	}
+
+	// nobody receives the ready task
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, waitingService.Stop(ctx))
+
+	assert.Equal(t, tasks, <-releasedTasks, "all waiting tasks must be released")
+}
+
+func TestMilliseconds(t *testing.T) {
+	preloadedTask := make(chan domain.Task, 3)
+	waitingService := instanceOfWaitingService(preloadedTask)
+	go waitingService.Run()
+
+	now := util.ToMs(time.Now())
+	for _, delay := range []int64{750, 250, 500} {
+		task := domain.Task{Id: util.NewId()}
+		task.SetExecTimeMs(now + delay)
+		preloadedTask <- task
+	}
+
+	for _, delay := range []int64{250, 500, 750} {
+		task := <-waitingService.GetReadyToSendChan("")
+		sentAt := util.ToMs(time.Now())
+		assert.Equal(t, now+delay, task.ExecTimeMs, "the tasks must be sent in the order of the time of execution")
+		assert.GreaterOrEqual(t, sentAt, task.ExecTimeMs, "the task must not be sent earlier")
+		assert.Less(t, sentAt, task.ExecTimeMs+100, "the task must be sent with the precision of milliseconds")
+	}
+}
+
+func TestPriority(t *testing.T) {
+	preloadedTask := make(chan domain.Task, 3)
+	waitingService := instanceOfWaitingService(preloadedTask)
+	go waitingService.Run()
+
+	execTimeMs := util.ToMs(time.Now()) + 200
+	var tasks []domain.Task
+	for _, priority := range []int{-1, 0, 10} {
+		task := domain.Task{Id: util.NewId(), Priority: priority}
+		task.SetExecTimeMs(execTimeMs)
+		tasks = append(tasks, task)
+		preloadedTask <- task
+	}
+
+	for _, expected := range []domain.Task{tasks[2], tasks[1], tasks[0]} {
+		assert.Equal(t, expected, <-waitingService.GetReadyToSendChan(""),
+			"the tasks with the same time must be sent in the order of the priority")
+	}
+}
+
+func TestQueues(t *testing.T) {
+	preloadedTask := make(chan domain.Task, 4)
+	waitingService := instanceOfWaitingService(preloadedTask)
+	go waitingService.Run()
+
+	now := time.Now().Unix()
+	emails := []domain.Task{
+		{Id: util.NewId(), ExecTime: now - 1, Queue: "emails"},
+		{Id: util.NewId(), ExecTime: now, Queue: "emails"},
+	}
+	sms := []domain.Task{
+		{Id: util.NewId(), ExecTime: now + 1, Queue: "sms"},
+		{Id: util.NewId(), ExecTime: now, Queue: "sms"},
+	}
+	for _, task := range append(emails, sms...) {
+		preloadedTask <- task
+	}
+
+	// nobody receives the tasks of the emails queue
+	assert.Equal(t, sms[1], <-waitingService.GetReadyToSendChan("sms"))
+	assert.Equal(t, sms[0], <-waitingService.GetReadyToSendChan("sms"), "the tasks must be sent in the order of the time of execution")
+
+	assert.Equal(t, emails[0], <-waitingService.GetReadyToSendChan("emails"))
+	assert.Equal(t, emails[1], <-waitingService.GetReadyToSendChan("emails"))
+	assert.Len(t, waitingService.GetReadyToSendChan(""), 0, "the tasks must be sent to the channels of their queues")
+}
+
+func TestReadyToSendBuffer(t *testing.T) {
+	preloadedTask := make(chan domain.Task, 5)
+	waitingService := New(
+		preloadedTask,
+		&monitoring_service.MonitoringMock{},
+		&task_manager.TaskManagerMock{},
+		nil,
+		&Options{ReadyToSendBuffer: 5},
+	)
+	go waitingService.Run()
+
+	for i := 0; i < 5; i++ {
+		preloadedTask <- domain.Task{Id: util.NewId(), ExecTime: time.Now().Unix()}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, waitingService.GetReadyToSendChan(""), 5, "the ready tasks must wait for the consumer in the buffer")
+}
+
 func instanceOfWaitingService(preloadedTask chan domain.Task) contracts.WaitingServiceInterface {
 	return New(
 		preloadedTask,
@@ -0,0 +1,324 @@
+package webhook_service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imdario/mergo"
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+)
+
+/*
+	Headers of the task which describe the request
+*/
+const (
+	/*
+		URL of the request. Required
+	*/
+	UrlHeader = "webhook.url"
+
+	/*
+		Method of the request. POST by default
+	*/
+	MethodHeader = "webhook.method"
+
+	/*
+		Headers of the task with the prefix are sent as the headers of the request without the prefix
+	*/
+	HeaderPrefix = "webhook.header."
+)
+
+/*
+	Headers of the request
+*/
+const (
+	TaskIdHeader    = "X-Triggerhook-Task-Id"
+	TimestampHeader = "X-Triggerhook-Timestamp"
+
+	/*
+		Hex encoded HMAC-SHA256 of the timestamp and the body of the request, see Sign
+	*/
+	SignatureHeader = "X-Triggerhook-Signature"
+)
+
+type Options struct {
+	/*
+		Count of the workers of each queue
+	*/
+	WorkersCount int
+
+	/*
+		Queues of the tasks executed by the service. The default queue if not specified
+	*/
+	Queues []string
+
+	/*
+		Timeout of the request
+	*/
+	Timeout time.Duration
+
+	/*
+		Max count of the requests to one host at once
+	*/
+	MaxConcurrencyPerHost int
+
+	/*
+		Delay of sending the task again when the count of the requests to its host is at the limit,
+		so the worker does not hold the task while waiting for the host. It is not counted as an attempt of the task
+	*/
+	BusyHostDelay time.Duration
+
+	/*
+		Key of the signature of the requests. The requests are not signed if it is not specified
+	*/
+	Secret string
+
+	Client *http.Client
+}
+
+/*
+	The service executes the tasks by the HTTP requests described in the headers of the task.
+	The task is confirmed on 2xx, rolled back with the backoff on 5xx, 408, 429 and network errors,
+	and moved to the dead letter store on other statuses and incorrect requests.
+	The task is postponed by BusyHostDelay when the count of the requests to its host is at the limit
+*/
+func New(
+	triggerHook contracts.TriggerHookInterface,
+	eventHandler contracts.EventHandlerInterface,
+	options *Options,
+) contracts.WebhookServiceInterface {
+
+	if options == nil {
+		options = &Options{}
+	}
+
+	defaultOptions := Options{
+		WorkersCount:          10,
+		Timeout:               10 * time.Second,
+		Queues:                []string{""},
+		MaxConcurrencyPerHost: 4,
+		BusyHostDelay:         time.Second,
+		Client:                &http.Client{},
+	}
+
+	if err := mergo.Merge(options, defaultOptions); err != nil {
+		panic(err)
+	}
+
+	return &webhookService{
+		triggerHook:           triggerHook,
+		eh:                    eventHandler,
+		workersCount:          options.WorkersCount,
+		queues:                options.Queues,
+		timeout:               options.Timeout,
+		maxConcurrencyPerHost: options.MaxConcurrencyPerHost,
+		busyHostDelay:         options.BusyHostDelay,
+		secret:                []byte(options.Secret),
+		client:                options.Client,
+		hosts:                 make(map[string]int),
+	}
+}
+
+type webhookService struct {
+	triggerHook           contracts.TriggerHookInterface
+	eh                    contracts.EventHandlerInterface
+	workersCount          int
+	queues                []string
+	timeout               time.Duration
+	maxConcurrencyPerHost int
+	busyHostDelay         time.Duration
+	secret                []byte
+	client                *http.Client
+
+	/*
+		Count of the requests to each host. The hosts without the requests are deleted
+	*/
+	hostsMu sync.Mutex
+	hosts   map[string]int
+}
+
+func (s *webhookService) Run() {
+	var wg sync.WaitGroup
+	for _, queue := range s.queues {
+		for worker := 0; worker < s.workersCount; worker++ {
+			wg.Add(1)
+			go func(queue string) {
+				defer wg.Done()
+				for {
+					task := s.triggerHook.ConsumeQueue(queue)
+					if task == nil {
+						return
+					}
+					s.execute(task)
+				}
+			}(queue)
+		}
+	}
+	wg.Wait()
+}
+
+/*
+	Error of the request which must not be repeated
+*/
+type permanentError struct {
+	reason string
+}
+
+func (e permanentError) Error() string {
+	return e.reason
+}
+
+/*
+	The count of the requests to the host of the task is at the limit
+*/
+type busyHostError struct {
+	host string
+}
+
+func (e busyHostError) Error() string {
+	return fmt.Sprintf("count of the requests to the host '%s' is at the limit", e.host)
+}
+
+func (s *webhookService) execute(taskToSend contracts.TaskToSendInterface) {
+	task := taskToSend.Task()
+
+	err := s.send(task)
+	switch err.(type) {
+	case nil:
+		taskToSend.Confirm()
+	case permanentError:
+		s.eh.New(contracts.LevelError, err.Error(), map[string]interface{}{"task id": task.Id})
+		taskToSend.Reject(err.Error())
+	case busyHostError:
+		s.eh.New(contracts.LevelDebug, err.Error(), map[string]interface{}{"task id": task.Id})
+		taskToSend.Postpone(s.busyHostDelay)
+	default:
+		s.eh.New(contracts.LevelDebug, err.Error(), map[string]interface{}{"task id": task.Id})
+		taskToSend.RollbackWithBackoff()
+	}
+}
+
+func (s *webhookService) send(task domain.Task) error {
+	request, err := s.newRequest(task)
+	if err != nil {
+		return permanentError{reason: err.Error()}
+	}
+
+	release, ok := s.acquire(request.URL.Host)
+	if !ok {
+		return busyHostError{host: request.URL.Host}
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	response, err := s.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	//	The connection is reused only if the body is read
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+
+	switch code := response.StatusCode; {
+	case code >= 200 && code < 300:
+		return nil
+	case code >= 500, code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return fmt.Errorf("request failed with status %d", code)
+	default:
+		return permanentError{reason: fmt.Sprintf("request rejected with status %d", code)}
+	}
+}
+
+func (s *webhookService) newRequest(task domain.Task) (*http.Request, error) {
+	target, err := url.Parse(task.Headers[UrlHeader])
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" || target.Host == "" {
+		return nil, fmt.Errorf("url of the webhook is not correct: '%s'", task.Headers[UrlHeader])
+	}
+
+	method := task.Headers[MethodHeader]
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	request, err := http.NewRequest(method, target.String(), bytes.NewReader(task.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range task.Headers {
+		if strings.HasPrefix(key, HeaderPrefix) {
+			request.Header.Set(strings.TrimPrefix(key, HeaderPrefix), value)
+		}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set(TaskIdHeader, task.Id)
+	request.Header.Set(TimestampHeader, timestamp)
+	if len(s.secret) > 0 {
+		request.Header.Set(SignatureHeader, Sign(s.secret, timestamp, task.Payload))
+	}
+
+	return request, nil
+}
+
+/*
+	Takes the place of the request to the host if the count of the requests is less than the limit.
+	Returns the function which must be called after the request
+*/
+func (s *webhookService) acquire(host string) (func(), bool) {
+	s.hostsMu.Lock()
+	defer s.hostsMu.Unlock()
+
+	if s.hosts[host] >= s.maxConcurrencyPerHost {
+		return nil, false
+	}
+	s.hosts[host]++
+
+	return func() {
+		s.hostsMu.Lock()
+		defer s.hostsMu.Unlock()
+
+		if s.hosts[host]--; s.hosts[host] == 0 {
+			delete(s.hosts, host)
+		}
+	}, true
+}
+
+/*
+	Signature of the request. The receiver calculates it with the same secret
+	from the headers X-Triggerhook-Timestamp and the body and compares with X-Triggerhook-Signature
+*/
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+	Checks the signature of the request in constant time
+*/
+func Verify(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,266 @@
+package webhook_service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pvelx/triggerhook/contracts"
+	"github.com/pvelx/triggerhook/domain"
+	"github.com/pvelx/triggerhook/error_service"
+	"github.com/pvelx/triggerhook/util"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+	The tasks of each queue are consumed from its channel, the queues without the channel are stopped
+*/
+type triggerHookMock struct {
+	contracts.TriggerHookInterface
+	tasks map[string]chan contracts.TaskToSendInterface
+}
+
+func (t *triggerHookMock) ConsumeQueue(queue string) contracts.TaskToSendInterface {
+	tasks, ok := t.tasks[queue]
+	if !ok {
+		return nil
+	}
+	task, ok := <-tasks
+	if !ok {
+		return nil
+	}
+	return task
+}
+
+type taskToSendMock struct {
+	contracts.TaskToSendInterface
+	task   domain.Task
+	result chan string
+}
+
+func (t *taskToSendMock) Confirm() {
+	t.result <- "confirm"
+}
+
+func (t *taskToSendMock) RollbackWithBackoff() {
+	t.result <- "rollback with backoff"
+}
+
+func (t *taskToSendMock) RollbackWithDelay(delay time.Duration) {
+	t.result <- "rollback " + delay.String()
+}
+
+func (t *taskToSendMock) Postpone(delay time.Duration) {
+	t.result <- "postpone " + delay.String()
+}
+
+func (t *taskToSendMock) Reject(reason string) {
+	t.result <- "reject"
+}
+
+func (t *taskToSendMock) Task() domain.Task {
+	return t.task
+}
+
+/*
+	Sends the tasks to the service and returns the results of the execution in the same order
+*/
+func execute(options *Options, tasks ...domain.Task) []string {
+	triggerHook := &triggerHookMock{tasks: make(map[string]chan contracts.TaskToSendInterface)}
+	var tasksToSend []*taskToSendMock
+	for _, task := range tasks {
+		if _, ok := triggerHook.tasks[task.Queue]; !ok {
+			triggerHook.tasks[task.Queue] = make(chan contracts.TaskToSendInterface, len(tasks))
+		}
+		taskToSend := &taskToSendMock{task: task, result: make(chan string, 1)}
+		tasksToSend = append(tasksToSend, taskToSend)
+		triggerHook.tasks[task.Queue] <- taskToSend
+	}
+	for _, queueTasks := range triggerHook.tasks {
+		close(queueTasks)
+	}
+
+	New(triggerHook, &error_service.ErrorHandlerMock{}, options).Run()
+
+	var results []string
+	for _, taskToSend := range tasksToSend {
+		results = append(results, <-taskToSend.result)
+	}
+
+	return results
+}
+
+func TestRequest(t *testing.T) {
+	secret := "secret"
+	task := domain.Task{
+		Id:       util.NewId(),
+		ExecTime: time.Now().Unix(),
+		Payload:  []byte(`{"order": 1}`),
+		Headers: map[string]string{
+			MethodHeader:                  http.MethodPut,
+			HeaderPrefix + "Content-Type": "application/json",
+			"other":                       "value",
+		},
+	}
+
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	task.Headers[UrlHeader] = server.URL + "/orders?id=1"
+	assert.Equal(t, []string{"confirm"}, execute(&Options{Secret: secret}, task))
+
+	request, body := <-requests, <-bodies
+	assert.Equal(t, http.MethodPut, request.Method)
+	assert.Equal(t, "/orders?id=1", request.URL.String())
+	assert.Equal(t, task.Payload, body)
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Empty(t, request.Header.Get("other"), "only the headers with the prefix must be sent")
+	assert.Equal(t, task.Id, request.Header.Get(TaskIdHeader))
+	assert.True(t, Verify(
+		[]byte(secret),
+		request.Header.Get(TimestampHeader),
+		body,
+		request.Header.Get(SignatureHeader),
+	), "the signature is not correct")
+	assert.False(t, Verify([]byte("other secret"), request.Header.Get(TimestampHeader), body, request.Header.Get(SignatureHeader)))
+}
+
+func TestResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, _ := map[string]int{
+			"/ok":                http.StatusOK,
+			"/accepted":          http.StatusAccepted,
+			"/bad-request":       http.StatusBadRequest,
+			"/not-found":         http.StatusNotFound,
+			"/too-many-requests": http.StatusTooManyRequests,
+			"/unavailable":       http.StatusServiceUnavailable,
+		}[r.URL.Path]
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	closedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedServer.Close()
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{server.URL + "/ok", "confirm"},
+		{server.URL + "/accepted", "confirm"},
+		{server.URL + "/bad-request", "reject"},
+		{server.URL + "/not-found", "reject"},
+		{server.URL + "/too-many-requests", "rollback with backoff"},
+		{server.URL + "/unavailable", "rollback with backoff"},
+		{closedServer.URL, "rollback with backoff"},
+		{"", "reject"},
+		{"ftp://example.com", "reject"},
+		{"http://%41", "reject"},
+	}
+
+	var tasks []domain.Task
+	var expected []string
+	for _, tt := range tests {
+		tasks = append(tasks, domain.Task{Id: util.NewId(), Headers: map[string]string{UrlHeader: tt.url}})
+		expected = append(expected, tt.expected)
+	}
+
+	//	The requests to the host are not limited here, the limit is tested by TestMaxConcurrencyPerHost
+	assert.Equal(t, expected, execute(&Options{MaxConcurrencyPerHost: len(tasks)}, tasks...))
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	task := domain.Task{Id: util.NewId(), Headers: map[string]string{UrlHeader: server.URL}}
+	assert.Equal(t, []string{"rollback with backoff"}, execute(&Options{Timeout: 20 * time.Millisecond}, task))
+}
+
+func TestMaxConcurrencyPerHost(t *testing.T) {
+	var current, max int32
+	mu := sync.Mutex{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		current++
+		if current > max {
+			max = current
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+	})
+	limited := httptest.NewServer(handler)
+	defer limited.Close()
+
+	var otherCount int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&otherCount, 1)
+	}))
+	defer other.Close()
+
+	var tasks []domain.Task
+	for i := 0; i < 20; i++ {
+		tasks = append(tasks, domain.Task{Id: util.NewId(), Headers: map[string]string{UrlHeader: limited.URL}})
+		tasks = append(tasks, domain.Task{Id: util.NewId(), Headers: map[string]string{UrlHeader: other.URL}})
+	}
+
+	results := execute(&Options{WorkersCount: 10, MaxConcurrencyPerHost: 2, BusyHostDelay: time.Minute}, tasks...)
+
+	//	The workers do not wait for the busy host, the task is sent again later without counting the attempt
+	var limitedDelayed, otherConfirmed int32
+	for i, result := range results {
+		assert.Contains(t, []string{"confirm", "postpone 1m0s"}, result)
+		switch {
+		case i%2 == 0 && result != "confirm":
+			limitedDelayed++
+		case i%2 == 1 && result == "confirm":
+			otherConfirmed++
+		}
+	}
+	assert.True(t, limitedDelayed > 0, "the task of the busy host must be postponed")
+	assert.Equal(t, int32(2), max, "the requests to one host exceed the limit")
+	assert.Equal(t, otherConfirmed, atomic.LoadInt32(&otherCount))
+}
+
+func TestIdleHosts(t *testing.T) {
+	service := New(&triggerHookMock{}, &error_service.ErrorHandlerMock{}, &Options{MaxConcurrencyPerHost: 1}).(*webhookService)
+
+	release, ok := service.acquire("example.com")
+	assert.True(t, ok)
+	_, ok = service.acquire("example.com")
+	assert.False(t, ok, "the requests to one host exceed the limit")
+
+	release()
+	assert.Empty(t, service.hosts, "the host without the requests must be deleted")
+
+	_, ok = service.acquire("example.com")
+	assert.True(t, ok, "the place of the request must be freed")
+}
+
+func TestQueues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tasks := []domain.Task{
+		{Id: util.NewId(), Queue: "emails", Headers: map[string]string{UrlHeader: server.URL}},
+		{Id: util.NewId(), Queue: "sms", Headers: map[string]string{UrlHeader: server.URL}},
+	}
+	assert.Equal(t, []string{"confirm", "confirm"}, execute(&Options{Queues: []string{"emails", "sms"}}, tasks...))
+}